@@ -2,20 +2,27 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
 	"embed"
+	"encoding/base64"
 	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/jota2rz/odoo-manager/internal/audit"
+	"github.com/jota2rz/odoo-manager/internal/certs"
+	"github.com/jota2rz/odoo-manager/internal/ctl"
 	"github.com/jota2rz/odoo-manager/internal/events"
 	"github.com/jota2rz/odoo-manager/internal/gitops"
 	"github.com/jota2rz/odoo-manager/internal/handlers"
+	"github.com/jota2rz/odoo-manager/internal/proxy"
 	"github.com/jota2rz/odoo-manager/internal/store"
 )
 
@@ -30,6 +37,17 @@ const (
 var staticFiles embed.FS
 
 func main() {
+	// When git invokes this binary as its GIT_ASKPASS helper, answer the
+	// credential prompt and exit without starting the server.
+	if gitops.IsAskPass() {
+		os.Exit(gitops.RunAskPass(os.Args[1:]))
+	}
+
+	// "odoo-manager ctl ..." is the command-line client of a running manager
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(ctl.Run(os.Args[2:]))
+	}
+
 	// Initialize project store
 	projectStore, err := store.NewProjectStore("data/odoo-manager.db")
 	if err != nil {
@@ -37,6 +55,19 @@ func main() {
 	}
 	defer projectStore.Close()
 
+	// Encrypt plaintext secrets left by older versions and re-encrypt any
+	// sealed with a rotated-out key
+	if n, err := projectStore.ResealSecrets(); err != nil {
+		log.Printf("Warning: failed to re-encrypt secrets: %v", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d secret(s) with the current key", n)
+	}
+
+	// Create the first account on a fresh install
+	if err := bootstrapAdmin(projectStore); err != nil {
+		log.Fatalf("Failed to create the initial admin user: %v", err)
+	}
+
 	// Ensure git CLI is available (download portable MinGit if needed)
 	gitAvailable := true
 	if err := gitops.EnsureGit(); err != nil {
@@ -44,6 +75,22 @@ func main() {
 		gitAvailable = false
 	}
 
+	// One-time migration: strip PAT tokens embedded in clone remotes by
+	// older versions so credentials no longer live in .git/config. It is
+	// only marked done once every remote was rewritten, so a partial run is
+	// retried on the next start.
+	if gitAvailable && projectStore.GetSetting("git_remotes_sanitized") != "true" {
+		n, err := gitops.SanitizeRemotes(context.Background())
+		if n > 0 {
+			log.Printf("Removed embedded credentials from %d git remote(s)", n)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to sanitize git remotes: %v", err)
+		} else {
+			_ = projectStore.SetSetting("git_remotes_sanitized", "true")
+		}
+	}
+
 	// Reset any projects stuck in transient statuses from a previous session
 	if n, err := projectStore.ReconcileStaleStatuses(); err != nil {
 		log.Printf("Warning: failed to reconcile stale statuses: %v", err)
@@ -86,10 +133,37 @@ func main() {
 	// Create handler with dependencies
 	handler := handlers.NewHandler(projectStore, staticHandler, eventHub, Version, auditLogger, gitAvailable)
 
-	// Start background Docker health check
+	// HTTPS for the manager and the project proxy
+	tlsConfig, tlsMode, caPEM, err := tlsFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up TLS: %v", err)
+	}
+	handler.SetTLS(tlsMode, caPEM)
+	// Largest backup a restore may upload
+	if v := os.Getenv("RESTORE_MAX_SIZE_MB"); v != "" {
+		mb, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || mb <= 0 {
+			log.Fatalf("RESTORE_MAX_SIZE_MB must be a positive number of megabytes, not %q", v)
+		}
+		handler.SetMaxRestoreSize(mb << 20)
+	}
+	// Projects behind the TLS proxy get proxy_mode; set before the server
+	// and background loops start reading it
+	proxyPort := os.Getenv("PROXY_PORT")
+	if proxyPort != "" && tlsConfig != nil {
+		handler.SetProxyTLS()
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+
+	// Start background loops: Docker health check, update checks, preview expiry
 	healthCtx, healthCancel := context.WithCancel(context.Background())
 	defer healthCancel()
 	handler.StartDockerHealthCheck(healthCtx)
+	handler.StartUpdatePoller(healthCtx)
+	handler.StartPreviewReaper(healthCtx)
 
 	// Setup HTTP routes
 	mux := http.NewServeMux()
@@ -108,16 +182,39 @@ func main() {
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
+		TLSConfig:    tlsConfig,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Starting Odoo Manager on http://localhost:%s", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Starting Odoo Manager on %s://localhost:%s", scheme, port)
+		if err := listen(srv); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
+	// Optional reverse proxy serving each project at {name}.{domain}. It has
+	// no write timeout so websockets and longpolling stay open.
+	var proxySrv *http.Server
+	if proxyPort != "" {
+		projectProxy := proxy.New(os.Getenv("PROXY_DOMAIN"))
+		projectProxy.Load(projectStore.List())
+		projectProxy.Run(healthCtx, eventHub)
+		proxySrv = &http.Server{
+			Addr:              fmt.Sprintf(":%s", proxyPort),
+			Handler:           projectProxy,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: 15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		}
+		go func() {
+			log.Printf("Serving projects at %s://{project-name}.%s:%s", scheme, projectProxy.Domain(), proxyPort)
+			if err := listen(proxySrv); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Proxy failed to start: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -129,9 +226,82 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if proxySrv != nil {
+		_ = proxySrv.Shutdown(ctx)
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
 	log.Println("Server exited")
 }
+
+// tlsFromEnv builds the TLS configuration from the environment:
+// TLS_CERT_FILE and TLS_KEY_FILE serve a given certificate, TLS_LOCAL_CA
+// issues certificates from a CA generated under data/tls. Returns a nil
+// config when neither is set, along with the mode and, for the local CA,
+// its certificate.
+func tlsFromEnv() (*tls.Config, string, []byte, error) {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("load TLS_CERT_FILE/TLS_KEY_FILE: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, "files", nil, nil
+	}
+
+	if enabled, _ := strconv.ParseBool(os.Getenv("TLS_LOCAL_CA")); enabled {
+		// Limit the CA to the project domain and this machine's name
+		host, _ := os.Hostname()
+		ca, err := certs.LoadOrCreateCA("data/tls", os.Getenv("PROXY_DOMAIN"), host)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		log.Printf("TLS certificates issued by the local CA (download it from /api/tls/ca.pem to trust it)")
+		return &tls.Config{GetCertificate: ca.GetCertificate, MinVersion: tls.VersionTLS12}, "local-ca", ca.CertPEM(), nil
+	}
+
+	return nil, "", nil, nil
+}
+
+// bootstrapAdmin creates the first user, an admin, when none exists. ADMIN_USERNAME
+// (default "admin") and ADMIN_PASSWORD set its credentials; without
+// ADMIN_PASSWORD a random password is generated and logged once.
+func bootstrapAdmin(s *store.ProjectStore) error {
+	if s.CountUsers() > 0 {
+		return nil
+	}
+
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		username = "admin"
+	}
+	password := os.Getenv("ADMIN_PASSWORD")
+	generated := password == ""
+	if generated {
+		buf := make([]byte, 12)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	if _, err := s.CreateUser(username, password, store.RoleAdmin); err != nil {
+		return err
+	}
+	if generated {
+		log.Printf("Created user %q with password %s — sign in and change it under Configuration → Account", username, password)
+	} else {
+		log.Printf("Created user %q with the password from ADMIN_PASSWORD", username)
+	}
+	return nil
+}
+
+// listen serves srv over TLS when it has a TLS configuration.
+func listen(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
@@ -1,3 +1,27 @@
+// ── Session Expiry & CSRF ──────────────────────────────────────────────
+// Every page and API call needs a signed-in session. When one comes back
+// 401 the session has expired or was signed out elsewhere, so go to the
+// login page and return here afterwards. Requests that change state carry
+// the session's CSRF token from the page's meta tag.
+
+const _fetch = window.fetch.bind(window);
+window.fetch = async function(input, init = {}) {
+  const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+  if (!['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+    const token = document.querySelector('meta[name="csrf-token"]')?.content;
+    if (token) {
+      const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+      headers.set('X-CSRF-Token', token);
+      init = { ...init, headers };
+    }
+  }
+  const resp = await _fetch(input, init);
+  if (resp.status === 401) {
+    location.href = '/login?next=' + encodeURIComponent(location.pathname + location.search);
+  }
+  return resp;
+};
+
 // ── SSE Real-time Updates ──────────────────────────────────────────────
 // Connects to /api/events and keeps the UI in sync without page reloads.
 
@@ -24,6 +48,7 @@ function connectSSE() {
     hideConnectionLostOverlay();
     // Reconcile project states to heal any SSE events missed during the gap
     syncAllProjects();
+    loadProjectUpdates();
     // Periodic self-heal: recover from any dropped SSE events
     if (_syncInterval) clearInterval(_syncInterval);
     _syncInterval = setInterval(syncAllProjects, 30000);
@@ -62,6 +87,18 @@ function connectSSE() {
     setCardPending(evt.project_id, evt.data);
   });
 
+  eventSource.addEventListener('git_progress', (e) => {
+    const evt = JSON.parse(e.data);
+    if (evt.data) setGitProgress(evt.project_id, evt.data);
+  });
+
+  eventSource.addEventListener('project_updates', (e) => {
+    const evt = JSON.parse(e.data);
+    if (!evt.data) return;
+    _projectUpdates[evt.project_id] = evt.data;
+    applyUpdateBadges(evt.project_id);
+  });
+
   eventSource.addEventListener('project_backup_pending', (e) => {
     const evt = JSON.parse(e.data);
     setBackupPending(evt.project_id, true);
@@ -208,7 +245,7 @@ function handleEmptyState() {
     <h3 class="mt-3 text-sm font-semibold text-white">No projects</h3>
     <p class="mt-1 text-sm text-gray-400">Get started by creating your first Odoo project.</p>
     <div class="mt-6">
-      <button onclick="showCreateProjectModal()" class="inline-flex items-center gap-x-1.5 rounded-md bg-indigo-500 px-3 py-2 text-sm font-semibold text-white shadow-sm hover:bg-indigo-400">
+      <button onclick="showCreateProjectModal()" data-requires="manage" class="inline-flex items-center gap-x-1.5 rounded-md bg-indigo-500 px-3 py-2 text-sm font-semibold text-white shadow-sm hover:bg-indigo-400">
         <svg class="-ml-0.5 size-5" viewBox="0 0 20 20" fill="currentColor"><path d="M10.75 4.75a.75.75 0 0 0-1.5 0v4.5h-4.5a.75.75 0 0 0 0 1.5h4.5v4.5a.75.75 0 0 0 1.5 0v-4.5h4.5a.75.75 0 0 0 0-1.5h-4.5v-4.5Z"/></svg>
         New Project
       </button>
@@ -247,6 +284,15 @@ function statusDotClass(status) {
 }
 
 // Build a project card DOM element matching the Templ-rendered structure
+// Lists the extra ports a project publishes, e.g. "Longpolling :8172 · debugpy :5678".
+function extraPortsText(project) {
+  const parts = [];
+  if (project.gevent_port) parts.push('Longpolling :' + project.gevent_port);
+  if (project.debug_port) parts.push('debugpy :' + project.debug_port);
+  if (project.db_port) parts.push('PostgreSQL :' + project.db_port);
+  return parts.join(' · ');
+}
+
 function buildProjectCard(project) {
   const isTransient = ['creating', 'deleting', 'starting', 'stopping', 'updating', 'updating-repo'].includes(project.status);
   const card = document.createElement('div');
@@ -267,15 +313,15 @@ function buildProjectCard(project) {
   let actionButtons = '';
   if (showRunningLayout) {
     actionButtons = `
-      <button onclick="window.stopProject('${project.id}')"
+      <button onclick="window.stopProject('${project.id}')" data-requires="operate"
         class="flex-1 inline-flex items-center justify-center gap-x-1.5 rounded-md bg-red-500/10 px-3 py-2 text-sm font-semibold text-red-400 ring-1 ring-inset ring-red-500/20 hover:bg-red-500/20 transition-colors">Stop</button>
       <a href="http://localhost:${project.port}" target="_blank"
         class="flex-1 inline-flex items-center justify-center gap-x-1.5 rounded-md bg-indigo-500 px-3 py-2 text-sm font-semibold text-white shadow-sm hover:bg-indigo-400 transition-colors">Open</a>
-      <button onclick="window.backupProject('${project.id}')" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-white hover:bg-white/10 transition-colors" title="Backup Database"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="M20.25 6.375c0 2.278-3.694 4.125-8.25 4.125S3.75 8.653 3.75 6.375m16.5 0c0-2.278-3.694-4.125-8.25-4.125S3.75 4.097 3.75 6.375m16.5 0v11.25c0 2.278-3.694 4.125-8.25 4.125s-8.25-1.847-8.25-4.125V6.375m16.5 0v3.75m-16.5-3.75v3.75m16.5 0v3.75C20.25 16.153 16.556 18 12 18s-8.25-1.847-8.25-4.125v-3.75m16.5 0c0 2.278-3.694 4.125-8.25 4.125s-8.25-1.847-8.25-4.125"/></svg></button>
+      <button onclick="window.backupProject('${project.id}')" data-requires="operate" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-white hover:bg-white/10 transition-colors" title="Backup Database"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="M20.25 6.375c0 2.278-3.694 4.125-8.25 4.125S3.75 8.653 3.75 6.375m16.5 0c0-2.278-3.694-4.125-8.25-4.125S3.75 4.097 3.75 6.375m16.5 0v11.25c0 2.278-3.694 4.125-8.25 4.125s-8.25-1.847-8.25-4.125V6.375m16.5 0v3.75m-16.5-3.75v3.75m16.5 0v3.75C20.25 16.153 16.556 18 12 18s-8.25-1.847-8.25-4.125v-3.75m16.5 0c0 2.278-3.694 4.125-8.25 4.125s-8.25-1.847-8.25-4.125"/></svg></button>
     `;
   } else {
     actionButtons = `
-      <button onclick="window.startProject('${project.id}')"
+      <button onclick="window.startProject('${project.id}')" data-requires="operate"
         class="flex-1 inline-flex items-center justify-center gap-x-1.5 rounded-md bg-green-500/10 px-3 py-2 text-sm font-semibold text-green-400 ring-1 ring-inset ring-green-500/20 hover:bg-green-500/20 transition-colors">Start</button>
     `;
   }
@@ -293,7 +339,7 @@ function buildProjectCard(project) {
   }
 
   let updateCodeBtn = '';
-  if (project.git_repo_url) {
+  if (project.git_repo_url || project.local_addons_path) {
     if (isTransient) {
       updateCodeBtn = `<button disabled class="flex-1 inline-flex items-center justify-center gap-1.5 rounded-lg bg-white/5 px-3 py-1.5 text-xs font-medium text-gray-500 cursor-not-allowed">${project.status === 'updating-repo' ? spinIcon : codeIcon} Update Repositories</button>`;
     } else {
@@ -307,6 +353,7 @@ function buildProjectCard(project) {
         <div class="min-w-0 flex-1">
           <h3 class="text-base font-semibold text-white truncate">${escapeHTML(project.name)}</h3>
           <p class="mt-1 text-sm text-gray-400 line-clamp-1">${escapeHTML(project.description || '')}</p>
+          ${project.expires_at ? `<p class="mt-1 text-xs text-amber-400" data-preview-expiry>Preview · expires ${escapeHTML(new Date(project.expires_at).toLocaleString([], { month: 'short', day: 'numeric', hour: '2-digit', minute: '2-digit' }))}</p>` : ''}
         </div>
         <span class="inline-flex items-center gap-x-1.5 rounded-full px-2.5 py-1 text-xs font-medium ring-1 ring-inset ${statusBadgeClass(project.status)}">
           <span class="h-1.5 w-1.5 rounded-full ${statusDotClass(project.status)}"></span>
@@ -316,21 +363,24 @@ function buildProjectCard(project) {
       <dl class="mt-5 grid grid-cols-3 gap-3 border-t border-white/5 pt-5 text-sm">
         <div><dt class="text-gray-500 text-xs">Odoo</dt><dd class="mt-1 font-medium text-white">v${escapeHTML(project.odoo_version)}</dd></div>
         <div><dt class="text-gray-500 text-xs">PostgreSQL</dt><dd class="mt-1 font-medium text-white">v${escapeHTML(project.postgres_version)}</dd></div>
-        <div><dt class="text-gray-500 text-xs">Port</dt><dd class="mt-1 font-medium text-white">${project.port}</dd></div>
+        <div><dt class="text-gray-500 text-xs">Port</dt><dd class="mt-1 font-medium text-white">${project.port}${project.exposed ? ` <span class="ml-1 rounded px-1 py-0.5 text-[10px] font-medium bg-amber-400/10 text-amber-400 ring-1 ring-inset ring-amber-400/20" title="Reachable from other machines via ${escapeHTML(project.bind_address)}" data-exposed>LAN</span>` : ''}</dd></div>
       </dl>
+      ${extraPortsText(project) ? `<p class="mt-3 text-xs text-gray-400" data-extra-ports>${escapeHTML(extraPortsText(project))}</p>` : ''}
       <div class="mt-5 flex items-center gap-2 border-t border-white/5 pt-5">
         ${actionButtons}
-        <button onclick="window.showConfigModal('${project.id}')" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-white hover:bg-white/10 transition-colors" title="Edit Config"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="M9.594 3.94c.09-.542.56-.94 1.11-.94h2.593c.55 0 1.02.398 1.11.94l.213 1.281c.063.374.313.686.645.87.074.04.147.083.22.127.325.196.72.257 1.075.124l1.217-.456a1.125 1.125 0 0 1 1.37.49l1.296 2.247a1.125 1.125 0 0 1-.26 1.431l-1.003.827c-.293.241-.438.613-.43.992a7.723 7.723 0 0 1 0 .255c-.008.378.137.75.43.991l1.004.827c.424.35.534.955.26 1.43l-1.298 2.247a1.125 1.125 0 0 1-1.369.491l-1.217-.456c-.355-.133-.75-.072-1.076.124a6.47 6.47 0 0 1-.22.128c-.331.183-.581.495-.644.869l-.213 1.281c-.09.543-.56.94-1.11.94h-2.594c-.55 0-1.019-.398-1.11-.94l-.213-1.281c-.062-.374-.312-.686-.644-.87a6.52 6.52 0 0 1-.22-.127c-.325-.196-.72-.257-1.076-.124l-1.217.456a1.125 1.125 0 0 1-1.369-.49l-1.297-2.247a1.125 1.125 0 0 1 .26-1.431l1.004-.827c.292-.24.437-.613.43-.991a6.932 6.932 0 0 1 0-.255c.007-.38-.138-.751-.43-.992l-1.004-.827a1.125 1.125 0 0 1-.26-1.43l1.297-2.247a1.125 1.125 0 0 1 1.37-.491l1.216.456c.356.133.751.072 1.076-.124.072-.044.146-.086.22-.128.332-.183.582-.495.644-.869l.214-1.28Z"/><path stroke-linecap="round" stroke-linejoin="round" d="M15 12a3 3 0 1 1-6 0 3 3 0 0 1 6 0Z"/></svg></button>
-        <button onclick="window.showLogs('${project.id}')" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-white hover:bg-white/10 transition-colors" title="View Logs"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="M19.5 14.25v-2.625a3.375 3.375 0 0 0-3.375-3.375h-1.5A1.125 1.125 0 0 1 13.5 7.125v-1.5a3.375 3.375 0 0 0-3.375-3.375H8.25m0 12.75h7.5m-7.5 3H12M10.5 2.25H5.625c-.621 0-1.125.504-1.125 1.125v17.25c0 .621.504 1.125 1.125 1.125h12.75c.621 0 1.125-.504 1.125-1.125V11.25a9 9 0 0 0-9-9Z"/></svg></button>
-        <button onclick="window.deleteProject('${project.id}')" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-red-400 hover:bg-white/10 transition-colors" title="Delete Project"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="m14.74 9-.346 9m-4.788 0L9.26 9m9.968-3.21c.342.052.682.107 1.022.166m-1.022-.165L18.16 19.673a2.25 2.25 0 0 1-2.244 2.077H8.084a2.25 2.25 0 0 1-2.244-2.077L4.772 5.79m14.456 0a48.108 48.108 0 0 0-3.478-.397m-12 .562c.34-.059.68-.114 1.022-.165m0 0a48.11 48.11 0 0 1 3.478-.397m7.5 0v-.916c0-1.18-.91-2.164-2.09-2.201a51.964 51.964 0 0 0-3.32 0c-1.18.037-2.09 1.022-2.09 2.201v.916m7.5 0a48.667 48.667 0 0 0-7.5 0"/></svg></button>
+        <button onclick="window.showConfigModal('${project.id}')" data-requires="manage" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-white hover:bg-white/10 transition-colors" title="Edit Config"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="M9.594 3.94c.09-.542.56-.94 1.11-.94h2.593c.55 0 1.02.398 1.11.94l.213 1.281c.063.374.313.686.645.87.074.04.147.083.22.127.325.196.72.257 1.075.124l1.217-.456a1.125 1.125 0 0 1 1.37.49l1.296 2.247a1.125 1.125 0 0 1-.26 1.431l-1.003.827c-.293.241-.438.613-.43.992a7.723 7.723 0 0 1 0 .255c-.008.378.137.75.43.991l1.004.827c.424.35.534.955.26 1.43l-1.298 2.247a1.125 1.125 0 0 1-1.369.491l-1.217-.456c-.355-.133-.75-.072-1.076.124a6.47 6.47 0 0 1-.22.128c-.331.183-.581.495-.644.869l-.213 1.281c-.09.543-.56.94-1.11.94h-2.594c-.55 0-1.019-.398-1.11-.94l-.213-1.281c-.062-.374-.312-.686-.644-.87a6.52 6.52 0 0 1-.22-.127c-.325-.196-.72-.257-1.076-.124l-1.217.456a1.125 1.125 0 0 1-1.369-.49l-1.297-2.247a1.125 1.125 0 0 1 .26-1.431l1.004-.827c.292-.24.437-.613.43-.991a6.932 6.932 0 0 1 0-.255c.007-.38-.138-.751-.43-.992l-1.004-.827a1.125 1.125 0 0 1-.26-1.43l1.297-2.247a1.125 1.125 0 0 1 1.37-.491l1.216.456c.356.133.751.072 1.076-.124.072-.044.146-.086.22-.128.332-.183.582-.495.644-.869l.214-1.28Z"/><path stroke-linecap="round" stroke-linejoin="round" d="M15 12a3 3 0 1 1-6 0 3 3 0 0 1 6 0Z"/></svg></button>
+        <button onclick="window.showLogs('${project.id}')" data-requires="operate" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-white hover:bg-white/10 transition-colors" title="View Logs"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="M19.5 14.25v-2.625a3.375 3.375 0 0 0-3.375-3.375h-1.5A1.125 1.125 0 0 1 13.5 7.125v-1.5a3.375 3.375 0 0 0-3.375-3.375H8.25m0 12.75h7.5m-7.5 3H12M10.5 2.25H5.625c-.621 0-1.125.504-1.125 1.125v17.25c0 .621.504 1.125 1.125 1.125h12.75c.621 0 1.125-.504 1.125-1.125V11.25a9 9 0 0 0-9-9Z"/></svg></button>
+        <button onclick="window.deleteProject('${project.id}')" data-requires="manage" class="rounded-md bg-white/5 p-2 text-gray-400 hover:text-red-400 hover:bg-white/10 transition-colors" title="Delete Project"><svg class="size-4" fill="none" viewBox="0 0 24 24" stroke-width="1.5" stroke="currentColor"><path stroke-linecap="round" stroke-linejoin="round" d="m14.74 9-.346 9m-4.788 0L9.26 9m9.968-3.21c.342.052.682.107 1.022.166m-1.022-.165L18.16 19.673a2.25 2.25 0 0 1-2.244 2.077H8.084a2.25 2.25 0 0 1-2.244-2.077L4.772 5.79m14.456 0a48.108 48.108 0 0 0-3.478-.397m-12 .562c.34-.059.68-.114 1.022-.165m0 0a48.11 48.11 0 0 1 3.478-.397m7.5 0v-.916c0-1.18-.91-2.164-2.09-2.201a51.964 51.964 0 0 0-3.32 0c-1.18.037-2.09 1.022-2.09 2.201v.916m7.5 0a48.667 48.667 0 0 0-7.5 0"/></svg></button>
       </div>
-      <div class="mt-4 flex items-center gap-2 border-t border-white/5 pt-4" data-update-buttons>
+      <div class="mt-4 flex items-center gap-2 border-t border-white/5 pt-4" data-update-buttons data-requires="manage">
         ${updateOdooBtn}
         ${updateCodeBtn}
       </div>
     </div>
   `;
 
+  if (!isTransient) applyUpdateBadges(project.id, card);
+
   // Apply pending visual state for transient statuses
   if (isTransient) {
     const btnRow = card.querySelector('.flex.items-center.gap-2');
@@ -443,6 +493,84 @@ function setCardPending(projectId, action) {
   }, 60000);
 }
 
+// Show git clone/pull progress ({ repo, phase, percent }) on a project
+// card. The bar goes away when the card is rebuilt on the next status change.
+function setGitProgress(projectId, progress) {
+  const card = document.getElementById('project-' + projectId);
+  if (!card) return;
+  let el = card.querySelector('[data-git-progress]');
+  if (!el) {
+    const updateRow = card.querySelector('[data-update-buttons]');
+    if (!updateRow) return;
+    el = document.createElement('div');
+    el.dataset.gitProgress = '';
+    el.className = 'mt-3';
+    el.innerHTML = `
+      <div class="flex items-center justify-between text-xs text-gray-400">
+        <span data-git-progress-label class="truncate"></span>
+        <span data-git-progress-pct class="ml-2 shrink-0 tabular-nums"></span>
+      </div>
+      <div class="mt-1 h-1 w-full overflow-hidden rounded-full bg-white/5">
+        <div data-git-progress-bar class="h-full rounded-full bg-indigo-500 transition-all duration-200" style="width:0%"></div>
+      </div>`;
+    updateRow.after(el);
+  }
+  const pct = Math.max(0, Math.min(100, progress.percent || 0));
+  el.querySelector('[data-git-progress-label]').textContent = `${progress.repo}: ${progress.phase}`;
+  el.querySelector('[data-git-progress-pct]').textContent = pct + '%';
+  el.querySelector('[data-git-progress-bar]').style.width = pct + '%';
+}
+
+// Last update check per project ID ({ repos: [{ repo, behind }], image_update })
+// from GET /api/updates and project_updates events.
+let _projectUpdates = {};
+
+async function loadProjectUpdates() {
+  try {
+    const resp = await fetch('/api/updates');
+    if (!resp.ok) return;
+    _projectUpdates = await resp.json();
+    Object.keys(_projectUpdates).forEach(id => applyUpdateBadges(id));
+  } catch (err) {
+    console.error('Failed to load update checks:', err);
+  }
+}
+
+// Show "N new" / "new image" badges on a card's update buttons. card
+// defaults to the one in the DOM (buildProjectCard passes its new element).
+function applyUpdateBadges(projectId, card) {
+  card = card || document.getElementById('project-' + projectId);
+  if (!card) return;
+  const updateRow = card.querySelector('[data-update-buttons]');
+  if (!updateRow) return;
+  const btns = updateRow.querySelectorAll('button');
+  btns.forEach(btn => btn.querySelectorAll('[data-update-badge]').forEach(b => b.remove()));
+  const info = _projectUpdates[projectId];
+  if (!info) return;
+
+  const badge = (text, title) => {
+    const el = document.createElement('span');
+    el.dataset.updateBadge = '';
+    el.className = 'ml-1 rounded-full bg-amber-400/10 px-1.5 py-0.5 text-[10px] font-semibold text-amber-400 ring-1 ring-inset ring-amber-400/20';
+    el.textContent = text;
+    el.title = title;
+    return el;
+  };
+
+  if (info.image_update && btns[0] && !btns[0].disabled) {
+    btns[0].appendChild(badge('new image', 'A newer Odoo image is available'));
+  }
+  // Update Odoo also pulls the repos; it carries the badge when there is no
+  // Update Repositories button (no custom addons repo)
+  const repoBtn = btns[1] || btns[0];
+  const behind = (info.repos || []).filter(r => r.behind > 0);
+  if (behind.length > 0 && repoBtn && !repoBtn.disabled) {
+    const total = behind.reduce((n, r) => n + r.behind, 0);
+    const title = behind.map(r => `${r.repo}: ${r.behind} commit${r.behind === 1 ? '' : 's'} behind`).join('\n');
+    repoBtn.appendChild(badge(total + ' new', title));
+  }
+}
+
 // Set the backup button into a pending/spinner state or restore it
 function setBackupPending(projectId, pending) {
   const card = document.getElementById('project-' + projectId);
@@ -568,6 +696,7 @@ document.addEventListener('click', (e) => {
 document.addEventListener('keydown', (e) => {
   if (e.key === 'Escape' && _confirmResolve) hideConfirmModal(false);
   if (e.key === 'Escape' && _configProjectId) hideConfigModal();
+  if (e.key === 'Escape' && _repoChangesProjectId) hideRepoChanges();
 });
 
 // ── Config Editor Modal ────────────────────────────────────────────────
@@ -599,6 +728,29 @@ window.showConfigModal = async function(id) {
   const branchSelect = document.getElementById('repoBranchSelect');
   if (branchWrapper) branchWrapper.classList.add('hidden');
   if (branchSelect) branchSelect.innerHTML = '';
+  const refInput = document.getElementById('repoRefInput');
+  if (refInput) refInput.value = '';
+  const localInput = document.getElementById('localAddonsInput');
+  if (localInput) localInput.value = '';
+  for (const inputId of ['configGeventPort', 'configDebugPort', 'configDBPort', 'configBindAddress']) {
+    const input = document.getElementById(inputId);
+    if (input) input.value = '';
+  }
+  const masterPassword = document.getElementById('configMasterPassword');
+  if (masterPassword) masterPassword.textContent = '••••••••';
+  const masterPasswordBtn = document.getElementById('configMasterPasswordBtn');
+  if (masterPasswordBtn) masterPasswordBtn.classList.remove('hidden');
+  const dbCredentials = document.getElementById('configDBCredentials');
+  if (dbCredentials) dbCredentials.textContent = '••••••••';
+  const dbCredentialsBtn = document.getElementById('configDBCredentialsBtn');
+  if (dbCredentialsBtn) dbCredentialsBtn.classList.remove('hidden');
+  // Reset deployed revision
+  const revisionWrapper = document.getElementById('configRevisionWrapper');
+  if (revisionWrapper) revisionWrapper.classList.add('hidden');
+  const worktreeWrapper = document.getElementById('configWorktreeWrapper');
+  if (worktreeWrapper) worktreeWrapper.classList.add('hidden');
+  const webhookWrapper = document.getElementById('configWebhookWrapper');
+  if (webhookWrapper) webhookWrapper.classList.add('hidden');
   // Reset enterprise toggle
   _configEnterpriseEnabled = false;
   const entToggle = document.getElementById('configEnterpriseToggle');
@@ -636,13 +788,27 @@ window.showConfigModal = async function(id) {
       const project = await projectResp.json();
       _configOdooVersion = project.odoo_version || '';
       repoInput.value = project.git_repo_url || '';
+      if (localInput) localInput.value = project.local_addons_path || '';
+      _setPortInput('configGeventPort', project.gevent_port);
+      _setPortInput('configDebugPort', project.debug_port);
+      _setPortInput('configDBPort', project.db_port);
+      const bindInput = document.getElementById('configBindAddress');
+      if (bindInput) bindInput.value = project.bind_address || '';
       // If there's a repo URL, fetch branches and select the saved branch
       if (project.git_repo_url) {
-        await _populateBranchSelect(
+        const branches = await _populateBranchSelect(
           'repoBranchSelect', 'configBranchWrapper', 'configBranchHint',
           project.git_repo_url, _configOdooVersion, project.git_repo_branch
         );
+        // A ref that is not a branch is a pinned tag or commit
+        if (refInput && project.git_repo_branch && !branches.includes(project.git_repo_branch)) {
+          refInput.value = project.git_repo_branch;
+        }
       }
+      _loadConfigRevision(id);
+      _loadConfigWorktree(id);
+      _loadConfigMembers(id);
+      if (project.git_repo_url) _loadConfigWebhook(id);
       // Set enterprise toggle state
       _configEnterpriseEnabled = !!project.enterprise_enabled;
       _applyEnterpriseAccess('configEnterpriseToggle', 'configEnterpriseWarning', entAccess, _configEnterpriseEnabled);
@@ -657,6 +823,374 @@ window.showConfigModal = async function(id) {
   }
 };
 
+// Loads the deployed repo revision into the Config modal.
+async function _loadConfigRevision(id) {
+  const wrapper = document.getElementById('configRevisionWrapper');
+  const text = document.getElementById('configRevisionText');
+  const btn = document.getElementById('configRollbackBtn');
+  if (!wrapper || !text) return;
+  try {
+    const resp = await fetch(`/api/projects/${id}/revisions?limit=2`);
+    if (!resp.ok) return;
+    const data = await resp.json();
+    if (!data.current) return;
+    const parts = [];
+    if (data.current.addons_commit) parts.push(`addons ${data.current.addons_commit.slice(0, 12)}`);
+    if (data.current.enterprise_commit) parts.push(`enterprise ${data.current.enterprise_commit.slice(0, 12)}`);
+    if (data.current.design_themes_commit) parts.push(`themes ${data.current.design_themes_commit.slice(0, 12)}`);
+    if (data.pinned) parts.push('pinned until the next update');
+    text.textContent = parts.join(' · ');
+    text.title = new Date(data.current.created_at).toLocaleString();
+    if (btn) btn.disabled = data.history.length < 2;
+    wrapper.classList.remove('hidden');
+  } catch (_) {
+    // Revision info is optional — leave hidden on error
+  }
+}
+
+// Summarises a repo status ({ branch, head, ahead, behind, files }) as
+// "main · ↑1 ↓2 · 3 changed".
+function _formatRepoStatus(st) {
+  const parts = [st.branch || `detached ${(st.head || '').slice(0, 12)}`];
+  if (st.ahead || st.behind) parts.push(`↑${st.ahead} ↓${st.behind}`);
+  parts.push(st.files.length ? `${st.files.length} changed` : 'clean');
+  return parts.join(' · ');
+}
+
+// Loads the addons working-tree status into the Config modal.
+async function _loadConfigWorktree(id) {
+  const wrapper = document.getElementById('configWorktreeWrapper');
+  const text = document.getElementById('configWorktreeText');
+  if (!wrapper || !text) return;
+  try {
+    const resp = await fetch(`/api/projects/${id}/repo/status`);
+    if (!resp.ok) return;
+    const st = await resp.json();
+    text.textContent = _formatRepoStatus(st);
+    text.classList.toggle('text-yellow-400', st.dirty);
+    wrapper.classList.remove('hidden');
+  } catch (_) {
+    // Status is optional — leave hidden on error
+  }
+}
+
+function _setPortInput(inputId, port) {
+  const input = document.getElementById(inputId);
+  if (input) input.value = port ? port : '';
+}
+
+// Applies the extra ports of the project in the Config modal. Empty inputs
+// close the port; the backend recreates the containers.
+window.saveProjectPorts = async function() {
+  if (!_configProjectId) return;
+  const btn = document.getElementById('configPortsBtn');
+  const portValue = (inputId) => parseInt(document.getElementById(inputId).value, 10) || 0;
+  btn.disabled = true;
+  btn.textContent = 'Applying…';
+  try {
+    const resp = await fetch(`/api/projects/${_configProjectId}/ports`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        gevent_port: portValue('configGeventPort'),
+        debug_port: portValue('configDebugPort'),
+        db_port: portValue('configDBPort'),
+        bind_address: document.getElementById('configBindAddress').value.trim() || '127.0.0.1',
+      }),
+    });
+    if (!resp.ok) {
+      const text = await resp.text();
+      let msg = text.trim();
+      try { msg = JSON.parse(text).error || msg; } catch (_) { /* plain text */ }
+      throw new Error(msg || 'Failed to apply ports');
+    }
+    showNotification('Ports applied', 'success');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  } finally {
+    btn.disabled = false;
+    btn.textContent = 'Apply';
+  }
+};
+
+// Lists the developers and viewers that can be assigned to the project in
+// the Config modal, checking its current members.
+async function _loadConfigMembers(id) {
+  const container = document.getElementById('configMembers');
+  if (!container) return;
+  container.textContent = 'Loading…';
+  try {
+    const resp = await fetch(`/api/projects/${id}/members`);
+    if (!resp.ok) throw new Error(await resp.text());
+    const data = await resp.json();
+    if (!data.users.length) {
+      container.innerHTML = '<span class="text-xs text-gray-500">No developers or viewers yet. Add them under Configuration → Users.</span>';
+      return;
+    }
+    const members = new Set(data.member_ids);
+    container.innerHTML = data.users.map(u => `
+      <label class="inline-flex items-center gap-2">
+        <input type="checkbox" value="${u.id}" ${members.has(u.id) ? 'checked' : ''} class="rounded border-white/10 bg-gray-950 text-indigo-500 focus:ring-indigo-500">
+        ${escapeHTML(u.username)} <span class="text-xs text-gray-500">${u.role}</span>
+      </label>`).join('');
+  } catch (err) {
+    container.textContent = 'Failed to load members: ' + err.message;
+  }
+}
+
+window.saveProjectMembers = async function() {
+  if (!_configProjectId) return;
+  const btn = document.getElementById('configMembersBtn');
+  const ids = Array.from(document.querySelectorAll('#configMembers input:checked')).map(i => parseInt(i.value, 10));
+  btn.disabled = true;
+  try {
+    const resp = await fetch(`/api/projects/${_configProjectId}/members`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ member_ids: ids }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    showNotification('Members saved', 'success');
+  } catch (err) {
+    showNotification('Failed to save members: ' + err.message, 'error');
+  } finally {
+    btn.disabled = false;
+  }
+};
+
+// Reveals the Odoo master password of the project in the Config modal.
+window.showMasterPassword = async function() {
+  if (!_configProjectId) return;
+  try {
+    const resp = await fetch(`/api/projects/${_configProjectId}/master-password`);
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to load master password');
+    const data = await resp.json();
+    let text = data.password;
+    if (data.hashed) text = 'Set from the database manager (stored hashed)';
+    else if (!text) text = 'Not set';
+    document.getElementById('configMasterPassword').textContent = text;
+    document.getElementById('configMasterPasswordBtn').classList.add('hidden');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
+// Generates a new master password for the project and shows it.
+window.rotateMasterPassword = async function() {
+  if (!_configProjectId) return;
+  const id = _configProjectId;
+  // The confirm dialog sits below the Config modal, so close it first
+  hideConfigModal();
+  const ok = await showConfirmModal({
+    title: 'Rotate Master Password',
+    message: 'Generate a new Odoo master password? The current one keeps working until the project is restarted.',
+    confirmText: 'Rotate',
+  });
+  if (!ok) return;
+  try {
+    const resp = await fetch(`/api/projects/${id}/master-password`, { method: 'POST' });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to rotate master password');
+    const data = await resp.json();
+    const bodyHtml = `<div class="rounded-lg bg-white/5 ring-1 ring-white/10 p-3 space-y-2">
+      <p class="text-xs text-gray-400">Master password</p>
+      <code class="block text-xs text-gray-200 break-all select-all">${escapeHTML(data.password)}</code>
+    </div>`;
+    await showConfirmModal({
+      title: 'Master Password Rotated',
+      message: 'Restart the project for the new master password to take effect.',
+      bodyHtml,
+      confirmText: 'Done',
+      confirmClass: 'bg-indigo-500 hover:bg-indigo-400 focus-visible:outline-indigo-500',
+    });
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
+// Reveals the PostgreSQL user and password of the project in the Config modal.
+window.showDBCredentials = async function() {
+  if (!_configProjectId) return;
+  try {
+    const resp = await fetch(`/api/projects/${_configProjectId}/db-credentials`);
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to load database login');
+    const data = await resp.json();
+    document.getElementById('configDBCredentials').textContent = `${data.user} / ${data.password}`;
+    document.getElementById('configDBCredentialsBtn').classList.add('hidden');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
+// Shows whether push webhooks are enabled for the project in the Config modal.
+async function _loadConfigWebhook(id) {
+  const wrapper = document.getElementById('configWebhookWrapper');
+  const text = document.getElementById('configWebhookText');
+  const btn = document.getElementById('configWebhookBtn');
+  const disableBtn = document.getElementById('configWebhookDisableBtn');
+  if (!wrapper || !text) return;
+  try {
+    const resp = await fetch(`/api/projects/${id}/webhook`);
+    if (!resp.ok) return;
+    const data = await resp.json();
+    text.textContent = data.enabled ? `Enabled · POST ${location.origin}${data.path}` : 'Disabled';
+    btn.textContent = data.enabled ? 'New secret' : 'Enable';
+    disableBtn.classList.toggle('hidden', !data.enabled);
+    wrapper.classList.remove('hidden');
+  } catch (_) {
+    // Webhook info is optional — leave hidden on error
+  }
+}
+
+// Generates a new webhook secret and shows it once.
+window.generateWebhookSecret = async function() {
+  if (!_configProjectId) return;
+  const id = _configProjectId;
+  try {
+    const resp = await fetch(`/api/projects/${id}/webhook`, { method: 'POST' });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to generate secret');
+    const data = await resp.json();
+    _loadConfigWebhook(id);
+    hideConfigModal();
+    const bodyHtml = `<div class="rounded-lg bg-white/5 ring-1 ring-white/10 p-3 space-y-2">
+      <p class="text-xs text-gray-400">Payload URL</p>
+      <code class="block text-xs text-gray-200 break-all select-all">${escapeHTML(location.origin + data.path)}</code>
+      <p class="text-xs text-gray-400">Secret</p>
+      <code class="block text-xs text-gray-200 break-all select-all">${escapeHTML(data.secret)}</code>
+    </div>`;
+    await showConfirmModal({
+      title: 'Push Webhook Enabled',
+      message: 'Add a push webhook with this URL and secret (content type application/json) in GitHub, GitLab or Gitea. The secret is shown only once.',
+      bodyHtml,
+      confirmText: 'Done',
+      confirmClass: 'bg-indigo-500 hover:bg-indigo-400 focus-visible:outline-indigo-500',
+    });
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
+// Disables push webhooks for the project in the Config modal.
+window.disableWebhook = async function() {
+  if (!_configProjectId) return;
+  const id = _configProjectId;
+  try {
+    const resp = await fetch(`/api/projects/${id}/webhook`, { method: 'DELETE' });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to disable webhook');
+    _loadConfigWebhook(id);
+    showNotification('Push webhook disabled', 'success');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
+let _repoChangesProjectId = null;
+
+// Opens the Repository Changes modal for the project in the Config modal.
+window.showRepoChanges = function() {
+  if (!_configProjectId) return;
+  _repoChangesProjectId = _configProjectId;
+  hideConfigModal();
+  document.getElementById('repoChangesRepo').value = 'addons';
+  document.getElementById('repoChangesModal').classList.remove('hidden');
+  loadRepoChanges();
+};
+
+window.hideRepoChanges = function() {
+  document.getElementById('repoChangesModal').classList.add('hidden');
+  _repoChangesProjectId = null;
+};
+
+// Fetches status, log and diff of the selected repo into the modal.
+window.loadRepoChanges = async function() {
+  const id = _repoChangesProjectId;
+  if (!id) return;
+  const repo = document.getElementById('repoChangesRepo').value;
+  const summary = document.getElementById('repoChangesSummary');
+  const errorEl = document.getElementById('repoChangesError');
+  const filesEl = document.getElementById('repoChangesFiles');
+  const logEl = document.getElementById('repoChangesLog');
+  const diffEl = document.getElementById('repoChangesDiff');
+  summary.textContent = 'Loading…';
+  errorEl.classList.add('hidden');
+  filesEl.innerHTML = '';
+  logEl.innerHTML = '';
+  diffEl.textContent = '';
+
+  const q = `repo=${encodeURIComponent(repo)}`;
+  try {
+    const [stResp, logResp, diffResp] = await Promise.all([
+      fetch(`/api/projects/${id}/repo/status?${q}`),
+      fetch(`/api/projects/${id}/repo/log?${q}&limit=30`),
+      fetch(`/api/projects/${id}/repo/diff?${q}`),
+    ]);
+    if (!stResp.ok) throw new Error((await stResp.text()).trim());
+    const st = await stResp.json();
+    summary.textContent = _formatRepoStatus(st) + (st.upstream ? ` · tracking ${st.upstream}` : '');
+
+    if (st.files.length === 0) {
+      filesEl.innerHTML = '<li class="text-xs text-gray-500">No local changes</li>';
+    }
+    st.files.forEach(f => {
+      const color = f.code === '??' ? 'text-gray-500' : 'text-yellow-400';
+      filesEl.innerHTML += `<li class="text-xs font-mono truncate ${color}" title="${escapeHTML(f.path)}">${escapeHTML(f.code)} ${escapeHTML(f.path)}</li>`;
+    });
+
+    if (logResp.ok) {
+      (await logResp.json()).forEach(c => {
+        logEl.innerHTML += `<li class="text-xs truncate" title="${escapeHTML(c.author)} · ${escapeHTML(new Date(c.date).toLocaleString())}"><code class="text-indigo-400">${escapeHTML(c.sha.slice(0, 8))}</code> <span class="text-gray-300">${escapeHTML(c.subject)}</span></li>`;
+      });
+    }
+
+    if (diffResp.ok) {
+      const diff = await diffResp.text();
+      diffEl.innerHTML = diff
+        ? diff.split('\n').map(line => {
+            const esc = escapeHTML(line);
+            if (line.startsWith('+') && !line.startsWith('+++')) return `<span class="text-green-400">${esc}</span>`;
+            if (line.startsWith('-') && !line.startsWith('---')) return `<span class="text-red-400">${esc}</span>`;
+            if (line.startsWith('@@')) return `<span class="text-cyan-400">${esc}</span>`;
+            return esc;
+          }).join('\n')
+        : '<span class="text-gray-500">No uncommitted changes to tracked files.</span>';
+      if (diffResp.headers.get('X-Diff-Truncated') === 'true') {
+        diffEl.innerHTML += '\n<span class="text-yellow-400">… diff truncated</span>';
+      }
+    }
+  } catch (err) {
+    summary.textContent = '';
+    errorEl.textContent = err.message || 'Failed to load repository status';
+    errorEl.classList.remove('hidden');
+  }
+};
+
+// Rolls the project's repos back to the previous recorded revision.
+window.rollbackRepos = async function() {
+  if (!_configProjectId) return;
+  const id = _configProjectId;
+  // The confirm dialog sits below the Config modal, so close it first
+  hideConfigModal();
+  const ok = await showConfirmModal({
+    title: 'Roll Back Repositories',
+    message: 'Check out the previously deployed commits and restart Odoo? The repositories stay at these commits, also across restarts, until you update or reconfigure them.',
+    confirmText: 'Roll back',
+  });
+  if (!ok) return;
+  try {
+    let resp = await fetch(`/api/projects/${id}/rollback`, { method: 'POST' });
+    if (resp.status === 409) {
+      if (!await _confirmForceDirty(await resp.json(), 'Stash & Roll back')) return;
+      resp = await fetch(`/api/projects/${id}/rollback?force=true`, { method: 'POST' });
+    }
+    if (!resp.ok) {
+      const text = await resp.text();
+      throw new Error(text.trim() || 'Rollback failed');
+    }
+    showNotification('Rolling back to the previous revision…', 'info');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
 function hideConfigModal() {
   const modal = document.getElementById('configModal');
   modal.classList.add('hidden');
@@ -684,7 +1218,9 @@ async function saveConfig() {
     // 1. Save repo URL first (includes validation)
     const repoUrl = repoInput ? repoInput.value.trim() : '';
     const branchSelect = document.getElementById('repoBranchSelect');
-    const repoBranch = branchSelect ? branchSelect.value : '';
+    const refInput = document.getElementById('repoRefInput');
+    const pinnedRef = refInput ? refInput.value.trim() : '';
+    const repoBranch = pinnedRef || (branchSelect ? branchSelect.value : '');
     if (repoUrl) {
       // Client-side format check
       if (!repoUrl.startsWith('https://') || !repoUrl.endsWith('.git')) {
@@ -692,10 +1228,16 @@ async function saveConfig() {
       }
     }
 
+    const localInput = document.getElementById('localAddonsInput');
+    const localPath = localInput ? localInput.value.trim() : '';
+    if (repoUrl && localPath) {
+      throw new Error('Choose either a repository URL or a local addons folder, not both');
+    }
+
     const repoResp = await fetch(`/api/projects/${_configProjectId}/repo`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ git_repo_url: repoUrl, git_repo_branch: repoBranch, enterprise_enabled: _configEnterpriseEnabled, design_themes_enabled: _configDesignThemesEnabled }),
+      body: JSON.stringify({ git_repo_url: repoUrl, git_repo_branch: repoBranch, local_addons_path: localPath, enterprise_enabled: _configEnterpriseEnabled, design_themes_enabled: _configDesignThemesEnabled }),
     });
     if (!repoResp.ok) {
       const data = await repoResp.json().catch(() => null);
@@ -717,6 +1259,8 @@ async function saveConfig() {
       const text = await configResp.text();
       throw new Error(text.trim() || 'Failed to save config');
     }
+    const configResult = await configResp.json().catch(() => ({}));
+    if (configResult.warning) showNotification(configResult.warning, 'warning');
     showNotification('Configuration saved. Restart the project for changes to take effect.', 'success');
     hideConfigModal();
   } catch (err) {
@@ -749,17 +1293,25 @@ async function saveAndRestartConfig() {
     // 1. Save repo URL first (includes validation)
     const repoUrl = repoInput ? repoInput.value.trim() : '';
     const branchSelect = document.getElementById('repoBranchSelect');
-    const repoBranch = branchSelect ? branchSelect.value : '';
+    const refInput = document.getElementById('repoRefInput');
+    const pinnedRef = refInput ? refInput.value.trim() : '';
+    const repoBranch = pinnedRef || (branchSelect ? branchSelect.value : '');
     if (repoUrl) {
       if (!repoUrl.startsWith('https://') || !repoUrl.endsWith('.git')) {
         throw new Error('Repository URL must start with https:// and end with .git');
       }
     }
 
+    const localInput = document.getElementById('localAddonsInput');
+    const localPath = localInput ? localInput.value.trim() : '';
+    if (repoUrl && localPath) {
+      throw new Error('Choose either a repository URL or a local addons folder, not both');
+    }
+
     const repoResp = await fetch(`/api/projects/${_configProjectId}/repo`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ git_repo_url: repoUrl, git_repo_branch: repoBranch, enterprise_enabled: _configEnterpriseEnabled, design_themes_enabled: _configDesignThemesEnabled }),
+      body: JSON.stringify({ git_repo_url: repoUrl, git_repo_branch: repoBranch, local_addons_path: localPath, enterprise_enabled: _configEnterpriseEnabled, design_themes_enabled: _configDesignThemesEnabled }),
     });
     if (!repoResp.ok) {
       const data = await repoResp.json().catch(() => null);
@@ -778,6 +1330,8 @@ async function saveAndRestartConfig() {
       const text = await configResp.text();
       throw new Error(text.trim() || 'Failed to save config');
     }
+    const configResult = await configResp.json().catch(() => ({}));
+    if (configResult.warning) showNotification(configResult.warning, 'warning');
 
     // 3. Restart Odoo container
     if (saveRestartBtn) saveRestartBtn.innerHTML = '<svg class="animate-spin h-4 w-4" fill="none" viewBox="0 0 24 24"><circle class="opacity-25" cx="12" cy="12" r="10" stroke="currentColor" stroke-width="4"></circle><path class="opacity-75" fill="currentColor" d="M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4z"></path></svg> Restarting…';
@@ -876,15 +1430,21 @@ async function createProject(event) {
     showNotification('Repository URL must start with https:// and end with .git', 'error');
     return;
   }
+  const localPath = (formData.get('local_addons_path') || '').trim();
+  if (repoUrl && localPath) {
+    showNotification('Choose either a repository URL or a local addons folder, not both', 'error');
+    return;
+  }
 
   const project = {
     name: formData.get('name'),
     description: formData.get('description'),
     odoo_version: formData.get('odoo_version'),
     postgres_version: formData.get('postgres_version'),
-    port: parseInt(formData.get('port')),
+    port: parseInt(formData.get('port')) || 0, // 0 = allocate from the port range
     git_repo_url: repoUrl,
     git_repo_branch: (formData.get('git_repo_branch') || '').trim(),
+    local_addons_path: localPath,
     enterprise_enabled: formData.get('enterprise_enabled') === 'true',
     design_themes_enabled: formData.get('design_themes_enabled') === 'true'
   };
@@ -903,8 +1463,11 @@ async function createProject(event) {
       // Card starts in "creating" status; SSE pending + status events handle the rest
       setCardPending(created.id, 'creating');
     } else {
+      // Port conflicts come back as JSON { error, port, owner }
       const error = await response.text();
-      showNotification(error.trim(), 'error');
+      let msg = error.trim();
+      try { msg = JSON.parse(error).error || msg; } catch (_) { /* plain text */ }
+      showNotification(msg, 'error');
     }
   } catch (error) {
     showNotification('Error creating project: ' + error.message, 'error');
@@ -932,7 +1495,9 @@ window.startProject = async function(id) {
     const response = await fetch(`/api/projects/${id}/start`, { method: 'POST' });
     if (!response.ok) {
       const error = await response.text();
-      showNotification('Failed to start project: ' + error, 'error');
+      let msg = error.trim();
+      try { msg = JSON.parse(error).error || msg; } catch (_) { /* plain text */ }
+      showNotification('Failed to start project: ' + msg, 'error');
       setButtonLoading(button, false);
     }
     // On success (202 Accepted) the SSE pending + status events handle the UI.
@@ -1005,7 +1570,15 @@ window.updateRepoCode = async function(id) {
   const button = event.currentTarget;
   setButtonLoading(button, true);
   try {
-    const response = await fetch(`/api/projects/${id}/update-repo`, { method: 'POST' });
+    let response = await fetch(`/api/projects/${id}/update-repo`, { method: 'POST' });
+    if (response.status === 409) {
+      const force = await _confirmForceDirty(await response.json(), 'Stash & Update');
+      if (!force) {
+        setButtonLoading(button, false);
+        return;
+      }
+      response = await fetch(`/api/projects/${id}/update-repo?force=true`, { method: 'POST' });
+    }
     if (!response.ok) {
       const error = await response.text();
       showNotification('Failed to update repositories: ' + error, 'error');
@@ -1017,6 +1590,27 @@ window.updateRepoCode = async function(id) {
   }
 };
 
+// Asks whether to stash local changes reported by a 409 from update-repo
+// or rollback ({ error, repos: { name: [files] } }) and go ahead anyway.
+function _confirmForceDirty(data, confirmText) {
+  let bodyHtml = '<div class="rounded-lg bg-white/5 ring-1 ring-white/10 p-3 max-h-48 overflow-y-auto">';
+  Object.entries(data.repos || {}).forEach(([repo, files]) => {
+    bodyHtml += `<p class="text-xs font-medium text-gray-300 mb-1">${escapeHTML(repo)} — ${files.length} modified</p><ul class="space-y-1 mb-2">`;
+    files.forEach(f => {
+      bodyHtml += `<li class="text-xs text-gray-400 truncate font-mono" title="${escapeHTML(f)}">${escapeHTML(f)}</li>`;
+    });
+    bodyHtml += '</ul>';
+  });
+  bodyHtml += '</div>';
+  return showConfirmModal({
+    title: 'Uncommitted Changes',
+    message: (data.error || 'The working tree has local changes.') + ' Stashed changes can be restored with "git stash pop".',
+    bodyHtml,
+    confirmText,
+    confirmClass: 'bg-yellow-600 hover:bg-yellow-500 focus-visible:outline-yellow-600',
+  });
+}
+
 // ── ANSI escape code to HTML conversion ───────────────────────────────
 
 const ANSI_COLORS = {
@@ -1177,7 +1771,9 @@ function startBackup(id, dbName) {
     logViewer.scrollTop = logViewer.scrollHeight;
   }
 
-  backupSource = new EventSource(`/api/projects/${id}/backup?db=${encodeURIComponent(dbName)}`);
+  // EventSource cannot send headers, so the CSRF token goes in the query
+  const csrf = document.querySelector('meta[name="csrf-token"]')?.content || '';
+  backupSource = new EventSource(`/api/projects/${id}/backup?db=${encodeURIComponent(dbName)}&csrf_token=${encodeURIComponent(csrf)}`);
 
   backupSource.onmessage = function(event) {
     appendLog(event.data);
@@ -1187,8 +1783,9 @@ function startBackup(id, dbName) {
     backupSource.close();
     appendLog('Download starting…', 'text-green-400');
 
+    // The download deletes the backup, so it needs the CSRF token too
     const a = document.createElement('a');
-    a.href = e.data;
+    a.href = `${e.data}?csrf_token=${encodeURIComponent(csrf)}`;
     a.download = '';
     document.body.appendChild(a);
     a.click();
@@ -1300,6 +1897,7 @@ function showNotification(message, type = 'info') {
     success: 'bg-green-500/90 ring-green-500/20',
     error:   'bg-red-500/90 ring-red-500/20',
     info:    'bg-indigo-500/90 ring-indigo-500/20',
+    warning: 'bg-yellow-500/90 ring-yellow-500/20',
   };
 
   const notification = document.createElement('div');
@@ -1505,7 +2103,8 @@ function initAuditPage() {
     auditSrc.onmessage = function(e) {
       try {
         const entry = JSON.parse(e.data);
-        const line = `[${entry.timestamp}] ${entry.client_ip} ${entry.method} ${entry.path} — ${entry.message}`;
+        const who = entry.token ? `${entry.username}[token:${entry.token}]` : entry.username;
+        const line = `[${entry.timestamp}] ${entry.client_ip} ${who} ${entry.method} ${entry.path} — ${entry.message}`;
         const wasAtBottom = (container.scrollHeight - container.scrollTop - container.clientHeight) < 40;
         appendLine(line);
         currentOffset++;
@@ -1541,6 +2140,7 @@ const CLEAN_LABELS = {
   containers: { singular: 'container', plural: 'containers', title: 'Clean Orphaned Containers' },
   volumes:    { singular: 'volume',    plural: 'volumes',    title: 'Clean Orphaned Volumes' },
   images:     { singular: 'image',     plural: 'images',     title: 'Clean Orphaned Images' },
+  networks:   { singular: 'network',   plural: 'networks',   title: 'Clean Orphaned Networks' },
 };
 
 function initMaintenancePage() {
@@ -1684,13 +2284,13 @@ async function _populateBranchSelect(selectId, wrapperId, hintId, repoUrl, odooV
       const data = await resp.json().catch(() => null);
       const msg = data && data.error ? data.error : 'Failed to load branches';
       select.innerHTML = `<option value="">${msg}</option>`;
-      return;
+      return [];
     }
 
     const branches = await resp.json();
     if (!branches || branches.length === 0) {
       select.innerHTML = '<option value="">No branches found</option>';
-      return;
+      return [];
     }
 
     select.innerHTML = '';
@@ -1720,8 +2320,10 @@ async function _populateBranchSelect(selectId, wrapperId, hintId, repoUrl, odooV
         hint.textContent = '';
       }
     }
+    return branches;
   } catch (err) {
     select.innerHTML = `<option value="">Error: ${err.message}</option>`;
+    return [];
   }
 }
 
@@ -1917,6 +2519,8 @@ window.toggleConfigDesignThemes = function() {
 // ── Configuration Page ─────────────────────────────────────────────────
 
 async function initConfigurationPage() {
+  loadUsers();
+  loadTokens();
   const tokenInput = document.getElementById('patTokenInput');
   const currentVal = document.getElementById('patCurrentValue');
   if (!tokenInput) return;
@@ -1932,12 +2536,357 @@ async function initConfigurationPage() {
         currentVal.textContent = 'No token configured';
       }
       updatePatBadge(data.github_pat_valid, !!data.github_pat);
+      const pollInput = document.getElementById('updatePollInput');
+      if (pollInput) pollInput.value = data.update_poll_minutes;
+      const rangeInput = document.getElementById('portRangeInput');
+      if (rangeInput) rangeInput.value = data.port_range;
+      const bindInput = document.getElementById('bindAddressInput');
+      if (bindInput) bindInput.value = data.bind_address;
+      updateTLSStatus(data.tls);
+      const originsInput = document.getElementById('allowedOriginsInput');
+      if (originsInput) originsInput.value = data.allowed_origins;
+      for (const [key, id] of Object.entries(_oidcInputs)) {
+        const input = document.getElementById(id);
+        if (input) input.value = data[key] || '';
+      }
     }
   } catch (err) {
     console.error('Failed to load settings:', err);
   }
 }
 
+window.saveAllowedOrigins = async function() {
+  const btn = document.getElementById('allowedOriginsSaveBtn');
+  btn.disabled = true;
+  try {
+    const resp = await fetch('/api/settings', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ allowed_origins: document.getElementById('allowedOriginsInput').value }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    showNotification('Allowed origins saved', 'success');
+  } catch (err) {
+    showNotification('Failed to save: ' + err.message, 'error');
+  } finally {
+    btn.disabled = false;
+  }
+};
+
+// Settings keys of the Single Sign-On card and the inputs holding them.
+const _oidcInputs = {
+  oidc_issuer: 'oidcIssuerInput',
+  oidc_client_id: 'oidcClientIDInput',
+  oidc_client_secret: 'oidcClientSecretInput',
+  oidc_scopes: 'oidcScopesInput',
+  oidc_groups_claim: 'oidcGroupsClaimInput',
+  oidc_redirect_url: 'oidcRedirectURLInput',
+  oidc_role_mapping: 'oidcRoleMappingInput',
+  oidc_default_role: 'oidcDefaultRoleInput',
+};
+
+window.saveOIDCSettings = async function() {
+  const btn = document.getElementById('oidcSaveBtn');
+  const body = {};
+  for (const [key, id] of Object.entries(_oidcInputs)) {
+    body[key] = document.getElementById(id).value.trim();
+  }
+  btn.disabled = true;
+  try {
+    const resp = await fetch('/api/settings', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(body),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    showNotification(body.oidc_issuer ? 'Single sign-on saved' : 'Single sign-on turned off', 'success');
+  } catch (err) {
+    showNotification('Failed to save: ' + err.message, 'error');
+  } finally {
+    btn.disabled = false;
+  }
+};
+
+window.saveUpdatePoll = async function() {
+  const input = document.getElementById('updatePollInput');
+  const minutes = parseInt(input.value, 10);
+  if (isNaN(minutes) || minutes < 0) {
+    showNotification('Enter 0 or a positive number of minutes', 'error');
+    return;
+  }
+  try {
+    const resp = await fetch('/api/settings', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ update_poll_minutes: minutes }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    showNotification(minutes === 0 ? 'Update checks disabled' : 'Update checks every ' + minutes + ' min', 'success');
+  } catch (err) {
+    showNotification('Failed to save: ' + err.message, 'error');
+  }
+};
+
+window.saveProjectPortSettings = async function() {
+  const portRange = document.getElementById('portRangeInput').value.trim();
+  const bindAddress = document.getElementById('bindAddressInput').value.trim() || '127.0.0.1';
+  try {
+    const resp = await fetch('/api/settings', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ port_range: portRange, bind_address: bindAddress }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    showNotification('Port settings saved', 'success');
+  } catch (err) {
+    showNotification('Failed to save: ' + err.message, 'error');
+  }
+};
+
+// ── Users (admins only) ──
+
+// Usernames by ID, for the confirmation messages of the user actions.
+const _usernames = new Map();
+
+async function loadUsers() {
+  const list = document.getElementById('usersList');
+  if (!list) return;
+  try {
+    const resp = await fetch('/api/users');
+    if (!resp.ok) throw new Error(await resp.text());
+    const users = await resp.json();
+    _usernames.clear();
+    users.forEach(u => _usernames.set(u.id, u.username));
+    const roles = ['admin', 'maintainer', 'developer', 'viewer'];
+    list.innerHTML = users.map(u => `
+      <div class="flex items-center gap-3 py-2">
+        <span class="flex-1 text-sm text-gray-200">${escapeHTML(u.username)}${u.oidc_subject ? ' <span class="ml-1 rounded bg-white/5 px-1.5 py-0.5 text-xs text-gray-400" title="Signs in through single sign-on">SSO</span>' : ''}</span>
+        <select onchange="setUserRole(${u.id}, this.value)" class="rounded-md bg-gray-950 px-2 py-1 text-xs text-gray-200 ring-1 ring-inset ring-white/10 focus:outline-none focus:ring-2 focus:ring-indigo-500">
+          ${roles.map(r => `<option value="${r}" ${r === u.role ? 'selected' : ''}>${r}</option>`).join('')}
+        </select>
+        ${u.oidc_subject ? '' : `<button onclick="resetUserPassword(${u.id})" class="rounded-md bg-white/10 px-2.5 py-1 text-xs font-semibold text-white hover:bg-white/20">Reset password</button>`}
+        <button onclick="deleteUser(${u.id})" class="rounded-md bg-white/5 px-2.5 py-1 text-xs font-semibold text-gray-400 hover:text-red-400 hover:bg-white/10">Delete</button>
+      </div>`).join('');
+  } catch (err) {
+    list.textContent = 'Failed to load users: ' + err.message;
+  }
+}
+
+window.createUser = async function() {
+  const nameInput = document.getElementById('newUserName');
+  const passwordInput = document.getElementById('newUserPassword');
+  const btn = document.getElementById('createUserBtn');
+  btn.disabled = true;
+  try {
+    const resp = await fetch('/api/users', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        username: nameInput.value.trim(),
+        password: passwordInput.value,
+        role: document.getElementById('newUserRole').value,
+      }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    nameInput.value = '';
+    passwordInput.value = '';
+    showNotification('User created', 'success');
+    loadUsers();
+  } catch (err) {
+    showNotification('Failed to create user: ' + err.message, 'error');
+  } finally {
+    btn.disabled = false;
+  }
+};
+
+async function _updateUser(id, body) {
+  const resp = await fetch(`/api/users/${id}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  });
+  if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to update user');
+}
+
+window.setUserRole = async function(id, role) {
+  try {
+    await _updateUser(id, { role });
+    showNotification('Role changed', 'success');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+  loadUsers();
+};
+
+window.resetUserPassword = async function(id) {
+  const username = _usernames.get(id);
+  const pending = showConfirmModal({
+    title: 'Reset Password',
+    message: `Set a new password for ${username}. Their sessions are signed out.`,
+    bodyHtml: '<input id="resetPasswordInput" type="password" autocomplete="new-password" placeholder="At least 8 characters" class="block w-full rounded-md bg-gray-950 px-3 py-2 text-sm text-gray-200 ring-1 ring-inset ring-white/10 focus:outline-none focus:ring-2 focus:ring-indigo-500 placeholder:text-gray-600">',
+    confirmText: 'Reset',
+    confirmClass: 'bg-indigo-500 hover:bg-indigo-400 focus-visible:outline-indigo-500',
+  });
+  // The modal body is cleared before the promise resolves, so keep the value.
+  let password = '';
+  document.getElementById('resetPasswordInput').addEventListener('input', (e) => { password = e.target.value; });
+  if (!await pending) return;
+  try {
+    await _updateUser(id, { password });
+    showNotification(`Password of ${username} reset`, 'success');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+};
+
+window.deleteUser = async function(id) {
+  const username = _usernames.get(id);
+  const confirmed = await showConfirmModal({
+    title: 'Delete User',
+    message: `Delete ${username}? They are signed out and lose access immediately.`,
+  });
+  if (!confirmed) return;
+  try {
+    const resp = await fetch(`/api/users/${id}`, { method: 'DELETE' });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to delete user');
+    showNotification(`User ${username} deleted`, 'success');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+  loadUsers();
+};
+
+// ── API tokens ──
+
+// Token names by ID, for the revoke confirmation.
+const _tokenNames = new Map();
+
+function _formatTokenDate(value, fallback) {
+  return value ? new Date(value).toLocaleDateString() : fallback;
+}
+
+async function loadTokens() {
+  const list = document.getElementById('tokensList');
+  if (!list) return;
+  try {
+    const resp = await fetch('/api/tokens');
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to load tokens');
+    const tokens = await resp.json();
+    _tokenNames.clear();
+    tokens.forEach(t => _tokenNames.set(t.id, t.name));
+    if (!tokens.length) {
+      list.innerHTML = '<p class="py-2 text-xs text-gray-500">No API tokens yet.</p>';
+      return;
+    }
+    const now = Date.now();
+    list.innerHTML = tokens.map(t => {
+      const expired = t.expires_at && new Date(t.expires_at).getTime() < now;
+      return `
+      <div class="flex items-center gap-3 py-2">
+        <span class="flex-1 text-sm text-gray-200">${escapeHTML(t.name)} <span class="ml-1 rounded bg-white/5 px-1.5 py-0.5 text-xs text-gray-400">${t.scope}</span></span>
+        <span class="text-xs ${expired ? 'text-red-400' : 'text-gray-500'}">${expired ? 'Expired' : 'Expires'} ${_formatTokenDate(t.expires_at, 'never')}</span>
+        <span class="text-xs text-gray-500">Last used ${_formatTokenDate(t.last_used_at, 'never')}</span>
+        <button onclick="revokeToken(${t.id})" class="rounded-md bg-white/5 px-2.5 py-1 text-xs font-semibold text-gray-400 hover:text-red-400 hover:bg-white/10">Revoke</button>
+      </div>`;
+    }).join('');
+  } catch (err) {
+    list.textContent = err.message;
+  }
+}
+
+window.createToken = async function() {
+  const nameInput = document.getElementById('newTokenName');
+  const btn = document.getElementById('createTokenBtn');
+  btn.disabled = true;
+  try {
+    const resp = await fetch('/api/tokens', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        name: nameInput.value.trim(),
+        scope: document.getElementById('newTokenScope').value,
+        expires_in_days: parseInt(document.getElementById('newTokenExpiry').value, 10),
+      }),
+    });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to create token');
+    const data = await resp.json();
+    nameInput.value = '';
+    loadTokens();
+    const bodyHtml = `<div class="rounded-lg bg-white/5 ring-1 ring-white/10 p-3 space-y-2">
+      <p class="text-xs text-gray-400">Token</p>
+      <code class="block text-xs text-gray-200 break-all select-all">${escapeHTML(data.token)}</code>
+    </div>`;
+    await showConfirmModal({
+      title: 'API Token Created',
+      message: `Copy the token ${data.name} now; it is shown only once. Send it as "Authorization: Bearer <token>".`,
+      bodyHtml,
+      confirmText: 'Done',
+      confirmClass: 'bg-indigo-500 hover:bg-indigo-400 focus-visible:outline-indigo-500',
+    });
+  } catch (err) {
+    showNotification(err.message, 'error');
+  } finally {
+    btn.disabled = false;
+  }
+};
+
+window.revokeToken = async function(id) {
+  const name = _tokenNames.get(id);
+  const confirmed = await showConfirmModal({
+    title: 'Revoke Token',
+    message: `Revoke ${name}? Scripts using it stop working immediately.`,
+    confirmText: 'Revoke',
+  });
+  if (!confirmed) return;
+  try {
+    const resp = await fetch(`/api/tokens/${id}`, { method: 'DELETE' });
+    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Failed to revoke token');
+    showNotification(`Token ${name} revoked`, 'success');
+  } catch (err) {
+    showNotification(err.message, 'error');
+  }
+  loadTokens();
+};
+
+window.changePassword = async function() {
+  const currentInput = document.getElementById('currentPasswordInput');
+  const newInput = document.getElementById('newPasswordInput');
+  const btn = document.getElementById('changePasswordBtn');
+  btn.disabled = true;
+  try {
+    const resp = await fetch('/api/account/password', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ current_password: currentInput.value, new_password: newInput.value }),
+    });
+    if (!resp.ok) throw new Error(await resp.text());
+    currentInput.value = '';
+    newInput.value = '';
+    showNotification('Password changed', 'success');
+  } catch (err) {
+    showNotification('Failed to change password: ' + err.message, 'error');
+  } finally {
+    btn.disabled = false;
+  }
+};
+
+// Shows how HTTPS is served and offers the local CA for download.
+function updateTLSStatus(mode) {
+  const badge = document.getElementById('tlsStatusBadge');
+  const caWrapper = document.getElementById('tlsCAWrapper');
+  if (!badge) return;
+  const labels = { files: 'CERTIFICATE FILES', 'local-ca': 'LOCAL CA' };
+  badge.classList.remove('hidden');
+  if (labels[mode]) {
+    badge.className = 'inline-flex items-center rounded-full px-2 py-0.5 text-xs font-medium ring-1 ring-inset bg-green-400/10 text-green-400 ring-green-400/20';
+    badge.textContent = labels[mode];
+  } else {
+    badge.className = 'inline-flex items-center rounded-full px-2 py-0.5 text-xs font-medium ring-1 ring-inset bg-gray-400/10 text-gray-400 ring-gray-400/20';
+    badge.textContent = 'OFF';
+  }
+  if (caWrapper) caWrapper.classList.toggle('hidden', mode !== 'local-ca');
+}
+
 function updatePatBadge(validStr, hasToken) {
   const badge = document.getElementById('patStatusBadge');
   if (!badge) return;
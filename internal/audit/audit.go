@@ -10,12 +10,16 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
 )
 
 // Entry represents a single audit log line.
 type Entry struct {
 	Timestamp string `json:"timestamp"`
 	ClientIP  string `json:"client_ip"`
+	Username  string `json:"username"`        // signed-in user, "-" when anonymous
+	Token     string `json:"token,omitempty"` // name of the API token used, if any
 	Method    string `json:"method"`
 	Path      string `json:"path"`
 	Message   string `json:"message"`
@@ -55,17 +59,29 @@ func NewLogger(path string) (*Logger, error) {
 }
 
 // Log records an audit entry, writes it to the file + stdout, and broadcasts
-// it to all SSE subscribers.
+// it to all SSE subscribers. The user, and the API token they used if any,
+// are taken from the request context.
 func (l *Logger) Log(r *http.Request, message string) {
+	username := auth.Username(r.Context())
+	if username == "" {
+		username = "-"
+	}
+
 	entry := Entry{
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
 		ClientIP:  clientIP(r),
+		Username:  username,
 		Method:    r.Method,
 		Path:      r.URL.Path,
 		Message:   message,
 	}
+	who := entry.Username
+	if token, ok := auth.TokenFrom(r.Context()); ok {
+		entry.Token = token.Name
+		who = fmt.Sprintf("%s[token:%s]", entry.Username, entry.Token)
+	}
 
-	line := fmt.Sprintf("[%s] %s %s %s — %s", entry.Timestamp, entry.ClientIP, entry.Method, entry.Path, entry.Message)
+	line := fmt.Sprintf("[%s] %s %s %s %s — %s", entry.Timestamp, entry.ClientIP, who, entry.Method, entry.Path, entry.Message)
 
 	// Write to file
 	l.mu.Lock()
@@ -0,0 +1,68 @@
+// Package auth carries the signed-in user, and the API token they used if
+// any, through request contexts so that handlers, templates and the audit
+// log can tell who made a request.
+package auth
+
+import (
+	"context"
+
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+type contextKey struct{}
+
+type tokenKey struct{}
+
+type csrfKey struct{}
+
+// WithUser returns a copy of ctx carrying the signed-in user.
+func WithUser(ctx context.Context, user *store.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFrom returns the signed-in user stored in ctx, if any.
+func UserFrom(ctx context.Context) (*store.User, bool) {
+	user, ok := ctx.Value(contextKey{}).(*store.User)
+	return user, ok && user != nil
+}
+
+// Username returns the name of the signed-in user, or "" for anonymous
+// requests.
+func Username(ctx context.Context) string {
+	if user, ok := UserFrom(ctx); ok {
+		return user.Username
+	}
+	return ""
+}
+
+// Role returns the role of the signed-in user, or "" for anonymous requests.
+func Role(ctx context.Context) string {
+	if user, ok := UserFrom(ctx); ok {
+		return user.Role
+	}
+	return ""
+}
+
+// WithToken returns a copy of ctx recording that the request was
+// authenticated with an API token rather than a session.
+func WithToken(ctx context.Context, token *store.APIToken) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// TokenFrom returns the API token a request was authenticated with, if any.
+func TokenFrom(ctx context.Context) (*store.APIToken, bool) {
+	token, ok := ctx.Value(tokenKey{}).(*store.APIToken)
+	return token, ok && token != nil
+}
+
+// WithCSRFToken returns a copy of ctx carrying the CSRF token of the
+// session, for templates to embed in pages and forms.
+func WithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfKey{}, token)
+}
+
+// CSRFToken returns the CSRF token stored in ctx, or "" outside a session.
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfKey{}).(string)
+	return token
+}
@@ -0,0 +1,277 @@
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	caCertFile = "ca.pem"
+	caKeyFile  = "ca-key.pem"
+
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 365 * 24 * time.Hour // browsers reject leaves valid for more than 398 days
+
+	maxCachedCerts = 256
+)
+
+// localRanges are the addresses the CA may issue certificates for: loopback
+// and private LAN ranges.
+var localRanges = []string{
+	"127.0.0.0/8", "::1/128",
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+}
+
+// Authority is a local certificate authority that issues server
+// certificates on demand for the hostname a client asks for, so the
+// manager and every proxied project hostname get a certificate browsers
+// accept once the CA is trusted. The CA is name-constrained to its domains
+// and local IP ranges, so trusting it does not let it vouch for any other
+// site, and it refuses to issue certificates outside them.
+type Authority struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	certPEM []byte
+
+	mu    sync.Mutex
+	cache map[string]*tls.Certificate
+}
+
+// LoadOrCreateCA loads the CA from dir, generating and saving a new one
+// on first use. The CA is limited to localhost, the given domains and their
+// subdomains; names an existing CA does not cover are logged, as it will
+// not issue certificates for them. The key file is readable by the owner
+// only.
+func LoadOrCreateCA(dir string, domains ...string) (*Authority, error) {
+	domains = permittedDomains(domains)
+	certPEM, certErr := os.ReadFile(filepath.Join(dir, caCertFile))
+	keyPEM, keyErr := os.ReadFile(filepath.Join(dir, caKeyFile))
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		return createCA(dir, domains)
+	}
+	if certErr != nil {
+		return nil, fmt.Errorf("read CA certificate: %w", certErr)
+	}
+	if keyErr != nil {
+		return nil, fmt.Errorf("read CA key: %w", keyErr)
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("load CA: %w", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("CA key cannot sign")
+	}
+	a := newAuthority(cert, key, certPEM)
+	for _, d := range domains {
+		if !a.permits(d) {
+			log.Printf("Warning: the CA in %s does not cover %s; remove the directory to generate a new CA and import it again", dir, d)
+		}
+	}
+	return a, nil
+}
+
+// permittedDomains normalises the domains a CA is limited to, always
+// including localhost.
+func permittedDomains(domains []string) []string {
+	out := []string{"localhost"}
+	for _, d := range domains {
+		d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d != "" && net.ParseIP(d) == nil && !slices.Contains(out, d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func createCA(dir string, domains []string) (*Authority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	var ranges []*net.IPNet
+	for _, cidr := range localRanges {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		ranges = append(ranges, ipNet)
+	}
+	host, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Odoo Manager Local CA",
+			Organization: []string{"Odoo Manager"},
+			// Tell CAs generated on different machines apart in trust stores
+			OrganizationalUnit: []string{host},
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		// Without these a trusted CA could sign for any site
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         domains,
+		PermittedIPRanges:           ranges,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create CA dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, caKeyFile), keyPEM, 0o600); err != nil {
+		return nil, fmt.Errorf("write CA key: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, caCertFile), certPEM, 0o644); err != nil {
+		return nil, fmt.Errorf("write CA certificate: %w", err)
+	}
+	return newAuthority(cert, key, certPEM), nil
+}
+
+func newAuthority(cert *x509.Certificate, key crypto.Signer, certPEM []byte) *Authority {
+	return &Authority{cert: cert, key: key, certPEM: certPEM, cache: map[string]*tls.Certificate{}}
+}
+
+// CertPEM returns the CA certificate in PEM form, for installing in
+// browsers and OS trust stores.
+func (a *Authority) CertPEM() []byte { return a.certPEM }
+
+// GetCertificate issues (or returns the cached) certificate for the SNI
+// name of a TLS handshake; clients that send none get one for localhost.
+// Names outside the CA's constraints are refused. It is meant for
+// tls.Config.GetCertificate.
+func (a *Authority) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name == "" {
+		name = "localhost"
+	}
+	if !a.permits(name) {
+		return nil, fmt.Errorf("no certificate for %s: outside %s", name, strings.Join(a.cert.PermittedDNSDomains, ", "))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.cache[name]; ok && time.Until(c.Leaf.NotAfter) > 24*time.Hour {
+		return c, nil
+	}
+	c, err := a.issue(name)
+	if err != nil {
+		return nil, err
+	}
+	// Any client can pick the name, so don't let the cache grow unbounded
+	if len(a.cache) >= maxCachedCerts {
+		clear(a.cache)
+	}
+	a.cache[name] = c
+	return c, nil
+}
+
+// permits reports whether name is an IP address in one of the CA's
+// permitted ranges, or one of its permitted domains or a subdomain of one.
+func (a *Authority) permits(name string) bool {
+	if ip := net.ParseIP(name); ip != nil {
+		for _, r := range a.cert.PermittedIPRanges {
+			if r.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range a.cert.PermittedDNSDomains {
+		if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// issue signs a server certificate for name. Certificates for localhost
+// also cover the loopback addresses.
+func (a *Authority) issue(name string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{name}
+		if name == "localhost" {
+			tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, key.Public(), a.key)
+	if err != nil {
+		return nil, fmt.Errorf("issue certificate for %s: %w", name, err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{
+		Certificate: [][]byte{der, a.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+	return serial, nil
+}
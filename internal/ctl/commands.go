@@ -0,0 +1,500 @@
+package ctl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/store"
+	"github.com/moby/term"
+)
+
+func (c *client) dispatch(args []string) error {
+	switch args[0] {
+	case "projects", "project":
+		return c.projects(args[1:])
+	case "logs":
+		return c.logs(args[1:])
+	case "backup":
+		return c.backup(args[1:])
+	case "restore":
+		return c.restore(args[1:])
+	case "modules":
+		if len(args) < 2 || args[1] != "upgrade" {
+			return usageError("usage: modules upgrade PROJECT [--db NAME] MODULE...")
+		}
+		return c.upgradeModules(args[2:])
+	case "shell":
+		return c.shell(args[1:])
+	}
+	return usageError(fmt.Sprintf("unknown command %q", args[0]))
+}
+
+func (c *client) projects(args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: projects list|create|start|stop|delete")
+	}
+	switch args[0] {
+	case "list", "ls":
+		return c.listProjects(args[1:])
+	case "create":
+		return c.createProject(args[1:])
+	case "start", "stop":
+		return c.startStopProjects(args[0], args[1:])
+	case "delete", "rm":
+		return c.deleteProjects(args[1:])
+	}
+	return usageError(fmt.Sprintf("unknown projects command %q", args[0]))
+}
+
+func (c *client) listProjects(args []string) error {
+	fs := flag.NewFlagSet("projects list", flag.ContinueOnError)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var projects []*store.Project
+	if err := c.call(http.MethodGet, "/projects", nil, &projects); err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return printJSON(projects)
+	}
+	c.printProjects(projects)
+	return nil
+}
+
+// printProjects prints projects as a table.
+func (c *client) printProjects(projects []*store.Project) {
+	rows := make([][]string, 0, len(projects))
+	for _, p := range projects {
+		addons := "-"
+		switch {
+		case p.LocalAddonsPath != "":
+			addons = p.LocalAddonsPath
+		case p.GitRepoURL != "" && p.GitRepoBranch != "":
+			addons = p.GitRepoURL + "@" + p.GitRepoBranch
+		case p.GitRepoURL != "":
+			addons = p.GitRepoURL
+		}
+		rows = append(rows, []string{p.Name, p.Status, p.OdooVersion, p.PostgresVersion,
+			fmt.Sprintf("%s:%d", p.BindAddress, p.Port), addons, p.ID})
+	}
+	printTable([]string{"NAME", "STATUS", "ODOO", "POSTGRES", "ADDRESS", "ADDONS", "ID"}, rows)
+}
+
+func (c *client) createProject(args []string) error {
+	var p store.Project
+	fs := flag.NewFlagSet("projects create", flag.ContinueOnError)
+	fs.StringVar(&p.Name, "name", "", "project name (required)")
+	fs.StringVar(&p.Description, "description", "", "description")
+	fs.StringVar(&p.OdooVersion, "odoo-version", "18.0", "Odoo version")
+	fs.StringVar(&p.PostgresVersion, "postgres-version", "16", "PostgreSQL version")
+	fs.IntVar(&p.Port, "port", 0, "HTTP port, 0 for the next free one in the port range")
+	fs.StringVar(&p.BindAddress, "bind-address", "", "host IP to publish the ports on (default: the bind_address setting)")
+	fs.StringVar(&p.GitRepoURL, "repo", "", "addons git repository URL")
+	fs.StringVar(&p.GitRepoBranch, "branch", "", "branch, tag or commit of --repo")
+	fs.StringVar(&p.LocalAddonsPath, "addons-path", "", "host folder mounted as addons instead of --repo")
+	fs.BoolVar(&p.EnterpriseEnabled, "enterprise", false, "add Odoo Enterprise (needs a GitHub token with access)")
+	fs.BoolVar(&p.DesignThemesEnabled, "design-themes", false, "add the Odoo design themes")
+	wait := fs.Bool("wait", false, "wait until the containers are created")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if p.Name == "" {
+		return usageError("projects create needs --name")
+	}
+
+	var created store.Project
+	if err := c.call(http.MethodPost, "/projects", &p, &created); err != nil {
+		return err
+	}
+	project := &created
+	if *wait {
+		var err error
+		if project, err = c.waitForStatus(project, func(s string) bool { return s != "creating" }); err != nil {
+			return err
+		}
+	}
+	if c.output == "json" {
+		return printJSON(project)
+	}
+	c.printProjects([]*store.Project{project})
+	return nil
+}
+
+func (c *client) startStopProjects(action string, args []string) error {
+	target, verb := "running", "Starting"
+	if action == "stop" {
+		target, verb = "stopped", "Stopping"
+	}
+	fs := flag.NewFlagSet("projects "+action, flag.ContinueOnError)
+	wait := fs.Bool("wait", false, "wait until the project is "+target)
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return usageError(fmt.Sprintf("usage: projects %s PROJECT... [--wait]", action))
+	}
+
+	var results []*store.Project
+	for _, ref := range refs {
+		project, err := c.resolveProject(ref)
+		if err != nil {
+			return err
+		}
+		var updated store.Project
+		if err := c.call(http.MethodPost, projectPath(project.ID, "/"+action), nil, &updated); err != nil {
+			return fmt.Errorf("%s: %w", project.Name, err)
+		}
+		result := &updated
+		if *wait {
+			if result, err = c.waitForStatus(result, func(s string) bool { return s == target || s == "error" }); err != nil {
+				return err
+			}
+			if result.Status == "error" {
+				return fmt.Errorf("%s: failed to %s, see its logs", project.Name, action)
+			}
+		}
+		results = append(results, result)
+		if c.output == "table" && !*wait {
+			fmt.Printf("%s %s\n", verb, project.Name)
+		}
+	}
+	switch {
+	case c.output == "json":
+		return printJSON(results)
+	case *wait:
+		c.printProjects(results)
+	}
+	return nil
+}
+
+func (c *client) deleteProjects(args []string) error {
+	fs := flag.NewFlagSet("projects delete", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.BoolVar(yes, "y", false, "shorthand for --yes")
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return usageError("usage: projects delete PROJECT... [--yes]")
+	}
+
+	type deleted struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	}
+	var results []deleted
+	for _, ref := range refs {
+		project, err := c.resolveProject(ref)
+		if err != nil {
+			return err
+		}
+		if !*yes {
+			if err := confirm(fmt.Sprintf("Delete project %s with its containers, databases and filestore?", project.Name)); err != nil {
+				return err
+			}
+		}
+		if err := c.call(http.MethodDelete, projectPath(project.ID), nil, nil); err != nil {
+			return fmt.Errorf("%s: %w", project.Name, err)
+		}
+		results = append(results, deleted{project.ID, project.Name, "deleting"})
+		if c.output == "table" {
+			fmt.Printf("Deleting %s\n", project.Name)
+		}
+	}
+	if c.output == "json" {
+		return printJSON(results)
+	}
+	return nil
+}
+
+func (c *client) logs(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := fs.Bool("f", false, "follow new lines")
+	tail := fs.String("tail", "100", "number of recent lines to print, or all")
+	containerType := fs.String("container", "odoo", "container: odoo or postgres")
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) != 1 {
+		return usageError("usage: logs PROJECT [-f] [--tail N] [--container odoo|postgres]")
+	}
+	project, err := c.resolveProject(refs[0])
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{"container": {*containerType}, "tail": {*tail}, "follow": {strconv.FormatBool(*follow)}}
+	req, err := c.newRequest(http.MethodGet, projectPath(project.ID, "/logs?", q.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.stream(req, func(line string) {
+		if c.output == "json" {
+			printJSON(map[string]string{"project": project.Name, "container": *containerType, "line": line})
+			return
+		}
+		fmt.Println(line)
+	})
+	return err
+}
+
+func (c *client) backup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	db := fs.String("db", "", "database to back up")
+	out := fs.String("out", ".", "file or directory to save the zip to")
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) != 1 {
+		return usageError("usage: backup PROJECT [--db NAME] [--out PATH]")
+	}
+	project, err := c.resolveProject(refs[0])
+	if err != nil {
+		return err
+	}
+	database, err := c.resolveDatabase(project, *db)
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(http.MethodPost, projectPath(project.ID, "/backups?", url.Values{"db": {database}}.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	download, err := c.stream(req, progress)
+	if err != nil {
+		return err
+	}
+
+	// The complete event holds the download path, below /api/v1
+	req, err = c.newRequest(http.MethodGet, strings.TrimPrefix(download, "/api/v1"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dest := *out
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		name, _ := url.PathUnescape(path.Base(download))
+		dest = filepath.Join(dest, filepath.Base(name))
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	size, err := f.ReadFrom(resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("failed to save backup: %w", err)
+	}
+
+	if c.output == "json" {
+		return printJSON(map[string]any{"project": project.Name, "database": database, "file": dest, "size": size})
+	}
+	fmt.Printf("Saved backup of %s/%s to %s (%.1f MB)\n", project.Name, database, dest, float64(size)/(1<<20))
+	return nil
+}
+
+func (c *client) restore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	db := fs.String("db", "", "database to restore into; it is replaced")
+	neutralize := fs.Bool("neutralize", false, "disable outgoing mail servers and scheduled actions in the copy")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.BoolVar(yes, "y", false, "shorthand for --yes")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageError("usage: restore PROJECT FILE [--db NAME] [--neutralize] [--yes]")
+	}
+	project, err := c.resolveProject(positional[0])
+	if err != nil {
+		return err
+	}
+	database, err := c.resolveDatabase(project, *db)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		if err := confirm(fmt.Sprintf("Replace database %s of %s with %s?", database, project.Name, positional[1])); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(positional[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{"db": {database}, "neutralize": {strconv.FormatBool(*neutralize)}}
+	req, err := c.newRequest(http.MethodPost, projectPath(project.ID, "/restore?", q.Encode()), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/zip")
+	message, err := c.stream(req, progress)
+	if err != nil {
+		return err
+	}
+	return c.printDone(project, database, message)
+}
+
+func (c *client) upgradeModules(args []string) error {
+	fs := flag.NewFlagSet("modules upgrade", flag.ContinueOnError)
+	db := fs.String("db", "", "database whose modules to upgrade")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 2 {
+		return usageError("usage: modules upgrade PROJECT [--db NAME] MODULE...")
+	}
+	project, err := c.resolveProject(positional[0])
+	if err != nil {
+		return err
+	}
+	database, err := c.resolveDatabase(project, *db)
+	if err != nil {
+		return err
+	}
+
+	var modules []string
+	for _, arg := range positional[1:] {
+		for _, m := range strings.Split(arg, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				modules = append(modules, m)
+			}
+		}
+	}
+	body, err := json.Marshal(map[string]any{"database": database, "modules": modules})
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(http.MethodPost, projectPath(project.ID, "/modules/upgrade"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	message, err := c.stream(req, progress)
+	if err != nil {
+		return err
+	}
+	return c.printDone(project, database, message)
+}
+
+// printDone reports a finished database job.
+func (c *client) printDone(project *store.Project, database, message string) error {
+	if c.output == "json" {
+		return printJSON(map[string]string{"project": project.Name, "database": database, "status": "ok", "message": message})
+	}
+	fmt.Println(message)
+	return nil
+}
+
+// progress prints the output of a running job on stderr, keeping stdout
+// for the result.
+func progress(line string) {
+	fmt.Fprintln(os.Stderr, line)
+}
+
+// resolveProject finds a project by ID or name.
+func (c *client) resolveProject(ref string) (*store.Project, error) {
+	var projects []*store.Project
+	if err := c.call(http.MethodGet, "/projects", nil, &projects); err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if p.ID == ref {
+			return p, nil
+		}
+	}
+	for _, p := range projects {
+		if p.Name == ref {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("no project named %q", ref)
+}
+
+// resolveDatabase returns db, or the project's only database when db is
+// empty.
+func (c *client) resolveDatabase(project *store.Project, db string) (string, error) {
+	if db != "" {
+		return db, nil
+	}
+	var databases []string
+	if err := c.call(http.MethodGet, projectPath(project.ID, "/databases"), nil, &databases); err != nil {
+		return "", fmt.Errorf("cannot list the databases of %s, pass --db: %w", project.Name, err)
+	}
+	switch len(databases) {
+	case 1:
+		return databases[0], nil
+	case 0:
+		return "", fmt.Errorf("%s has no database yet, pass --db", project.Name)
+	}
+	return "", fmt.Errorf("%s has several databases (%s), choose one with --db", project.Name, strings.Join(databases, ", "))
+}
+
+// waitForStatus polls a project until done accepts its status. The status
+// returned with an action is the one before it, so it polls at least once.
+func (c *client) waitForStatus(project *store.Project, done func(string) bool) (*store.Project, error) {
+	deadline := time.Now().Add(15 * time.Minute)
+	for {
+		time.Sleep(2 * time.Second)
+		var current store.Project
+		if err := c.call(http.MethodGet, projectPath(project.ID), nil, &current); err != nil {
+			return nil, err
+		}
+		if done(current.Status) {
+			return &current, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: still %s after 15 minutes", current.Name, current.Status)
+		}
+	}
+}
+
+// confirm asks a yes/no question on the terminal. Without one it refuses,
+// so scripts must pass --yes.
+func confirm(question string) error {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return usageError("refusing to continue without confirmation, pass --yes")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return fmt.Errorf("cancelled")
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+// Package ctl implements "odoo-manager ctl", a command-line client that
+// drives a running manager over its versioned HTTP API (/api/v1) with an
+// API token.
+package ctl
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `Usage: odoo-manager ctl [options] <command> [arguments]
+
+Drives a running Odoo Manager over its API. Create an API token under
+Configuration → API Tokens and pass it with --token or ODOO_MANAGER_TOKEN.
+
+Commands:
+  projects list                          List projects
+  projects create --name NAME [...]      Create a project (see projects create -h)
+  projects start PROJECT...              Start projects
+  projects stop PROJECT...               Stop projects
+  projects delete PROJECT... [--yes]     Delete projects with their containers and data
+  logs PROJECT [-f] [--tail N] [--container odoo|postgres]
+                                         Print (and follow) a container's logs
+  backup PROJECT [--db NAME] [--out PATH]
+                                         Back up a database and download the zip
+  restore PROJECT FILE [--db NAME] [--neutralize]
+                                         Restore a backup zip, replacing the database
+  modules upgrade PROJECT [--db NAME] MODULE...
+                                         Upgrade modules ("all" for every module)
+  shell PROJECT [--db NAME]              Open an interactive odoo shell
+
+PROJECT is a project name or ID. --db may be left out when the project has
+a single database.
+
+Options:
+`
+
+// Run runs the ctl command with args (everything after "ctl") and returns
+// the process exit code.
+func Run(args []string) int {
+	c := &client{}
+	fs := flag.NewFlagSet("odoo-manager ctl", flag.ContinueOnError)
+	fs.StringVar(&c.baseURL, "url", envOr("ODOO_MANAGER_URL", "http://localhost:8080"), "manager URL (ODOO_MANAGER_URL)")
+	fs.StringVar(&c.token, "token", os.Getenv("ODOO_MANAGER_TOKEN"), "API token (ODOO_MANAGER_TOKEN)")
+	fs.StringVar(&c.output, "o", envOr("ODOO_MANAGER_OUTPUT", "table"), "output format: table or json (ODOO_MANAGER_OUTPUT)")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "odoo-manager ctl: -o must be table or json, not %q\n", c.output)
+		return 2
+	}
+	if c.token == "" {
+		fmt.Fprintln(os.Stderr, "odoo-manager ctl: an API token is required, pass --token or set ODOO_MANAGER_TOKEN")
+		return 2
+	}
+	c.baseURL = strings.TrimSuffix(c.baseURL, "/")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	c.http = &http.Client{Transport: transport}
+
+	err := c.dispatch(fs.Args())
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "odoo-manager ctl: %v\nRun \"odoo-manager ctl -h\" for usage.\n", err)
+		return 2
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "odoo-manager ctl: %v\n", err)
+		return 1
+	}
+}
+
+// usageError is a mistake in the command line rather than a failure.
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// parseFlags parses fs from args, allowing flags after positional
+// arguments, and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// client calls the manager's API.
+type client struct {
+	baseURL string
+	token   string
+	output  string
+	http    *http.Client
+}
+
+// apiError is the error envelope of /api/v1.
+type apiError struct {
+	Error struct {
+		Code    string         `json:"code"`
+		Message string         `json:"message"`
+		Details map[string]any `json:"details"`
+	} `json:"error"`
+}
+
+// newRequest returns an authenticated request for an /api/v1 path.
+func (c *client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.baseURL+"/api/v1"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return req, nil
+}
+
+// do sends req and returns the response when it succeeded, or the message
+// of the error envelope.
+func (c *client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	var e apiError
+	if json.Unmarshal(data, &e) == nil && e.Error.Message != "" {
+		return nil, errors.New(e.Error.Message)
+	}
+	return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+}
+
+// call sends a JSON request and decodes the JSON response into out, which
+// may be nil.
+func (c *client) call(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(string(data))
+	}
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stream sends req and calls onData for every data line of the returned
+// event stream. A "complete" event ends it successfully with its data, an
+// "error" event with an error; otherwise it ends with the response.
+func (c *client) stream(req *http.Request, onData func(string)) (string, error) {
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	event := ""
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "":
+			msg := strings.Join(data, "\n")
+			switch event {
+			case "complete":
+				return msg, nil
+			case "error":
+				return "", errors.New(msg)
+			case "":
+				if data != nil {
+					onData(msg)
+				}
+			}
+			event, data = "", nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// projectPath escapes a project ID into an /api/v1 path.
+func projectPath(id string, rest ...string) string {
+	return "/projects/" + url.PathEscape(id) + strings.Join(rest, "")
+}
+
+// printJSON writes v as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTable writes rows under header, aligned in columns.
+func printTable(header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,90 @@
+package ctl
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/moby/term"
+)
+
+// shellProtocol is the Upgrade token the manager expects for shells.
+const shellProtocol = "odoo-shell"
+
+// shell opens "odoo shell" on a project's database and connects it to the
+// terminal, which is put in raw mode so keys such as Tab and Ctrl-C reach
+// the remote shell. Exit with Ctrl-D or exit().
+func (c *client) shell(args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ContinueOnError)
+	db := fs.String("db", "", "database to open the shell on")
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) != 1 {
+		return usageError("usage: shell PROJECT [--db NAME]")
+	}
+	project, err := c.resolveProject(refs[0])
+	if err != nil {
+		return err
+	}
+	database, err := c.resolveDatabase(project, *db)
+	if err != nil {
+		return err
+	}
+
+	q := url.Values{"db": {database}}
+	inFd, inTerm := term.GetFdInfo(os.Stdin)
+	if size, err := term.GetWinsize(inFd); inTerm && err == nil {
+		q.Set("rows", strconv.Itoa(int(size.Height)))
+		q.Set("cols", strconv.Itoa(int(size.Width)))
+	}
+	req, err := c.newRequest(http.MethodPost, projectPath(project.ID, "/shell?", q.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", shellProtocol)
+
+	// Switching protocols needs HTTP/1.1, also over TLS
+	transport := c.http.Transport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	shellClient := *c
+	shellClient.http = &http.Client{Transport: transport}
+	resp, err := shellClient.do(req)
+	if err != nil {
+		return err
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		resp.Body.Close()
+		return fmt.Errorf("the manager did not open a shell (%s)", resp.Status)
+	}
+	defer conn.Close()
+
+	if inTerm {
+		state, err := term.MakeRaw(inFd)
+		if err != nil {
+			return err
+		}
+		defer term.RestoreTerminal(inFd, state)
+	}
+	go func() {
+		io.Copy(conn, os.Stdin)
+		if !inTerm {
+			// Piped input ended: end the remote shell like Ctrl-D would
+			conn.Write([]byte{4})
+		}
+	}()
+	if _, err := io.Copy(os.Stdout, conn); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return err
+	}
+	return nil
+}
@@ -10,10 +10,12 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
@@ -27,16 +29,139 @@ type Manager struct {
 
 // odooEntrypoint returns the custom entrypoint that pip-installs a
 // requirements.txt from the addons directory (if present) before
-// handing off to the stock Odoo entrypoint.
+// handing off to the stock Odoo entrypoint. With ODOO_DEBUGPY set it also
+// installs debugpy and shadows the odoo binary with a wrapper that runs it
+// under debugpy listening on port 5678.
 func odooEntrypoint() []string {
 	return []string{"/bin/bash", "-c",
-		`if [ -f /mnt/extra-addons/requirements.txt ]; then pip3 install --no-cache-dir --break-system-packages -r /mnt/extra-addons/requirements.txt; fi; exec /entrypoint.sh "$@"`,
+		`if [ -f /mnt/extra-addons/requirements.txt ]; then pip3 install --no-cache-dir --break-system-packages -r /mnt/extra-addons/requirements.txt; fi; ` +
+			`if [ -n "$ODOO_DEBUGPY" ] && pip3 install --no-cache-dir --break-system-packages debugpy; then mkdir -p /tmp/debugpy && printf '#!/bin/sh\nexec python3 -m debugpy --listen 0.0.0.0:5678 %s "$@"\n' "$(command -v odoo)" > /tmp/debugpy/odoo && chmod +x /tmp/debugpy/odoo && export PATH=/tmp/debugpy:$PATH; fi; ` +
+			`exec /entrypoint.sh "$@"`,
 		"--"}
 }
 
 // odooCmd returns the default command passed to the entrypoint.
 func odooCmd() []string { return []string{"odoo"} }
 
+// Container ports of a project. Odoo's HTTP port is always published; the
+// others only when the project assigns them a host port.
+const (
+	odooHTTPPort    nat.Port = "8069/tcp"
+	odooGeventPort  nat.Port = "8072/tcp" // longpolling / gevent (bus, live chat, Discuss)
+	odooDebugpyPort nat.Port = "5678/tcp"
+	postgresPort    nat.Port = "5432/tcp"
+)
+
+// publishPort exposes containerPort and binds it to hostPort on hostIP. A
+// zero hostPort leaves the port unpublished.
+func publishPort(exposed nat.PortSet, bindings nat.PortMap, containerPort nat.Port, hostIP string, hostPort int) {
+	if hostPort == 0 {
+		return
+	}
+	exposed[containerPort] = struct{}{}
+	bindings[containerPort] = []nat.PortBinding{{HostIP: hostIP, HostPort: strconv.Itoa(hostPort)}}
+}
+
+// bindAddress returns the host IP a project's ports are published on.
+// Projects without one are published only on this machine.
+func bindAddress(project *store.Project) string {
+	if project.BindAddress == "" {
+		return "127.0.0.1"
+	}
+	return project.BindAddress
+}
+
+// odooPorts returns the exposed ports and bindings of a project's Odoo
+// container.
+func odooPorts(project *store.Project) (nat.PortSet, nat.PortMap) {
+	exposed, bindings := nat.PortSet{}, nat.PortMap{}
+	hostIP := bindAddress(project)
+	publishPort(exposed, bindings, odooHTTPPort, hostIP, project.Port)
+	publishPort(exposed, bindings, odooGeventPort, hostIP, project.GeventPort)
+	publishPort(exposed, bindings, odooDebugpyPort, hostIP, project.DebugPort)
+	return exposed, bindings
+}
+
+// postgresPorts returns the exposed ports and bindings of a project's
+// Postgres container.
+func postgresPorts(project *store.Project) (nat.PortSet, nat.PortMap) {
+	exposed, bindings := nat.PortSet{}, nat.PortMap{}
+	publishPort(exposed, bindings, postgresPort, bindAddress(project), project.DBPort)
+	return exposed, bindings
+}
+
+// postgresEnv returns the environment of a project's Postgres container.
+// The credentials only take effect when the data directory is initialised.
+func postgresEnv(project *store.Project) []string {
+	return []string{
+		"POSTGRES_DB=postgres",
+		"POSTGRES_USER=" + project.DBUser,
+		"POSTGRES_PASSWORD=" + project.DBPassword,
+	}
+}
+
+// odooEnv returns the environment of a project's Odoo container.
+func odooEnv(project *store.Project) []string {
+	env := []string{
+		"HOST=postgres",
+		"USER=" + project.DBUser,
+		"PASSWORD=" + project.DBPassword,
+	}
+	if project.DebugPort != 0 {
+		env = append(env, "ODOO_DEBUGPY=1")
+	}
+	return env
+}
+
+// projectNetwork returns the name of a project's private bridge network,
+// e.g. odoo-manager-{projectID}.
+func projectNetwork(projectID string) string {
+	return "odoo-manager-" + projectID
+}
+
+// projectNetworking returns the network mode and endpoint settings that
+// attach a container to its project's network, reachable under aliases.
+func projectNetworking(projectID string, aliases ...string) (container.NetworkMode, *network.NetworkingConfig) {
+	name := projectNetwork(projectID)
+	return container.NetworkMode(name), &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			name: {Aliases: aliases},
+		},
+	}
+}
+
+// ensureProjectNetwork creates a project's network if it does not exist yet
+// and attaches the project's Postgres container to it under the "postgres"
+// alias. Postgres containers created before per-project networks only sit
+// on the default bridge, reached through a legacy link.
+func (m *Manager) ensureProjectNetwork(ctx context.Context, projectID string) error {
+	name := projectNetwork(projectID)
+	if _, err := m.cli.NetworkInspect(ctx, name, network.InspectOptions{}); err != nil {
+		if !client.IsErrNotFound(err) {
+			return fmt.Errorf("inspect project network: %w", err)
+		}
+		if _, err := m.cli.NetworkCreate(ctx, name, network.CreateOptions{
+			Driver: "bridge",
+			Labels: projectLabels(projectID, "network"),
+		}); err != nil {
+			return fmt.Errorf("create project network: %w", err)
+		}
+	}
+
+	postgresName := fmt.Sprintf("postgres-%s", projectID)
+	info, err := m.cli.ContainerInspect(ctx, postgresName)
+	if err != nil || info.NetworkSettings == nil {
+		return nil
+	}
+	if _, ok := info.NetworkSettings.Networks[name]; ok {
+		return nil
+	}
+	if err := m.cli.NetworkConnect(ctx, name, postgresName, &network.EndpointSettings{Aliases: []string{"postgres"}}); err != nil {
+		return fmt.Errorf("connect postgres to project network: %w", err)
+	}
+	return nil
+}
+
 // configDir returns the local host directory for a project's odoo.conf.
 // e.g. data/config/{projectID}
 func configDir(projectID string) string {
@@ -95,44 +220,42 @@ func postgresImage(odooVersion string, pgVersion string) string {
 // /mnt/enterprise-addons. designThemesHostDir is the absolute path to bind-mount at
 // /mnt/design-themes. Pass empty string for any to skip.
 func (m *Manager) CreateProject(ctx context.Context, project *store.Project, addonsHostDir, enterpriseHostDir, designThemesHostDir string) error {
+	if err := m.ensureProjectNetwork(ctx, project.ID); err != nil {
+		return err
+	}
+
 	// Create Postgres container
 	postgresContainerName := fmt.Sprintf("postgres-%s", project.ID)
+	postgresExposed, postgresBindings := postgresPorts(project)
 	postgresConfig := &container.Config{
-		Image: postgresImage(project.OdooVersion, project.PostgresVersion),
-		Env: []string{
-			"POSTGRES_DB=postgres",
-			"POSTGRES_USER=odoo",
-			"POSTGRES_PASSWORD=odoo",
-		},
-		Labels: projectLabels(project.ID, "postgres"),
+		Image:        postgresImage(project.OdooVersion, project.PostgresVersion),
+		Env:          postgresEnv(project),
+		ExposedPorts: postgresExposed,
+		Labels:       projectLabels(project.ID, "postgres"),
 	}
-	postgresHostConfig := &container.HostConfig{}
+	postgresNetworkMode, postgresNetworking := projectNetworking(project.ID, "postgres")
+	postgresHostConfig := &container.HostConfig{NetworkMode: postgresNetworkMode, PortBindings: postgresBindings}
 
 	if !m.containerExists(ctx, postgresContainerName) {
 		if err := m.pullImage(ctx, postgresConfig.Image); err != nil {
 			return fmt.Errorf("failed to pull postgres image: %w", err)
 		}
-		if _, err := m.cli.ContainerCreate(ctx, postgresConfig, postgresHostConfig, nil, nil, postgresContainerName); err != nil {
+		if _, err := m.cli.ContainerCreate(ctx, postgresConfig, postgresHostConfig, postgresNetworking, nil, postgresContainerName); err != nil {
 			return fmt.Errorf("failed to create postgres container: %w", err)
 		}
 	}
 
 	// Create Odoo container
 	odooContainerName := fmt.Sprintf("odoo-%s", project.ID)
+	odooExposed, odooBindings := odooPorts(project)
 	odooConfig := &container.Config{
-		Image: fmt.Sprintf("odoo:%s", project.OdooVersion),
-		Env: []string{
-			"HOST=postgres",
-			"USER=odoo",
-			"PASSWORD=odoo",
-		},
-		ExposedPorts: nat.PortSet{
-			"8069/tcp": struct{}{},
-		},
-		Entrypoint: odooEntrypoint(),
-		Cmd:        odooCmd(),
-		Tty:        true, // enable TTY so Odoo outputs ANSI colors in logs
-		Labels:     projectLabels(project.ID, "odoo"),
+		Image:        fmt.Sprintf("odoo:%s", project.OdooVersion),
+		Env:          odooEnv(project),
+		ExposedPorts: odooExposed,
+		Entrypoint:   odooEntrypoint(),
+		Cmd:          odooCmd(),
+		Tty:          true, // enable TTY so Odoo outputs ANSI colors in logs
+		Labels:       projectLabels(project.ID, "odoo"),
 	}
 
 	// Write odoo.conf to local host directory before creating the container
@@ -161,21 +284,18 @@ func (m *Manager) CreateProject(ctx context.Context, project *store.Project, add
 	if designThemesHostDir != "" {
 		binds = append(binds, fmt.Sprintf("%s:/mnt/design-themes", designThemesHostDir))
 	}
+	odooNetworkMode, odooNetworking := projectNetworking(project.ID)
 	odooHostConfig := &container.HostConfig{
-		Links: []string{fmt.Sprintf("%s:postgres", postgresContainerName)},
-		PortBindings: nat.PortMap{
-			"8069/tcp": []nat.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: fmt.Sprintf("%d", project.Port)},
-			},
-		},
-		Binds: binds,
+		NetworkMode:  odooNetworkMode,
+		PortBindings: odooBindings,
+		Binds:        binds,
 	}
 
 	if !m.containerExists(ctx, odooContainerName) {
 		if err := m.pullImage(ctx, odooConfig.Image); err != nil {
 			return fmt.Errorf("failed to pull odoo image: %w", err)
 		}
-		if _, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, nil, nil, odooContainerName); err != nil {
+		if _, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, odooNetworking, nil, odooContainerName); err != nil {
 			return fmt.Errorf("failed to create odoo container: %w", err)
 		}
 	}
@@ -215,19 +335,22 @@ data_dir = /var/lib/odoo
 // enterpriseHostDir is the absolute path to bind-mount at /mnt/enterprise-addons.
 // designThemesHostDir is the absolute path to bind-mount at /mnt/design-themes.
 func (m *Manager) StartProject(ctx context.Context, project *store.Project, addonsHostDir, enterpriseHostDir, designThemesHostDir string) error {
+	if err := m.ensureProjectNetwork(ctx, project.ID); err != nil {
+		return err
+	}
+
 	// Start Postgres container first
 	postgresContainerName := fmt.Sprintf("postgres-%s", project.ID)
+	postgresExposed, postgresBindings := postgresPorts(project)
 	postgresConfig := &container.Config{
-		Image: postgresImage(project.OdooVersion, project.PostgresVersion),
-		Env: []string{
-			"POSTGRES_DB=postgres",
-			"POSTGRES_USER=odoo",
-			"POSTGRES_PASSWORD=odoo",
-		},
-		Labels: projectLabels(project.ID, "postgres"),
+		Image:        postgresImage(project.OdooVersion, project.PostgresVersion),
+		Env:          postgresEnv(project),
+		ExposedPorts: postgresExposed,
+		Labels:       projectLabels(project.ID, "postgres"),
 	}
 
-	postgresHostConfig := &container.HostConfig{}
+	postgresNetworkMode, postgresNetworking := projectNetworking(project.ID, "postgres")
+	postgresHostConfig := &container.HostConfig{NetworkMode: postgresNetworkMode, PortBindings: postgresBindings}
 
 	// Check if postgres container exists
 	postgresExists := m.containerExists(ctx, postgresContainerName)
@@ -238,7 +361,7 @@ func (m *Manager) StartProject(ctx context.Context, project *store.Project, addo
 		}
 
 		// Create postgres container
-		_, err := m.cli.ContainerCreate(ctx, postgresConfig, postgresHostConfig, nil, nil, postgresContainerName)
+		_, err := m.cli.ContainerCreate(ctx, postgresConfig, postgresHostConfig, postgresNetworking, nil, postgresContainerName)
 		if err != nil {
 			return fmt.Errorf("failed to create postgres container: %w", err)
 		}
@@ -251,20 +374,15 @@ func (m *Manager) StartProject(ctx context.Context, project *store.Project, addo
 
 	// Start Odoo container
 	odooContainerName := fmt.Sprintf("odoo-%s", project.ID)
+	odooExposed, odooBindings := odooPorts(project)
 	odooConfig := &container.Config{
-		Image: fmt.Sprintf("odoo:%s", project.OdooVersion),
-		Env: []string{
-			"HOST=postgres",
-			"USER=odoo",
-			"PASSWORD=odoo",
-		},
-		ExposedPorts: nat.PortSet{
-			"8069/tcp": struct{}{},
-		},
-		Entrypoint: odooEntrypoint(),
-		Cmd:        odooCmd(),
-		Tty:        true, // enable TTY so Odoo outputs ANSI colors in logs
-		Labels:     projectLabels(project.ID, "odoo"),
+		Image:        fmt.Sprintf("odoo:%s", project.OdooVersion),
+		Env:          odooEnv(project),
+		ExposedPorts: odooExposed,
+		Entrypoint:   odooEntrypoint(),
+		Cmd:          odooCmd(),
+		Tty:          true, // enable TTY so Odoo outputs ANSI colors in logs
+		Labels:       projectLabels(project.ID, "odoo"),
 	}
 
 	absConfDir, _ := filepath.Abs(configDir(project.ID))
@@ -280,18 +398,29 @@ func (m *Manager) StartProject(ctx context.Context, project *store.Project, addo
 	if designThemesHostDir != "" {
 		binds = append(binds, fmt.Sprintf("%s:/mnt/design-themes", designThemesHostDir))
 	}
+
+	// Odoo containers created before per-project networks reach Postgres
+	// through a legacy link; replace them with one on the project network,
+	// keeping the data volume.
+	existing, err := m.cli.ContainerInspect(ctx, odooContainerName)
+	odooExists := err == nil
+	if odooExists && existing.HostConfig != nil && len(existing.HostConfig.Links) > 0 {
+		if dataVolumeName := odooDataVolume(existing.Mounts); dataVolumeName != "" {
+			binds = append(binds, fmt.Sprintf("%s:/var/lib/odoo", dataVolumeName))
+		}
+		if err := m.cli.ContainerRemove(ctx, odooContainerName, container.RemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("failed to remove linked odoo container: %w", err)
+		}
+		odooExists = false
+	}
+
+	odooNetworkMode, odooNetworking := projectNetworking(project.ID)
 	odooHostConfig := &container.HostConfig{
-		Links: []string{fmt.Sprintf("%s:postgres", postgresContainerName)},
-		PortBindings: nat.PortMap{
-			"8069/tcp": []nat.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: fmt.Sprintf("%d", project.Port)},
-			},
-		},
-		Binds: binds,
+		NetworkMode:  odooNetworkMode,
+		PortBindings: odooBindings,
+		Binds:        binds,
 	}
 
-	// Check if odoo container exists
-	odooExists := m.containerExists(ctx, odooContainerName)
 	if !odooExists {
 		// Pull odoo image if not exists
 		if err := m.pullImage(ctx, odooConfig.Image); err != nil {
@@ -299,7 +428,7 @@ func (m *Manager) StartProject(ctx context.Context, project *store.Project, addo
 		}
 
 		// Create odoo container
-		_, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, nil, nil, odooContainerName)
+		_, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, odooNetworking, nil, odooContainerName)
 		if err != nil {
 			return fmt.Errorf("failed to create odoo container: %w", err)
 		}
@@ -340,10 +469,11 @@ func (m *Manager) StopProject(ctx context.Context, project *store.Project) error
 	return firstErr
 }
 
-// GetLogs streams logs from a container. The returned boolean indicates
-// whether the container has a TTY (raw stream) or not (multiplexed stream
-// that must be demuxed with stdcopy).
-func (m *Manager) GetLogs(ctx context.Context, projectID string, containerType string) (io.ReadCloser, bool, error) {
+// GetLogs streams the last tail lines ("all" for everything) of a
+// container's logs, and new ones as they come when follow is set. The
+// returned boolean indicates whether the container has a TTY (raw stream)
+// or not (multiplexed stream that must be demuxed with stdcopy).
+func (m *Manager) GetLogs(ctx context.Context, projectID string, containerType string, follow bool, tail string) (io.ReadCloser, bool, error) {
 	containerName := fmt.Sprintf("%s-%s", containerType, projectID)
 
 	// Inspect the container to check if it has a TTY
@@ -356,8 +486,8 @@ func (m *Manager) GetLogs(ctx context.Context, projectID string, containerType s
 	options := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Follow:     true,
-		Tail:       "100",
+		Follow:     follow,
+		Tail:       tail,
 	}
 
 	logs, err := m.cli.ContainerLogs(ctx, containerName, options)
@@ -404,7 +534,7 @@ func (m *Manager) ReconcileStatus(ctx context.Context, project *store.Project) s
 	return status
 }
 
-// RemoveProject removes containers for a project
+// RemoveProject removes containers and the network for a project
 func (m *Manager) RemoveProject(ctx context.Context, project *store.Project) error {
 	odooContainerName := fmt.Sprintf("odoo-%s", project.ID)
 	postgresContainerName := fmt.Sprintf("postgres-%s", project.ID)
@@ -430,6 +560,13 @@ func (m *Manager) RemoveProject(ctx context.Context, project *store.Project) err
 		}
 	}
 
+	// Remove the project network once no container is attached to it
+	if err := m.cli.NetworkRemove(ctx, projectNetwork(project.ID)); err != nil {
+		if !client.IsErrNotFound(err) && firstErr == nil {
+			firstErr = fmt.Errorf("failed to remove project network: %w", err)
+		}
+	}
+
 	// Remove local config directory
 	if err := os.RemoveAll(configDir(project.ID)); err != nil {
 		log.Printf("Warning: failed to remove config dir for project %s: %v", project.ID, err)
@@ -439,13 +576,12 @@ func (m *Manager) RemoveProject(ctx context.Context, project *store.Project) err
 }
 
 // RecreateOdooContainer removes the existing Odoo container and creates a new
-// one with updated bind mounts. The container is restored to its previous
-// state (running → restarted, stopped → kept stopped). If the container does
-// not exist yet this is a no-op — the correct mounts will be applied on the
-// next StartProject call.
+// one with updated bind mounts and port bindings, keeping its data volume.
+// The container is restored to its previous state (running → restarted,
+// stopped → kept stopped). If the container does not exist yet this is a
+// no-op — the correct mounts will be applied on the next StartProject call.
 func (m *Manager) RecreateOdooContainer(ctx context.Context, project *store.Project, addonsHostDir, enterpriseHostDir, designThemesHostDir string) error {
 	odooName := fmt.Sprintf("odoo-%s", project.ID)
-	postgresName := fmt.Sprintf("postgres-%s", project.ID)
 
 	// Check if the container exists and its current state
 	existing, err := m.cli.ContainerInspect(ctx, odooName)
@@ -455,6 +591,10 @@ func (m *Manager) RecreateOdooContainer(ctx context.Context, project *store.Proj
 	}
 
 	wasRunning := existing.State.Running
+	dataVolumeName := odooDataVolume(existing.Mounts)
+	if err := m.ensureProjectNetwork(ctx, project.ID); err != nil {
+		return err
+	}
 
 	// Stop if running
 	if wasRunning {
@@ -487,33 +627,29 @@ func (m *Manager) RecreateOdooContainer(ctx context.Context, project *store.Proj
 	if designThemesHostDir != "" {
 		binds = append(binds, fmt.Sprintf("%s:/mnt/design-themes", designThemesHostDir))
 	}
+	// Re-attach the data volume so the filestore survives the recreation
+	if dataVolumeName != "" {
+		binds = append(binds, fmt.Sprintf("%s:/var/lib/odoo", dataVolumeName))
+	}
 
+	odooExposed, odooBindings := odooPorts(project)
 	odooConfig := &container.Config{
-		Image: fmt.Sprintf("odoo:%s", project.OdooVersion),
-		Env: []string{
-			"HOST=postgres",
-			"USER=odoo",
-			"PASSWORD=odoo",
-		},
-		ExposedPorts: nat.PortSet{
-			"8069/tcp": struct{}{},
-		},
-		Entrypoint: odooEntrypoint(),
-		Cmd:        odooCmd(),
-		Tty:        true,
-		Labels:     projectLabels(project.ID, "odoo"),
+		Image:        fmt.Sprintf("odoo:%s", project.OdooVersion),
+		Env:          odooEnv(project),
+		ExposedPorts: odooExposed,
+		Entrypoint:   odooEntrypoint(),
+		Cmd:          odooCmd(),
+		Tty:          true,
+		Labels:       projectLabels(project.ID, "odoo"),
 	}
+	odooNetworkMode, odooNetworking := projectNetworking(project.ID)
 	odooHostConfig := &container.HostConfig{
-		Links: []string{fmt.Sprintf("%s:postgres", postgresName)},
-		PortBindings: nat.PortMap{
-			"8069/tcp": []nat.PortBinding{
-				{HostIP: "0.0.0.0", HostPort: fmt.Sprintf("%d", project.Port)},
-			},
-		},
-		Binds: binds,
+		NetworkMode:  odooNetworkMode,
+		PortBindings: odooBindings,
+		Binds:        binds,
 	}
 
-	if _, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, nil, nil, odooName); err != nil {
+	if _, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, odooNetworking, nil, odooName); err != nil {
 		return fmt.Errorf("recreate odoo container: %w", err)
 	}
 
@@ -527,6 +663,76 @@ func (m *Manager) RecreateOdooContainer(ctx context.Context, project *store.Proj
 	return nil
 }
 
+// odooDataVolume returns the name of the volume mounted at /var/lib/odoo
+// (the filestore), or "" if there is none.
+func odooDataVolume(mounts []container.MountPoint) string {
+	for _, mp := range mounts {
+		if mp.Destination == "/var/lib/odoo" {
+			return mp.Name
+		}
+	}
+	return ""
+}
+
+// RecreatePostgresContainer removes the existing Postgres container and
+// creates a new one with the project's current port bindings, re-attaching
+// its data volumes. The container is restored to its previous state. If
+// the container does not exist yet this is a no-op.
+func (m *Manager) RecreatePostgresContainer(ctx context.Context, project *store.Project) error {
+	postgresName := fmt.Sprintf("postgres-%s", project.ID)
+
+	existing, err := m.cli.ContainerInspect(ctx, postgresName)
+	if err != nil {
+		return nil
+	}
+	wasRunning := existing.State.Running
+	if err := m.ensureProjectNetwork(ctx, project.ID); err != nil {
+		return err
+	}
+
+	// The image declares the data directory as a volume; keep every volume
+	var binds []string
+	for _, mp := range existing.Mounts {
+		if mp.Type == "volume" && mp.Name != "" {
+			binds = append(binds, fmt.Sprintf("%s:%s", mp.Name, mp.Destination))
+		}
+	}
+
+	if wasRunning {
+		timeout := 30
+		if err := m.cli.ContainerStop(ctx, postgresName, container.StopOptions{Timeout: &timeout}); err != nil {
+			if !client.IsErrNotFound(err) {
+				return fmt.Errorf("stop postgres container: %w", err)
+			}
+		}
+	}
+	if err := m.cli.ContainerRemove(ctx, postgresName, container.RemoveOptions{Force: true}); err != nil {
+		if !client.IsErrNotFound(err) {
+			return fmt.Errorf("remove postgres container: %w", err)
+		}
+	}
+
+	postgresExposed, postgresBindings := postgresPorts(project)
+	postgresConfig := &container.Config{
+		Image:        existing.Config.Image,
+		Env:          postgresEnv(project),
+		ExposedPorts: postgresExposed,
+		Labels:       projectLabels(project.ID, "postgres"),
+	}
+	postgresNetworkMode, postgresNetworking := projectNetworking(project.ID, "postgres")
+	postgresHostConfig := &container.HostConfig{NetworkMode: postgresNetworkMode, PortBindings: postgresBindings, Binds: binds}
+	if _, err := m.cli.ContainerCreate(ctx, postgresConfig, postgresHostConfig, postgresNetworking, nil, postgresName); err != nil {
+		return fmt.Errorf("recreate postgres container: %w", err)
+	}
+
+	if wasRunning {
+		if err := m.cli.ContainerStart(ctx, postgresName, container.StartOptions{}); err != nil {
+			return fmt.Errorf("restart postgres container: %w", err)
+		}
+	}
+	return nil
+}
+
 // UpdateOdooContainer pulls the latest Odoo image and recreates only the Odoo
 // container, preserving all data volumes (the anonymous /var/lib/odoo volume
 // is found on the old container and explicitly re-bound). Git repo, enterprise
@@ -543,19 +749,16 @@ func (m *Manager) UpdateOdooContainer(ctx context.Context, project *store.Projec
 	var dataVolumeName string
 	if err == nil {
 		wasRunning = existing.State.Running
-		// Find the anonymous volume mounted at /var/lib/odoo
-		for _, mp := range existing.Mounts {
-			if mp.Destination == "/var/lib/odoo" {
-				dataVolumeName = mp.Name
-				break
-			}
-		}
+		dataVolumeName = odooDataVolume(existing.Mounts)
 	}
 
 	// Pull the latest image.
 	if err := m.pullImage(ctx, odooImage); err != nil {
 		return fmt.Errorf("pull latest odoo image: %w", err)
 	}
+	if err := m.ensureProjectNetwork(ctx, project.ID); err != nil {
+		return err
+	}
 
 	// Stop if running.
 	if wasRunning {
@@ -593,28 +796,24 @@ func (m *Manager) UpdateOdooContainer(ctx context.Context, project *store.Projec
 		binds = append(binds, fmt.Sprintf("%s:/var/lib/odoo", dataVolumeName))
 	}
 
+	odooExposed, odooBindings := odooPorts(project)
 	odooConfig := &container.Config{
-		Image: odooImage,
-		Env: []string{
-			"HOST=postgres",
-			"USER=odoo",
-			"PASSWORD=odoo",
-		},
-		ExposedPorts: nat.PortSet{"8069/tcp": struct{}{}},
+		Image:        odooImage,
+		Env:          odooEnv(project),
+		ExposedPorts: odooExposed,
 		Entrypoint:   odooEntrypoint(),
 		Cmd:          odooCmd(),
 		Tty:          true,
 		Labels:       projectLabels(project.ID, "odoo"),
 	}
+	odooNetworkMode, odooNetworking := projectNetworking(project.ID)
 	odooHostConfig := &container.HostConfig{
-		Links: []string{fmt.Sprintf("%s:postgres", postgresName)},
-		PortBindings: nat.PortMap{
-			"8069/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: fmt.Sprintf("%d", project.Port)}},
-		},
-		Binds: binds,
+		NetworkMode:  odooNetworkMode,
+		PortBindings: odooBindings,
+		Binds:        binds,
 	}
 
-	if _, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, nil, nil, odooName); err != nil {
+	if _, err := m.cli.ContainerCreate(ctx, odooConfig, odooHostConfig, odooNetworking, nil, odooName); err != nil {
 		return fmt.Errorf("recreate odoo container: %w", err)
 	}
 
@@ -635,6 +834,39 @@ func (m *Manager) RestartOdooContainer(ctx context.Context, projectID string) er
 	return m.cli.ContainerRestart(ctx, odooName, container.StopOptions{Timeout: &timeout})
 }
 
+// OdooImageUpdateAvailable reports whether the registry serves a newer
+// odoo:{version} image than the one the project's Odoo container was
+// created from. Returns false when the container does not exist or its
+// image has no registry digest to compare (e.g. a locally built image).
+func (m *Manager) OdooImageUpdateAvailable(ctx context.Context, project *store.Project) (bool, error) {
+	odooName := fmt.Sprintf("odoo-%s", project.ID)
+	odooImage := fmt.Sprintf("odoo:%s", project.OdooVersion)
+
+	existing, err := m.cli.ContainerInspect(ctx, odooName)
+	if err != nil {
+		return false, nil
+	}
+	img, err := m.cli.ImageInspect(ctx, existing.Image)
+	if err != nil {
+		return false, fmt.Errorf("inspect image of %s: %w", odooName, err)
+	}
+	if len(img.RepoDigests) == 0 {
+		return false, nil
+	}
+
+	dist, err := m.cli.DistributionInspect(ctx, odooImage, "")
+	if err != nil {
+		return false, fmt.Errorf("inspect %s in registry: %w", odooImage, err)
+	}
+	latest := "@" + dist.Descriptor.Digest.String()
+	for _, d := range img.RepoDigests {
+		if strings.HasSuffix(d, latest) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // CleanupResult holds the outcome of a cleanup operation.
 type CleanupResult struct {
 	Removed []string `json:"removed"`
@@ -665,6 +897,24 @@ func containerDisplayName(c container.Summary) string {
 	return c.ID[:12]
 }
 
+// PublishedPorts returns the host ports published by running containers,
+// mapped to the container name.
+func (m *Manager) PublishedPorts(ctx context.Context) (map[int]string, error) {
+	running, err := m.cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	ports := map[int]string{}
+	for _, c := range running {
+		for _, p := range c.Ports {
+			if p.PublicPort != 0 {
+				ports[int(p.PublicPort)] = containerDisplayName(c)
+			}
+		}
+	}
+	return ports, nil
+}
+
 // ListOrphanedContainers returns the names of containers whose project no
 // longer exists in the store (read-only preview).
 // knownProjectIDs must contain every project ID from the database.
@@ -841,6 +1091,59 @@ func (m *Manager) CleanOrphanedImages(ctx context.Context, knownProjectIDs map[s
 	return result, nil
 }
 
+// orphanedNetworks returns the project networks whose project no longer
+// exists in the store. Networks not created by odoo-manager, including
+// Docker's built-in ones, are never considered orphaned.
+func (m *Manager) orphanedNetworks(ctx context.Context, knownProjectIDs map[string]bool) ([]network.Summary, error) {
+	all, err := m.cli.NetworkList(ctx, network.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "odoo-manager.managed=true")),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	var orphaned []network.Summary
+	for _, n := range all {
+		if !knownProjectIDs[n.Labels["odoo-manager.project-id"]] {
+			orphaned = append(orphaned, n)
+		}
+	}
+	return orphaned, nil
+}
+
+// ListOrphanedNetworks returns the names of project networks whose project
+// no longer exists in the store (read-only preview).
+func (m *Manager) ListOrphanedNetworks(ctx context.Context, knownProjectIDs map[string]bool) ([]string, error) {
+	orphaned, err := m.orphanedNetworks(ctx, knownProjectIDs)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, n := range orphaned {
+		names = append(names, n.Name)
+	}
+	return names, nil
+}
+
+// CleanOrphanedNetworks removes all project networks whose project no
+// longer exists in the store. A network still used by a container fails to
+// remove; clean orphaned containers first.
+func (m *Manager) CleanOrphanedNetworks(ctx context.Context, knownProjectIDs map[string]bool) (*CleanupResult, error) {
+	orphaned, err := m.orphanedNetworks(ctx, knownProjectIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newCleanupResult()
+	for _, n := range orphaned {
+		if err := m.cli.NetworkRemove(ctx, n.ID); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", n.Name, err))
+		} else {
+			result.Removed = append(result.Removed, n.Name)
+		}
+	}
+	return result, nil
+}
+
 // containerExists checks if a container exists
 func (m *Manager) containerExists(ctx context.Context, name string) bool {
 	_, err := m.cli.ContainerInspect(ctx, name)
@@ -862,11 +1165,11 @@ func (m *Manager) pullImage(ctx context.Context, imageName string) error {
 
 // ListDatabases runs psql inside the Postgres container and returns the list
 // of databases, excluding system databases (postgres, template0, template1).
-func (m *Manager) ListDatabases(ctx context.Context, projectID string) ([]string, error) {
-	containerName := fmt.Sprintf("postgres-%s", projectID)
+func (m *Manager) ListDatabases(ctx context.Context, project *store.Project) ([]string, error) {
+	containerName := fmt.Sprintf("postgres-%s", project.ID)
 
 	execCfg := container.ExecOptions{
-		Cmd:          []string{"psql", "-U", "odoo", "-d", "postgres", "-t", "-A", "-c", "SELECT datname FROM pg_database WHERE datistemplate = false AND datname NOT IN ('postgres') ORDER BY datname"},
+		Cmd:          []string{"psql", "-U", project.DBUser, "-d", "postgres", "-t", "-A", "-c", "SELECT datname FROM pg_database WHERE datistemplate = false AND datname NOT IN ('postgres') ORDER BY datname"},
 		AttachStdout: true,
 		AttachStderr: true,
 	}
@@ -914,16 +1217,19 @@ func (m *Manager) ListDatabases(ctx context.Context, projectID string) ([]string
 //
 // The returned execID can be inspected to check whether the command has
 // finished. The caller MUST call the cleanup function when done reading.
-func (m *Manager) BackupDatabase(ctx context.Context, projectID, database string) (logReader io.Reader, execID string, cleanup func(), err error) {
-	containerName := fmt.Sprintf("odoo-%s", projectID)
+func (m *Manager) BackupDatabase(ctx context.Context, project *store.Project, database string) (logReader io.Reader, execID string, cleanup func(), err error) {
+	containerName := fmt.Sprintf("odoo-%s", project.ID)
 	backupPath := "/tmp/odoo_backup.zip"
 
-	// Connect to the linked postgres container (user/password match CreateProject).
+	// Connect to the project's postgres container with its credentials.
 	// Redirect stdout (the zip data) to a file; stderr (progress/errors) stays on console.
-	cmd := fmt.Sprintf("odoo db --db_host postgres --db_port 5432 --db_user odoo --db_password odoo dump %s > %s", database, backupPath)
+	// The database name is passed in the environment so the shell never
+	// parses it.
+	cmd := fmt.Sprintf(`odoo db %s dump "$DB_NAME" > %s`, odooDBArgs, backupPath)
 
 	execCfg := container.ExecOptions{
 		Cmd:          []string{"sh", "-c", cmd},
+		Env:          append(dbExecEnv(project), "DB_NAME="+database),
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          true, // single stream (no multiplexing headers)
@@ -1044,6 +1350,53 @@ func (m *Manager) updateOdooConfigAddonPath(ctx context.Context, projectID, addo
 	return m.WriteOdooConfig(ctx, projectID, strings.Join(lines, "\n"))
 }
 
+// OdooConfigValue returns the value of key in odoo.conf content and whether
+// the key is set at all.
+func OdooConfigValue(content, key string) (string, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok && strings.TrimSpace(name) == key {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+// SetOdooConfigOption sets key = value in a project's odoo.conf, replacing
+// an existing entry or appending one. It reports whether the file changed.
+func (m *Manager) SetOdooConfigOption(ctx context.Context, projectID, key, value string) (bool, error) {
+	content, err := m.ReadOdooConfig(ctx, projectID)
+	if err != nil {
+		return false, err
+	}
+
+	want := key + " = " + value
+	lines := strings.Split(content, "\n")
+	found := false
+	for i, line := range lines {
+		name, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.TrimSpace(name) != key {
+			continue
+		}
+		if strings.TrimSpace(line) == want {
+			return false, nil
+		}
+		lines[i] = want
+		found = true
+		break
+	}
+	if !found {
+		// Keep the trailing newline last
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			lines = append(lines[:n-1], want, "")
+		} else {
+			lines = append(lines, want)
+		}
+	}
+
+	return true, m.WriteOdooConfig(ctx, projectID, strings.Join(lines, "\n"))
+}
+
 // CopyBackupFromContainer copies /tmp/odoo_backup.zip out of the Odoo
 // container and saves it to destPath on the host. It removes the file
 // from the container afterwards.
@@ -1089,3 +1442,213 @@ func (m *Manager) CopyBackupFromContainer(ctx context.Context, projectID, destPa
 
 	return nil
 }
+
+// odooDBArgs are the "odoo db" options connecting to a project's database
+// from its Odoo container. The credentials come from the exec environment
+// set up by dbExecEnv rather than the command line.
+const odooDBArgs = `--db_host postgres --db_port 5432 --db_user "$DB_USER" --db_password "$DB_PASSWORD"`
+
+// dbExecEnv returns the exec environment odooDBArgs expects.
+func dbExecEnv(project *store.Project) []string {
+	return []string{"DB_USER=" + project.DBUser, "DB_PASSWORD=" + project.DBPassword}
+}
+
+// runExec runs cmd inside a container and returns its combined output and
+// exit code.
+func (m *Manager) runExec(ctx context.Context, containerName string, env, cmd []string) (string, int, error) {
+	execResp, err := m.cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		Cmd:          cmd,
+		Env:          env,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true, // single stream (no multiplexing headers)
+	})
+	if err != nil {
+		return "", -1, fmt.Errorf("failed to create exec in %s: %w", containerName, err)
+	}
+	attach, err := m.cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{Tty: true})
+	if err != nil {
+		return "", -1, fmt.Errorf("failed to attach to exec in %s: %w", containerName, err)
+	}
+	defer attach.Close()
+
+	output, err := io.ReadAll(attach.Reader)
+	if err != nil {
+		return "", -1, fmt.Errorf("failed to read exec output: %w", err)
+	}
+	code, err := m.WaitExec(ctx, execResp.ID)
+	return string(output), code, err
+}
+
+// waitPostgresReady polls pg_isready in a project's Postgres container
+// until it accepts connections or ctx expires.
+func (m *Manager) waitPostgresReady(ctx context.Context, project *store.Project) error {
+	containerName := fmt.Sprintf("postgres-%s", project.ID)
+	for {
+		if _, code, err := m.runExec(ctx, containerName, nil, []string{"pg_isready", "-U", project.DBUser}); err == nil && code == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not ready: %w", containerName, ctx.Err())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// CloneDatabase copies database, including its filestore, from the Odoo
+// container of project src into that of project dst under the same name.
+// It dumps with "odoo db dump" like BackupDatabase and restores with
+// "odoo db load --neutralize", which disables outgoing mail servers and
+// scheduled actions in the copy. Both projects must be running.
+func (m *Manager) CloneDatabase(ctx context.Context, src, dst *store.Project, database string) error {
+	srcName := fmt.Sprintf("odoo-%s", src.ID)
+	dstName := fmt.Sprintf("odoo-%s", dst.ID)
+	const dumpPath = "/tmp/odoo_clone.zip"
+
+	out, code, err := m.runExec(ctx, srcName, dbExecEnv(src), []string{"sh", "-c",
+		fmt.Sprintf("odoo db %s dump %s > %s", odooDBArgs, database, dumpPath)})
+	if err != nil {
+		return err
+	}
+	defer m.runExec(context.Background(), srcName, nil, []string{"rm", "-f", dumpPath})
+	if code != 0 {
+		return fmt.Errorf("dump of %s failed (exit %d): %s", database, code, strings.TrimSpace(out))
+	}
+
+	// CopyFromContainer returns a tar archive, which CopyToContainer accepts as-is
+	rc, _, err := m.cli.CopyFromContainer(ctx, srcName, dumpPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy dump from %s: %w", srcName, err)
+	}
+	defer rc.Close()
+	if err := m.cli.CopyToContainer(ctx, dstName, "/tmp", rc, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to copy dump into %s: %w", dstName, err)
+	}
+	defer m.runExec(context.Background(), dstName, nil, []string{"rm", "-f", dumpPath})
+
+	if err := m.waitPostgresReady(ctx, dst); err != nil {
+		return err
+	}
+	out, code, err = m.runExec(ctx, dstName, dbExecEnv(dst), []string{"sh", "-c",
+		fmt.Sprintf("odoo db %s load --force --neutralize %s %s", odooDBArgs, database, dumpPath)})
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("restore of %s failed (exit %d): %s", database, code, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// streamOdooExec runs a shell command in a project's Odoo container with
+// the database credentials of dbExecEnv, streaming its console output like
+// BackupDatabase. The caller MUST call cleanup when done reading.
+func (m *Manager) streamOdooExec(ctx context.Context, project *store.Project, cmd string) (logReader io.Reader, execID string, cleanup func(), err error) {
+	containerName := fmt.Sprintf("odoo-%s", project.ID)
+	execResp, err := m.cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		Cmd:          []string{"sh", "-c", cmd},
+		Env:          dbExecEnv(project),
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true, // single stream (no multiplexing headers)
+	})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create exec in %s: %w", containerName, err)
+	}
+	attach, err := m.cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{Tty: true})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to attach to exec in %s: %w", containerName, err)
+	}
+	return attach.Reader, execResp.ID, attach.Close, nil
+}
+
+// RestoreDatabase copies the backup zip at zipPath on the host into the
+// Odoo container and runs "odoo db load --force" on it, replacing database
+// if it exists. With neutralize, outgoing mail servers and scheduled
+// actions are disabled in the restored copy. Output is streamed like
+// BackupDatabase; cleanup also removes the copied zip.
+func (m *Manager) RestoreDatabase(ctx context.Context, project *store.Project, database, zipPath string, neutralize bool) (logReader io.Reader, execID string, cleanup func(), err error) {
+	containerName := fmt.Sprintf("odoo-%s", project.ID)
+	const restorePath = "/tmp/odoo_restore.zip"
+
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// CopyToContainer takes a tar archive; stream the zip into one
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{Name: filepath.Base(restorePath), Mode: 0o644, Size: info.Size(), ModTime: info.ModTime()})
+		if err == nil {
+			_, err = io.Copy(tw, f)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	if err := m.cli.CopyToContainer(ctx, containerName, filepath.Dir(restorePath), pr, container.CopyToContainerOptions{}); err != nil {
+		pr.CloseWithError(err)
+		return nil, "", nil, fmt.Errorf("failed to copy backup into %s: %w", containerName, err)
+	}
+	removeZip := func() {
+		m.runExec(context.Background(), containerName, nil, []string{"rm", "-f", restorePath})
+	}
+
+	flags := "--force"
+	if neutralize {
+		flags += " --neutralize"
+	}
+	logReader, execID, closeExec, err := m.streamOdooExec(ctx, project,
+		fmt.Sprintf("odoo db %s load %s %s %s", odooDBArgs, flags, database, restorePath))
+	if err != nil {
+		removeZip()
+		return nil, "", nil, err
+	}
+	return logReader, execID, func() { closeExec(); removeZip() }, nil
+}
+
+// UpgradeModules runs "odoo -u" for modules on database in a one-off Odoo
+// process next to the running server, which reloads its registry when the
+// upgrade is done. Output is streamed like BackupDatabase.
+func (m *Manager) UpgradeModules(ctx context.Context, project *store.Project, database string, modules []string) (logReader io.Reader, execID string, cleanup func(), err error) {
+	return m.streamOdooExec(ctx, project, fmt.Sprintf("odoo %s -d %s -u %s --stop-after-init --no-http",
+		odooDBArgs, database, strings.Join(modules, ",")))
+}
+
+// OpenShell starts an interactive "odoo shell" on database in a project's
+// Odoo container, on a terminal of the given size. Everything written to
+// input reaches the shell; output carries the terminal's screen. The
+// caller MUST call cleanup when done.
+func (m *Manager) OpenShell(ctx context.Context, project *store.Project, database string, rows, cols uint) (output io.Reader, input io.Writer, cleanup func(), err error) {
+	containerName := fmt.Sprintf("odoo-%s", project.ID)
+	size := &[2]uint{rows, cols}
+	if rows == 0 || cols == 0 {
+		size = nil
+	}
+	execResp, err := m.cli.ContainerExecCreate(ctx, containerName, container.ExecOptions{
+		Cmd:          []string{"sh", "-c", fmt.Sprintf("odoo shell %s -d %s --no-http", odooDBArgs, database)},
+		Env:          dbExecEnv(project),
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		ConsoleSize:  size,
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create shell in %s: %w", containerName, err)
+	}
+	attach, err := m.cli.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{Tty: true, ConsoleSize: size})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to attach to shell in %s: %w", containerName, err)
+	}
+	return attach.Reader, attach.Conn, attach.Close, nil
+}
@@ -17,6 +17,8 @@ const (
 	ProjectBackupPending EventType = "project_backup_pending"
 	ProjectBackupDone    EventType = "project_backup_done"
 	DockerStatus         EventType = "docker_status"
+	GitProgress          EventType = "git_progress"
+	ProjectUpdates       EventType = "project_updates"
 )
 
 // Event represents a project lifecycle event broadcast to all SSE clients
@@ -66,8 +68,11 @@ func (h *Hub) Publish(evt Event) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	data, _ := json.Marshal(evt)
-	log.Printf("Broadcasting event: %s", string(data))
+	// Git progress fires for every percent step; keep it out of the log
+	if evt.Type != GitProgress {
+		data, _ := json.Marshal(evt)
+		log.Printf("Broadcasting event: %s", string(data))
+	}
 
 	for ch := range h.clients {
 		select {
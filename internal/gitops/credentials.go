@@ -0,0 +1,136 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables used to hand a PAT to git without writing it to
+// disk or putting it on the command line. git inherits them from runGit and
+// invokes this same binary as GIT_ASKPASS, which answers the prompt.
+const (
+	askPassEnv      = "ODOO_MANAGER_GIT_ASKPASS"
+	askPassTokenEnv = "ODOO_MANAGER_GIT_TOKEN"
+)
+
+// tokenUsername is the username paired with a PAT for HTTPS auth
+// (GitHub PAT convention).
+const tokenUsername = "x-access-token"
+
+// IsAskPass reports whether the current process was started by git as a
+// GIT_ASKPASS helper rather than as the web server.
+func IsAskPass() bool {
+	return os.Getenv(askPassEnv) == "1"
+}
+
+// RunAskPass answers a single git credential prompt. git passes the prompt
+// text as the first argument, e.g. "Username for 'https://github.com': ".
+// Returns the process exit code.
+func RunAskPass(args []string) int {
+	prompt := ""
+	if len(args) > 0 {
+		prompt = strings.ToLower(strings.TrimSpace(args[0]))
+	}
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		fmt.Println(tokenUsername)
+	case strings.HasPrefix(prompt, "password"):
+		fmt.Println(os.Getenv(askPassTokenEnv))
+	default:
+		fmt.Fprintf(os.Stderr, "odoo-manager askpass: unexpected prompt %q\n", prompt)
+		return 1
+	}
+	return 0
+}
+
+// gitArgs prefixes git arguments with config overrides that disable any
+// credential helper configured on the host, so a PAT supplied through
+// askpass is never persisted to a system keychain or credential store.
+func gitArgs(args []string) []string {
+	return append([]string{"-c", "credential.helper="}, args...)
+}
+
+// gitEnv returns the environment for a git subprocess. When token is
+// non-empty git is pointed at this binary as its askpass helper and the
+// token is passed through the environment only.
+func gitEnv(token string) []string {
+	// Prevent git from asking for credentials interactively
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if token == "" {
+		return env
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		log.Printf("gitops: cannot resolve own executable for askpass: %v", err)
+		return env
+	}
+	return append(env,
+		"GIT_ASKPASS="+exe,
+		askPassEnv+"=1",
+		askPassTokenEnv+"="+token,
+	)
+}
+
+// stripCredentials removes any userinfo (user:token@) from an HTTPS URL.
+// Non-URL values are returned unchanged.
+func stripCredentials(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// SanitizeRemotes rewrites the origin URL of every clone under data/repos
+// that still embeds credentials (from older versions that injected the PAT
+// into the clone URL) to its token-free form. Returns the number of clones
+// rewritten, and an error when any remote could not be rewritten so the
+// caller can retry later.
+func SanitizeRemotes(ctx context.Context) (int, error) {
+	root := filepath.Join("data", "repos")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("read repos dir: %w", err)
+	}
+
+	n, failed := 0, 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+			continue
+		}
+		out, err := exec.CommandContext(ctx, gitExePath(), "-C", dir, "remote", "get-url", "origin").Output()
+		if err != nil {
+			continue
+		}
+		current := strings.TrimSpace(string(out))
+		clean := stripCredentials(current)
+		if clean == current {
+			continue
+		}
+		if err := exec.CommandContext(ctx, gitExePath(), "-C", dir, "remote", "set-url", "origin", clean).Run(); err != nil {
+			log.Printf("gitops: failed to sanitize remote for %s: %v", dir, err)
+			failed++
+			continue
+		}
+		log.Printf("gitops: removed embedded credentials from %s origin", dir)
+		n++
+	}
+	if failed > 0 {
+		return n, fmt.Errorf("%d remote(s) still embed credentials", failed)
+	}
+	return n, nil
+}
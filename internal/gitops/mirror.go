@@ -0,0 +1,352 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Upstream repositories are fetched once into a bare mirror under
+// data/mirrors/<host>/<path> and shared by every project using them:
+//
+//   - Enterprise and Design Themes checkouts are git worktrees of the
+//     mirror, which only holds a shallow copy of the branches in use. A new
+//     project on an already-fetched version costs one checkout and no
+//     download.
+//   - Custom addons clones of a repository that another project already
+//     uses are regular clones created with --reference to the mirror, so
+//     they borrow its objects but keep their own branches for local
+//     commits. The mirror then holds the full history of the refs deployed
+//     from it. A repository used by a single project is cloned on its own.
+//
+// Mirrors are never garbage-collected (gc.auto=0) because checkouts rely on
+// their objects; do not delete data/mirrors while projects still use it.
+
+// mirrorLocks serialises fetches and worktree changes per mirror directory.
+var mirrorLocks sync.Map // dir → *sync.Mutex
+
+// lockMirror locks the mirror at dir and returns the unlock function.
+func lockMirror(dir string) func() {
+	v, _ := mirrorLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// MirrorDir returns the local directory of the shared bare mirror for
+// repoURL, e.g. data/mirrors/github.com/odoo/enterprise.git.
+func MirrorDir(repoURL string) (string, error) {
+	u, err := url.Parse(stripCredentials(repoURL))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid repository URL %q", repoURL)
+	}
+	parts := []string{"data", "mirrors", strings.ToLower(u.Host)}
+	for _, p := range strings.Split(u.Path, "/") {
+		switch p {
+		case "":
+			continue
+		case ".", "..":
+			return "", fmt.Errorf("invalid repository path in %q", repoURL)
+		}
+		parts = append(parts, p)
+	}
+	if len(parts) < 4 {
+		return "", fmt.Errorf("invalid repository path in %q", repoURL)
+	}
+	return filepath.Join(parts...), nil
+}
+
+// ensureMirror creates an empty bare mirror for repoURL if it does not
+// exist yet and returns its absolute path. Objects are only downloaded by
+// the fetch helpers.
+func ensureMirror(ctx context.Context, repoURL string) (string, error) {
+	dir, err := MirrorDir(repoURL)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(abs, "HEAD")); err == nil {
+		return abs, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return "", fmt.Errorf("create mirrors dir: %w", err)
+	}
+	log.Printf("gitops: creating mirror for %s in %s", repoURL, dir)
+	if err := runGit(ctx, "", "", "init", "--bare", "--quiet", abs); err != nil {
+		os.RemoveAll(abs)
+		return "", fmt.Errorf("init mirror: %w", err)
+	}
+	for _, kv := range [][2]string{
+		{"remote.origin.url", stripCredentials(repoURL)},
+		{"gc.auto", "0"},
+	} {
+		if _, err := gitOutput(ctx, abs, "config", kv[0], kv[1]); err != nil {
+			os.RemoveAll(abs)
+			return "", fmt.Errorf("configure mirror: %w", err)
+		}
+	}
+	return abs, nil
+}
+
+// fetchMirrorBranch updates one branch of the mirror to the upstream tip,
+// shallowly unless the mirror already holds full history (see
+// referenceMirror). Commits fetched earlier stay available for rollbacks.
+func fetchMirrorBranch(ctx context.Context, mirror, token, branch string) error {
+	args := []string{"fetch", "--progress"}
+	if isShallow(mirror) || isEmptyRepo(ctx, mirror) {
+		args = append(args, "--depth", "1")
+	}
+	args = append(args, "origin", "+refs/heads/"+branch+":refs/heads/"+branch)
+	if err := runGit(ctx, mirror, token, args...); err != nil {
+		return fmt.Errorf("fetch %s into mirror: %w", branch, err)
+	}
+	return nil
+}
+
+// isShallow reports whether the bare repository at dir has truncated history.
+func isShallow(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "shallow"))
+	return err == nil
+}
+
+// isEmptyRepo reports whether the repository at dir has no refs yet.
+func isEmptyRepo(ctx context.Context, dir string) bool {
+	out, err := gitOutput(ctx, dir, "for-each-ref", "--count=1")
+	return err == nil && out == ""
+}
+
+// repoShared reports whether a project other than the one cloned in dir
+// already uses repoURL: its mirror exists, or another checkout under
+// data/repos has it as origin.
+func repoShared(ctx context.Context, repoURL, dir string) bool {
+	mirror, err := MirrorDir(repoURL)
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(mirror, "HEAD")); err == nil {
+		return true
+	}
+	root := filepath.Join("data", "repos")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		other := filepath.Join(root, e.Name())
+		if !e.IsDir() || other == filepath.Clean(dir) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(other, ".git")); err != nil {
+			continue
+		}
+		origin, err := gitOutput(ctx, other, "remote", "get-url", "origin")
+		if err != nil {
+			continue
+		}
+		if otherMirror, err := MirrorDir(origin); err == nil &&
+			strings.TrimSuffix(otherMirror, ".git") == strings.TrimSuffix(mirror, ".git") {
+			return true
+		}
+	}
+	return false
+}
+
+// mirrorRefspec returns the refspec fetching ref into a reference mirror,
+// or "" when it cannot be fetched by name (commits, or a detached clone).
+func mirrorRefspec(ref string, kind RefKind) string {
+	switch {
+	case ref == "" || ref == "HEAD":
+		return ""
+	case kind == RefBranch:
+		return "+refs/heads/" + ref + ":refs/heads/" + ref
+	case kind == RefTag:
+		return "+refs/tags/" + ref + ":refs/tags/" + ref
+	}
+	return ""
+}
+
+// remoteDefaultBranch returns the name of the remote's default branch.
+func remoteDefaultBranch(ctx context.Context, repoURL, token string) (string, error) {
+	refs, err := listRemoteRefs(ctx, repoURL, token)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range refs {
+		if r.Name() == plumbing.HEAD && r.Type() == plumbing.SymbolicReference {
+			return r.Target().Short(), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no default branch", repoURL)
+}
+
+// referenceMirror fetches refspec with full history into the shared mirror
+// for repoURL and returns its path for use with clone --reference. An empty
+// refspec uses the mirror as it is. Shallow mirrors (shared with Enterprise
+// or Design Themes worktrees) are not used, since git refuses shallow
+// references. Failures are logged and reported as an empty path so callers
+// can fall back to a standalone clone.
+func referenceMirror(ctx context.Context, repoURL, token, refspec string) string {
+	mirror, err := ensureMirror(ctx, repoURL)
+	if err != nil {
+		log.Printf("gitops: mirror unavailable for %s: %v", repoURL, err)
+		return ""
+	}
+	unlock := lockMirror(mirror)
+	defer unlock()
+	if isShallow(mirror) {
+		return ""
+	}
+	if refspec != "" {
+		if err := runGit(ctx, mirror, token, "fetch", "--progress", "origin", refspec); err != nil {
+			log.Printf("gitops: fetch mirror: %v", err)
+			return ""
+		}
+	}
+	if isEmptyRepo(ctx, mirror) {
+		return ""
+	}
+	return mirror
+}
+
+// hasAlternates reports whether the clone in dir borrows objects from
+// another repository, i.e. was created with --reference.
+func hasAlternates(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git", "objects", "info", "alternates"))
+	return err == nil
+}
+
+// checkoutFromMirror creates or updates dir as a detached worktree of the
+// shared mirror of repoURL at the tip of branch. A standalone clone left in
+// dir by an older version is replaced by a worktree unless it has local
+// modifications; one holding untracked files, unpushed commits or stashes
+// is moved aside first. Returns the absolute checkout path.
+func checkoutFromMirror(ctx context.Context, dir, repoURL, token, branch string) (string, error) {
+	if branch == "" {
+		return "", fmt.Errorf("a branch is required to check out %s", repoURL)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	mirror, err := ensureMirror(ctx, repoURL)
+	if err != nil {
+		return "", err
+	}
+
+	unlock := lockMirror(mirror)
+	defer unlock()
+
+	standalone := false
+	if fi, err := os.Stat(filepath.Join(abs, ".git")); err == nil {
+		if err := checkClean(ctx, abs); err != nil {
+			return "", err
+		}
+		standalone = fi.IsDir()
+	}
+
+	log.Printf("gitops: fetching %s %s into mirror ...", repoURL, branch)
+	if err := fetchMirrorBranch(ctx, mirror, token, branch); err != nil {
+		return "", err
+	}
+
+	if standalone {
+		// Clone from before mirrors were introduced
+		local, err := hasLocalWork(ctx, abs)
+		if err != nil {
+			return "", err
+		}
+		if local {
+			old := fmt.Sprintf("%s.standalone-%d", abs, time.Now().Unix())
+			log.Printf("gitops: standalone clone %s has local work, moving it to %s", dir, old)
+			if err := os.Rename(abs, old); err != nil {
+				return "", fmt.Errorf("move old clone aside: %w", err)
+			}
+		} else {
+			log.Printf("gitops: replacing standalone clone %s with a mirror worktree", dir)
+			if err := os.RemoveAll(abs); err != nil {
+				return "", fmt.Errorf("remove old clone: %w", err)
+			}
+		}
+	} else if _, err := os.Stat(filepath.Join(abs, ".git")); os.IsNotExist(err) {
+		// Leftover directory without git metadata
+		os.RemoveAll(abs)
+	}
+
+	ref := "refs/heads/" + branch
+	if _, err := os.Stat(filepath.Join(abs, ".git")); err == nil {
+		if err := runGit(ctx, abs, "", "checkout", "--detach", ref); err != nil {
+			return "", fmt.Errorf("checkout %s failed: %w", branch, err)
+		}
+		log.Printf("gitops: %s updated to %s", dir, branch)
+		return abs, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return "", fmt.Errorf("create repo parent dir: %w", err)
+	}
+	// Drop admin entries of worktrees whose directory was deleted so the
+	// name can be reused
+	_ = runGit(ctx, mirror, "", "worktree", "prune")
+	if err := runGit(ctx, mirror, "", "worktree", "add", "--detach", abs, ref); err != nil {
+		os.RemoveAll(abs)
+		return "", fmt.Errorf("create worktree: %w", err)
+	}
+	log.Printf("gitops: %s checked out from mirror at %s", dir, branch)
+	return abs, nil
+}
+
+// removeMirrorCheckout deletes a worktree checkout and unregisters it from
+// the mirror of repoURL.
+func removeMirrorCheckout(dir, repoURL string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if mirror, err := MirrorDir(repoURL); err == nil {
+		mirror, _ = filepath.Abs(mirror)
+		if _, err := os.Stat(filepath.Join(mirror, "HEAD")); err == nil {
+			unlock := lockMirror(mirror)
+			defer unlock()
+			_ = runGit(context.Background(), mirror, "", "worktree", "prune")
+		}
+	}
+	return nil
+}
+
+// hasLocalWork reports whether the clone in dir holds anything that exists
+// only there: untracked files, commits on no remote-tracking branch, or
+// stashes.
+func hasLocalWork(ctx context.Context, dir string) (bool, error) {
+	st, err := Status(ctx, dir)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range st.Files {
+		if f.Untracked() {
+			return true, nil
+		}
+	}
+	unpushed, err := gitOutput(ctx, dir, "rev-list", "--max-count=1", "--branches", "--not", "--remotes")
+	if err != nil {
+		return false, fmt.Errorf("git rev-list in %s: %w", dir, err)
+	}
+	stashes, err := gitOutput(ctx, dir, "stash", "list")
+	if err != nil {
+		return false, fmt.Errorf("git stash list in %s: %w", dir, err)
+	}
+	return unpushed != "" || stashes != "", nil
+}
@@ -0,0 +1,91 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is one step of git's --progress output, e.g. "Receiving objects"
+// at 45%.
+type Progress struct {
+	Repo    string `json:"repo"`
+	Phase   string `json:"phase"`
+	Percent int    `json:"percent"`
+}
+
+// ProgressFunc receives parsed progress updates. It is called from the
+// goroutine copying git's stderr and must not block for long.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+type progressReporter struct {
+	repo string
+	fn   ProgressFunc
+}
+
+// WithProgress returns a context that makes git commands run with it
+// report their progress to fn, tagged with repo (e.g. "addons").
+func WithProgress(ctx context.Context, repo string, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progressReporter{repo: repo, fn: fn})
+}
+
+// progressLine matches git progress lines such as
+// "remote: Counting objects:  12% (34/280)" or "Receiving objects: 45% (…), 1.2 MiB | 3 MiB/s".
+var progressLine = regexp.MustCompile(`^(?:remote: )?([A-Za-z][A-Za-z ]*?):\s+(\d{1,3})%`)
+
+// gitStderr returns the writer for git's stderr: the server's stderr,
+// teed into a progress parser when ctx carries a reporter.
+func gitStderr(ctx context.Context) io.Writer {
+	r, ok := ctx.Value(progressKey{}).(progressReporter)
+	if !ok {
+		return os.Stderr
+	}
+	return &progressParser{out: os.Stderr, reporter: r, last: Progress{Percent: -1}}
+}
+
+// progressParser passes output through to out and reports every change of
+// phase or percentage. git redraws progress with carriage returns, so both
+// \r and \n end a line.
+type progressParser struct {
+	out      io.Writer
+	reporter progressReporter
+	buf      []byte
+	last     Progress
+}
+
+func (p *progressParser) Write(b []byte) (int, error) {
+	n, err := p.out.Write(b)
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexAny(p.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		p.parse(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+	}
+	return n, err
+}
+
+func (p *progressParser) parse(line string) {
+	m := progressLine.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return
+	}
+	pct, _ := strconv.Atoi(m[2])
+	if pct > 100 {
+		return
+	}
+	cur := Progress{Repo: p.reporter.repo, Phase: m[1], Percent: pct}
+	if cur == p.last {
+		return
+	}
+	p.last = cur
+	p.reporter.fn(cur)
+}
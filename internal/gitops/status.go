@@ -0,0 +1,229 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDiffBytes caps the size of a diff returned by Diff so a huge change
+// set (e.g. a vendored library) can't exhaust memory or the browser.
+const maxDiffBytes = 1 << 20
+
+// FileStatus is one changed path in a working tree.
+type FileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"` // source path of a rename/copy
+	// Code is git's two-letter XY status: X is the index (staged) state,
+	// Y the working-tree state, "." meaning unchanged. Untracked files
+	// are "??" and unmerged ones carry a "U".
+	Code string `json:"code"`
+}
+
+// Untracked reports whether the path is not known to git.
+func (f FileStatus) Untracked() bool {
+	return f.Code == "??"
+}
+
+// RepoStatus describes the state of a local checkout.
+type RepoStatus struct {
+	Branch   string       `json:"branch"` // empty when HEAD is detached
+	Head     string       `json:"head"`
+	Upstream string       `json:"upstream,omitempty"`
+	Ahead    int          `json:"ahead"`  // commits not on upstream, as of the last fetch
+	Behind   int          `json:"behind"` // upstream commits not yet pulled, as of the last fetch
+	Dirty    bool         `json:"dirty"`  // tracked files are modified or staged
+	Files    []FileStatus `json:"files"`
+}
+
+// DirtyFiles returns the tracked paths with local modifications. Untracked
+// files are left out: a pull never touches them unless upstream adds the
+// same path, in which case git refuses on its own.
+func (s *RepoStatus) DirtyFiles() []string {
+	var paths []string
+	for _, f := range s.Files {
+		if !f.Untracked() {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// Commit is a single entry of a repository log.
+type Commit struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// DirtyTreeError is returned when an update is refused because the
+// checkout has local modifications that a pull could clobber.
+type DirtyTreeError struct {
+	Dir   string
+	Files []string
+}
+
+func (e *DirtyTreeError) Error() string {
+	return fmt.Sprintf("%s has %d locally modified file(s)", e.Dir, len(e.Files))
+}
+
+// IsRepo reports whether dir contains a git checkout.
+func IsRepo(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// Status returns the branch, ahead/behind counts and changed files of the
+// checkout in dir. It only inspects local state; ahead/behind reflect the
+// remote-tracking branch as of the last fetch.
+func Status(ctx context.Context, dir string) (*RepoStatus, error) {
+	out, err := gitOutputRaw(ctx, dir, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("git status in %s: %w", dir, err)
+	}
+
+	st := &RepoStatus{Files: []FileStatus{}}
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		line := fields[i]
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case '#':
+			parts := strings.Fields(line)
+			if len(parts) < 3 {
+				continue
+			}
+			switch parts[1] {
+			case "branch.oid":
+				if parts[2] != "(initial)" {
+					st.Head = parts[2]
+				}
+			case "branch.head":
+				if parts[2] != "(detached)" {
+					st.Branch = parts[2]
+				}
+			case "branch.upstream":
+				st.Upstream = parts[2]
+			case "branch.ab":
+				if len(parts) >= 4 {
+					st.Ahead, _ = strconv.Atoi(strings.TrimPrefix(parts[2], "+"))
+					st.Behind, _ = strconv.Atoi(strings.TrimPrefix(parts[3], "-"))
+				}
+			}
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			parts := strings.SplitN(line, " ", 9)
+			if len(parts) == 9 {
+				st.Files = append(st.Files, FileStatus{Path: parts[8], Code: parts[1]})
+			}
+		case '2':
+			// 2 XY sub mH mI mW hH hI Xscore path, followed by origPath
+			parts := strings.SplitN(line, " ", 10)
+			if len(parts) == 10 {
+				f := FileStatus{Path: parts[9], Code: parts[1]}
+				if i+1 < len(fields) {
+					i++
+					f.OrigPath = fields[i]
+				}
+				st.Files = append(st.Files, f)
+			}
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			parts := strings.SplitN(line, " ", 11)
+			if len(parts) == 11 {
+				st.Files = append(st.Files, FileStatus{Path: parts[10], Code: parts[1]})
+			}
+		case '?':
+			st.Files = append(st.Files, FileStatus{Path: strings.TrimPrefix(line, "? "), Code: "??"})
+		}
+	}
+	st.Dirty = len(st.DirtyFiles()) > 0
+	return st, nil
+}
+
+// Log returns up to limit most recent commits reachable from HEAD.
+func Log(ctx context.Context, dir string, limit int) ([]Commit, error) {
+	out, err := gitOutputRaw(ctx, dir, "log", "-n", strconv.Itoa(limit), "--format=%H%x1f%an%x1f%aI%x1f%s%x1e")
+	if err != nil {
+		return nil, fmt.Errorf("git log in %s: %w", dir, err)
+	}
+
+	commits := []Commit{}
+	for _, rec := range strings.Split(out, "\x1e") {
+		rec = strings.TrimSpace(rec)
+		if rec == "" {
+			continue
+		}
+		parts := strings.SplitN(rec, "\x1f", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, parts[2])
+		commits = append(commits, Commit{SHA: parts[0], Author: parts[1], Date: date, Subject: parts[3]})
+	}
+	return commits, nil
+}
+
+// Diff returns the unified diff of all tracked changes (staged and
+// unstaged) against HEAD, optionally limited to one path. The second
+// return value is true when the output was cut at maxDiffBytes.
+func Diff(ctx context.Context, dir, path string) (string, bool, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", "HEAD"}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+	out, err := gitOutputRaw(ctx, dir, args...)
+	if err != nil {
+		return "", false, fmt.Errorf("git diff in %s: %w", dir, err)
+	}
+	if len(out) > maxDiffBytes {
+		return out[:maxDiffBytes], true, nil
+	}
+	return out, false, nil
+}
+
+// StashChanges saves the tracked local modifications in dir to the stash
+// so a forced update can proceed without losing them. Untracked files are
+// left in place.
+func StashChanges(ctx context.Context, dir string) error {
+	msg := "odoo-manager: before update " + time.Now().Format(time.RFC3339)
+	// A stash is a commit, so give git an identity in case none is
+	// configured on the host
+	if _, err := gitOutput(ctx, dir,
+		"-c", "user.name=Odoo Manager", "-c", "user.email=odoo-manager@localhost",
+		"stash", "push", "--message", msg); err != nil {
+		return fmt.Errorf("git stash in %s: %w", dir, err)
+	}
+	return nil
+}
+
+// checkClean returns a *DirtyTreeError if the checkout in dir has tracked
+// local modifications.
+func checkClean(ctx context.Context, dir string) error {
+	st, err := Status(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if st.Dirty {
+		return &DirtyTreeError{Dir: dir, Files: st.DirtyFiles()}
+	}
+	return nil
+}
+
+// gitOutputRaw is like gitOutput but returns stdout untrimmed, for output
+// whose leading or trailing bytes are significant.
+func gitOutputRaw(ctx context.Context, workDir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, gitExePath(), gitArgs(args)...)
+	cmd.Dir = workDir
+	cmd.Env = gitEnv("")
+	out, err := cmd.Output()
+	return string(out), err
+}
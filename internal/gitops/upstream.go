@@ -0,0 +1,109 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrNotBranch is returned by CommitsBehind when the checkout tracks a tag
+// or commit rather than a branch, so there is nothing to fall behind.
+var ErrNotBranch = errors.New("ref is not a branch")
+
+// remoteBranchHead returns the commit branch points to on the remote, or
+// that of the default branch when branch is empty.
+func remoteBranchHead(ctx context.Context, repoURL, token, branch string) (string, error) {
+	refs, err := listRemoteRefs(ctx, repoURL, token)
+	if err != nil {
+		return "", err
+	}
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, r := range refs {
+		byName[r.Name()] = r
+	}
+
+	name := plumbing.NewBranchReferenceName(branch)
+	if branch == "" {
+		name = plumbing.HEAD
+	}
+	ref, ok := byName[name]
+	if !ok {
+		return "", ErrNotBranch
+	}
+	if ref.Type() == plumbing.SymbolicReference {
+		if ref, ok = byName[ref.Target()]; !ok {
+			return "", ErrNotBranch
+		}
+	}
+	return ref.Hash().String(), nil
+}
+
+// CommitsBehind reports how many commits the remote branch has that the
+// checkout in dir does not, along with the remote head. Missing commits are
+// fetched into the repository (without touching the working tree or local
+// branches) so they can be counted; shallow checkouts only fetch history
+// newer than their HEAD. Returns ErrNotBranch if branch is not a branch on
+// the remote.
+func CommitsBehind(ctx context.Context, dir, repoURL, token, branch string) (int, string, error) {
+	remote, err := remoteBranchHead(ctx, repoURL, token, branch)
+	if err != nil {
+		return 0, "", err
+	}
+	head, err := HeadCommit(ctx, dir)
+	if err != nil {
+		return 0, remote, err
+	}
+	if head == remote {
+		return 0, remote, nil
+	}
+
+	if _, err := gitOutput(ctx, dir, "cat-file", "-e", remote+"^{commit}"); err != nil {
+		if err := fetchForCount(ctx, dir, repoURL, token, branch); err != nil {
+			return 0, remote, err
+		}
+	}
+
+	out, err := gitOutput(ctx, dir, "rev-list", "--count", "HEAD.."+remote)
+	if err != nil {
+		return 0, remote, fmt.Errorf("count commits in %s: %w", dir, err)
+	}
+	n, _ := strconv.Atoi(out)
+	return n, remote, nil
+}
+
+// fetchForCount fetches branch into the remote-tracking ref of the
+// repository at dir. Worktrees share their repository with the mirror, so
+// the mirror is locked for the duration.
+func fetchForCount(ctx context.Context, dir, repoURL, token, branch string) error {
+	if fi, err := os.Stat(filepath.Join(dir, ".git")); err == nil && !fi.IsDir() {
+		if mirror, err := MirrorDir(repoURL); err == nil {
+			if abs, err := filepath.Abs(mirror); err == nil {
+				unlock := lockMirror(abs)
+				defer unlock()
+			}
+		}
+	}
+
+	args := []string{"fetch", "--quiet"}
+	if shallow, _ := gitOutput(ctx, dir, "rev-parse", "--is-shallow-repository"); shallow == "true" {
+		date, err := gitOutput(ctx, dir, "log", "-1", "--format=%cI", "HEAD")
+		if err != nil {
+			return fmt.Errorf("read HEAD date in %s: %w", dir, err)
+		}
+		args = append(args, "--shallow-since="+date)
+	}
+	spec := "HEAD"
+	if branch != "" {
+		spec = "+refs/heads/" + branch + ":refs/remotes/origin/" + branch
+	}
+	args = append(args, "origin", spec)
+	if err := runGit(ctx, dir, token, args...); err != nil {
+		return fmt.Errorf("fetch %s in %s: %w", repoURL, dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// permission is what a request needs to be allowed. Each role is granted a
+// permission and everything below it.
+type permission int
+
+const (
+	permView    permission = iota + 1 // see projects, their status and repo history
+	permOperate                       // start, stop, restart, logs and backups
+	permManage                        // create, change and delete projects
+	permAdmin                         // settings, maintenance, audit log and users
+)
+
+// roleGrants maps each role to the highest permission it holds.
+var roleGrants = map[string]permission{
+	store.RoleViewer:     permView,
+	store.RoleDeveloper:  permOperate,
+	store.RoleMaintainer: permManage,
+	store.RoleAdmin:      permAdmin,
+}
+
+// scopeGrants maps each API token scope to the highest permission it holds.
+// A token is also limited by the role of its owner.
+var scopeGrants = map[string]permission{
+	store.ScopeRead:    permView,
+	store.ScopeOperate: permOperate,
+	store.ScopeManage:  permManage,
+	store.ScopeAdmin:   permAdmin,
+}
+
+// describe completes "cannot ..." in a 403 message.
+func (p permission) describe() string {
+	switch p {
+	case permView:
+		return "view projects"
+	case permOperate:
+		return "start, stop, back up or read the logs of projects"
+	case permManage:
+		return "create, change or delete projects"
+	default:
+		return "change settings, run maintenance, read the audit log or manage users"
+	}
+}
+
+// policy is the access rule of a route: read applies to GET and HEAD, write
+// to every other method. Project routes are further limited to projects
+// the user can see, and session routes refuse API tokens.
+type policy struct {
+	read    permission
+	write   permission
+	project bool
+	session bool
+}
+
+// routePolicies lists the access rule of every route registered by
+// registerAppRoutes, keyed by pattern.
+var routePolicies = map[string]policy{
+	// Pages
+	"/":              {read: permView, write: permView},
+	"/projects":      {read: permView, write: permView},
+	"/audit":         {read: permAdmin, write: permAdmin},
+	"/maintenance":   {read: permAdmin, write: permAdmin},
+	"/configuration": {read: permView, write: permView},
+
+	// Projects
+	"/api/projects":                        {read: permView, write: permManage},
+	"/api/projects/{id}":                   {read: permView, write: permManage, project: true},
+	"/api/projects/{id}/start":             {read: permOperate, write: permOperate, project: true},
+	"/api/projects/{id}/stop":              {read: permOperate, write: permOperate, project: true},
+	"/api/projects/{id}/restart-odoo":      {read: permOperate, write: permOperate, project: true},
+	"/api/projects/{id}/logs":              {read: permOperate, write: permOperate, project: true},
+	"/api/projects/{id}/databases":         {read: permOperate, write: permOperate, project: true},
+	"/api/projects/{id}/backup":            {read: permOperate, write: permOperate, project: true},
+	"/api/backup/download/{id}/{filename}": {read: permOperate, write: permOperate, project: true},
+	"/api/projects/{id}/restore":           {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/modules/upgrade":   {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/shell":             {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/config":            {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/repo":              {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/repo/status":       {read: permView, write: permManage, project: true},
+	"/api/projects/{id}/repo/log":          {read: permView, write: permManage, project: true},
+	"/api/projects/{id}/repo/diff":         {read: permView, write: permManage, project: true},
+	"/api/projects/{id}/update-odoo":       {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/update-repo":       {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/revisions":         {read: permView, write: permManage, project: true},
+	"/api/projects/{id}/rollback":          {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/webhook":           {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/ports":             {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/db-credentials":    {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/master-password":   {read: permManage, write: permManage, project: true},
+	"/api/projects/{id}/members":           {read: permManage, write: permManage, project: true},
+	"/api/repo/branches":                   {read: permManage, write: permManage},
+	"/api/enterprise/check-access":         {read: permManage, write: permManage},
+	"/api/design-themes/check-access":      {read: permManage, write: permManage},
+	"/api/updates":                         {read: permView, write: permManage},
+	"/api/previews":                        {read: permView, write: permManage},
+
+	// Settings, users, and the signed-in account and its API tokens
+	"/api/settings":                {read: permAdmin, write: permAdmin},
+	"/api/settings/validate-token": {read: permAdmin, write: permAdmin},
+	"/api/users":                   {read: permAdmin, write: permAdmin},
+	"/api/users/{uid}":             {read: permAdmin, write: permAdmin},
+	"/api/account/password":        {read: permView, write: permView, session: true},
+	"/api/tokens":                  {read: permView, write: permView, session: true},
+	"/api/tokens/{tid}":            {read: permView, write: permView, session: true},
+
+	// Maintenance
+	"/api/maintenance/preview-containers": {read: permAdmin, write: permAdmin},
+	"/api/maintenance/preview-volumes":    {read: permAdmin, write: permAdmin},
+	"/api/maintenance/preview-images":     {read: permAdmin, write: permAdmin},
+	"/api/maintenance/preview-networks":   {read: permAdmin, write: permAdmin},
+	"/api/maintenance/clean-containers":   {read: permAdmin, write: permAdmin},
+	"/api/maintenance/clean-volumes":      {read: permAdmin, write: permAdmin},
+	"/api/maintenance/clean-images":       {read: permAdmin, write: permAdmin},
+	"/api/maintenance/clean-networks":     {read: permAdmin, write: permAdmin},
+
+	// Audit log and event stream (events are filtered per user)
+	"/api/audit/logs":   {read: permAdmin, write: permAdmin},
+	"/api/audit/stream": {read: permAdmin, write: permAdmin},
+	"/api/events":       {read: permView, write: permView},
+
+	// Versioned API: its routes use the policies above; these cover the
+	// description and unknown paths
+	"GET /api/v1/openapi.json": {read: permView, write: permView},
+	"/api/v1/":                 {read: permView, write: permView},
+}
+
+// allows reports whether role holds permission p.
+func allows(role string, p permission) bool {
+	return roleGrants[role] >= p
+}
+
+// authorize enforces the route policy of pattern before calling next. It
+// panics at startup when the pattern has no policy, so no route can be
+// added without deciding who may use it.
+func (h *Handler) authorize(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	p, ok := routePolicies[pattern]
+	if !ok {
+		panic("handlers: no access policy for route " + pattern)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFrom(r.Context())
+		if !ok {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		need := p.write
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			need = p.read
+		}
+		if !allows(user.Role, need) {
+			h.deny(w, r, fmt.Sprintf("Forbidden: the %s role cannot %s", user.Role, need.describe()))
+			return
+		}
+		if token, ok := auth.TokenFrom(r.Context()); ok {
+			if p.session {
+				h.deny(w, r, "Forbidden: API tokens cannot be used here, sign in instead")
+				return
+			}
+			if scopeGrants[token.Scope] < need {
+				h.deny(w, r, fmt.Sprintf("Forbidden: a token with the %s scope cannot %s", token.Scope, need.describe()))
+				return
+			}
+		}
+		if p.project {
+			if id := routeProjectID(r); id != "" && !h.store.CanSeeProject(user, id) {
+				h.deny(w, r, "Forbidden: you are not a member of this project")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// deny answers 403 with the reason and records it in the audit log.
+func (h *Handler) deny(w http.ResponseWriter, r *http.Request, reason string) {
+	if h.audit != nil {
+		h.audit.Log(r, reason)
+	}
+	http.Error(w, reason, http.StatusForbidden)
+}
+
+// routeProjectID returns the project a request targets, its {id} path value.
+func routeProjectID(r *http.Request) string {
+	return r.PathValue("id")
+}
+
+// visibleProjects returns the projects the signed-in user can see.
+func (h *Handler) visibleProjects(r *http.Request) []*store.Project {
+	user, ok := auth.UserFrom(r.Context())
+	if !ok {
+		return nil
+	}
+	return h.store.ListFor(user)
+}
+
+// canSeeProject reports whether the signed-in user can see a project.
+func (h *Handler) canSeeProject(r *http.Request, projectID string) bool {
+	user, ok := auth.UserFrom(r.Context())
+	return ok && h.store.CanSeeProject(user, projectID)
+}
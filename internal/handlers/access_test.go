@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// newTestHandler returns a Handler backed by a fresh store in a temporary
+// directory.
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("ODOO_MANAGER_SECRET_KEY", "")
+	s, err := store.NewProjectStore(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return &Handler{store: s}
+}
+
+func TestRoutePolicies(t *testing.T) {
+	for pattern, p := range routePolicies {
+		if p.read == 0 || p.write == 0 {
+			t.Errorf("%s: read and write permissions must both be set", pattern)
+		}
+		if p.read > p.write {
+			t.Errorf("%s: reading needs more than writing", pattern)
+		}
+		if strings.Contains(pattern, "{id}") && !p.project {
+			t.Errorf("%s: takes a project ID but is not limited to the user's projects", pattern)
+		}
+	}
+}
+
+func TestEveryRouteHasAPolicy(t *testing.T) {
+	h := newTestHandler(t)
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("registering routes: %v", r)
+		}
+	}()
+	h.registerAppRoutes(http.NewServeMux())
+}
+
+func TestAuthorizeUnknownPatternPanics(t *testing.T) {
+	h := newTestHandler(t)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("authorize accepted a pattern without a policy")
+		}
+	}()
+	h.authorize("/api/not-a-route", func(http.ResponseWriter, *http.Request) {})
+}
+
+func TestAuthorize(t *testing.T) {
+	h := newTestHandler(t)
+	project := &store.Project{ID: "p1", Name: "Sales"}
+	if err := h.store.Create(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.store.SetProjectMembers("p1", []int64{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		admin      = &store.User{ID: 1, Username: "admin", Role: store.RoleAdmin}
+		member     = &store.User{ID: 2, Username: "dev", Role: store.RoleDeveloper}
+		viewer     = &store.User{ID: 3, Username: "viewer", Role: store.RoleViewer}
+		outsider   = &store.User{ID: 4, Username: "other", Role: store.RoleDeveloper}
+		maintainer = &store.User{ID: 5, Username: "lead", Role: store.RoleMaintainer}
+	)
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{
+		"/api/projects",
+		"/api/projects/{id}/start",
+		"/api/projects/{id}/repo/status",
+		"/api/backup/download/{id}/{filename}",
+		"/api/settings",
+	} {
+		mux.HandleFunc(pattern, h.authorize(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+
+	tests := []struct {
+		name   string
+		user   *store.User
+		method string
+		path   string
+		want   int
+	}{
+		{name: "anonymous", method: "GET", path: "/api/projects", want: http.StatusUnauthorized},
+		{name: "viewer lists projects", user: viewer, method: "GET", path: "/api/projects", want: http.StatusNoContent},
+		{name: "viewer cannot create", user: viewer, method: "POST", path: "/api/projects", want: http.StatusForbidden},
+		{name: "developer cannot create", user: member, method: "POST", path: "/api/projects", want: http.StatusForbidden},
+		{name: "maintainer creates", user: maintainer, method: "POST", path: "/api/projects", want: http.StatusNoContent},
+		{name: "member starts", user: member, method: "POST", path: "/api/projects/p1/start", want: http.StatusNoContent},
+		{name: "viewer member cannot start", user: viewer, method: "POST", path: "/api/projects/p1/start", want: http.StatusForbidden},
+		{name: "outsider cannot start", user: outsider, method: "POST", path: "/api/projects/p1/start", want: http.StatusForbidden},
+		{name: "maintainer starts any project", user: maintainer, method: "POST", path: "/api/projects/p1/start", want: http.StatusNoContent},
+		{name: "read uses the read permission", user: viewer, method: "GET", path: "/api/projects/p1/repo/status", want: http.StatusNoContent},
+		{name: "HEAD is a read", user: viewer, method: "HEAD", path: "/api/projects/p1/repo/status", want: http.StatusNoContent},
+		{name: "outsider cannot read", user: outsider, method: "GET", path: "/api/projects/p1/repo/status", want: http.StatusForbidden},
+		{name: "member downloads backups", user: member, method: "GET", path: "/api/backup/download/p1/backup.zip", want: http.StatusNoContent},
+		{name: "outsider cannot download backups", user: outsider, method: "GET", path: "/api/backup/download/p1/backup.zip", want: http.StatusForbidden},
+		{name: "admin reads settings", user: admin, method: "GET", path: "/api/settings", want: http.StatusNoContent},
+		{name: "maintainer cannot read settings", user: maintainer, method: "GET", path: "/api/settings", want: http.StatusForbidden},
+		{name: "unknown role", user: &store.User{ID: 6, Role: "superuser"}, method: "GET", path: "/api/projects", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.user != nil {
+				r = r.WithContext(auth.WithUser(r.Context(), tt.user))
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("%s %s as %v = %d, want %d (%s)", tt.method, tt.path, tt.user, w.Code, tt.want, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+}
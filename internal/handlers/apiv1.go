@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// apiV1Prefix is where the versioned API is served. Its routes share the
+// handlers and access policies of the first, unversioned API, which stays
+// available under /api for the web UI and existing scripts.
+const apiV1Prefix = "/api/v1"
+
+// apiRoute is an endpoint of the versioned API.
+type apiRoute struct {
+	method  string
+	path    string // below apiV1Prefix, with the wildcards of the legacy route
+	legacy  string // pattern of the route whose handler and policy it uses
+	tag     string
+	summary string
+	query   []string // optional query parameters
+	body    any      // request body, nil for none
+	upload  string   // content type of a non-JSON request body
+	resp    any      // response body, nil for none
+	status  int      // success status, 200 when zero
+	stream  bool     // server-sent events instead of JSON
+	raw     string   // content type of a non-JSON response
+}
+
+// apiStatus is the body of requests that succeed without returning data.
+type apiStatus struct {
+	Status string `json:"status"`
+}
+
+// apiError is the body of every failed /api/v1 request.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string         `json:"code"`              // e.g. "not_found", derived from the status
+	Message string         `json:"message"`           // human readable, as shown in the web UI
+	Details map[string]any `json:"details,omitempty"` // extra fields, e.g. the holder of a port
+}
+
+// apiRoutes lists the versioned API. Collections are plural nouns, a
+// resource is addressed by its ID, reads use GET and changes PUT, creation
+// POST on the collection, removal DELETE, and actions POST on a verb below
+// the resource.
+var apiRoutes = []apiRoute{
+	// Projects
+	{method: "GET", path: "/projects", legacy: "/api/projects", tag: "Projects", summary: "List the projects you can see", resp: []store.Project{}},
+	{method: "POST", path: "/projects", legacy: "/api/projects", tag: "Projects", summary: "Create a project; its containers are created in the background", body: store.Project{}, resp: store.Project{}, status: http.StatusCreated},
+	{method: "GET", path: "/projects/{id}", legacy: "/api/projects/{id}", tag: "Projects", summary: "Get a project", resp: store.Project{}},
+	{method: "PUT", path: "/projects/{id}", legacy: "/api/projects/{id}", tag: "Projects", summary: "Rename a project or change its description", body: struct {
+		Name        string `json:"name,omitempty"`
+		Description string `json:"description,omitempty"`
+	}{}, resp: store.Project{}},
+	{method: "DELETE", path: "/projects/{id}", legacy: "/api/projects/{id}", tag: "Projects", summary: "Delete a project, its containers and volumes", status: http.StatusAccepted},
+	{method: "POST", path: "/projects/{id}/start", legacy: "/api/projects/{id}/start", tag: "Projects", summary: "Start a project", resp: store.Project{}, status: http.StatusAccepted},
+	{method: "POST", path: "/projects/{id}/stop", legacy: "/api/projects/{id}/stop", tag: "Projects", summary: "Stop a project", resp: store.Project{}, status: http.StatusAccepted},
+	{method: "POST", path: "/projects/{id}/odoo/restart", legacy: "/api/projects/{id}/restart-odoo", tag: "Projects", summary: "Restart only the Odoo container", status: http.StatusAccepted},
+	{method: "POST", path: "/projects/{id}/odoo/update", legacy: "/api/projects/{id}/update-odoo", tag: "Projects", summary: "Pull the latest Odoo image, recreate the container and pull the repos", status: http.StatusAccepted},
+	{method: "GET", path: "/projects/{id}/logs", legacy: "/api/projects/{id}/logs", tag: "Projects", summary: "Follow the logs of the odoo or db container", query: []string{"container"}, stream: true},
+	{method: "GET", path: "/projects/{id}/databases", legacy: "/api/projects/{id}/databases", tag: "Projects", summary: "List the project's databases", resp: []string{}},
+	{method: "POST", path: "/projects/{id}/backups", legacy: "/api/projects/{id}/backup", tag: "Backups", summary: "Back up a database, streaming progress; the complete event holds the download path", query: []string{"db"}, stream: true},
+	{method: "GET", path: "/projects/{id}/backups/{filename}", legacy: "/api/backup/download/{id}/{filename}", tag: "Backups", summary: "Download a backup once; it is deleted afterwards", raw: "application/zip"},
+	{method: "POST", path: "/projects/{id}/restore", legacy: "/api/projects/{id}/restore", tag: "Backups", summary: "Restore a backup zip (the request body) into a database, replacing it, streaming progress", query: []string{"db", "neutralize"}, upload: "application/zip", stream: true},
+	{method: "POST", path: "/projects/{id}/modules/upgrade", legacy: "/api/projects/{id}/modules/upgrade", tag: "Projects", summary: "Upgrade modules of a database, streaming the output", body: struct {
+		Database string   `json:"database"`
+		Modules  []string `json:"modules"`
+	}{}, stream: true},
+	{method: "POST", path: "/projects/{id}/shell", legacy: "/api/projects/{id}/shell", tag: "Projects", summary: "Open an interactive odoo shell; send Connection: Upgrade and Upgrade: odoo-shell, the 101 response carries the raw terminal", query: []string{"db", "rows", "cols"}, status: http.StatusSwitchingProtocols},
+	{method: "GET", path: "/projects/{id}/config", legacy: "/api/projects/{id}/config", tag: "Configuration", summary: "Read odoo.conf", resp: struct {
+		Content string `json:"content"`
+	}{}},
+	{method: "PUT", path: "/projects/{id}/config", legacy: "/api/projects/{id}/config", tag: "Configuration", summary: "Write odoo.conf", body: struct {
+		Content string `json:"content"`
+	}{}, resp: apiStatus{}},
+	{method: "GET", path: "/projects/{id}/ports", legacy: "/api/projects/{id}/ports", tag: "Configuration", summary: "Get the published ports and bind address"},
+	{method: "PUT", path: "/projects/{id}/ports", legacy: "/api/projects/{id}/ports", tag: "Configuration", summary: "Change the optional ports and bind address", body: struct {
+		GeventPort  *int    `json:"gevent_port,omitempty"`
+		DebugPort   *int    `json:"debug_port,omitempty"`
+		DBPort      *int    `json:"db_port,omitempty"`
+		BindAddress *string `json:"bind_address,omitempty"`
+	}{}},
+	{method: "GET", path: "/projects/{id}/db-credentials", legacy: "/api/projects/{id}/db-credentials", tag: "Configuration", summary: "Get the PostgreSQL login", resp: struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}{}},
+	{method: "GET", path: "/projects/{id}/master-password", legacy: "/api/projects/{id}/master-password", tag: "Configuration", summary: "Get the Odoo master password", resp: struct {
+		Password string `json:"password"`
+		Hashed   bool   `json:"hashed"`
+	}{}},
+	{method: "POST", path: "/projects/{id}/master-password", legacy: "/api/projects/{id}/master-password", tag: "Configuration", summary: "Generate a new Odoo master password", resp: struct {
+		Password string `json:"password"`
+	}{}},
+	{method: "GET", path: "/projects/{id}/members", legacy: "/api/projects/{id}/members", tag: "Configuration", summary: "List the users assigned to the project"},
+	{method: "PUT", path: "/projects/{id}/members", legacy: "/api/projects/{id}/members", tag: "Configuration", summary: "Replace the users assigned to the project", body: struct {
+		MemberIDs []int64 `json:"member_ids"`
+	}{}, resp: apiStatus{}},
+	{method: "GET", path: "/projects/{id}/webhook", legacy: "/api/projects/{id}/webhook", tag: "Configuration", summary: "Get whether push webhooks are enabled", resp: struct {
+		Enabled bool   `json:"enabled"`
+		Path    string `json:"path"`
+	}{}},
+	{method: "POST", path: "/projects/{id}/webhook", legacy: "/api/projects/{id}/webhook", tag: "Configuration", summary: "Generate a new webhook secret, shown once", resp: struct {
+		Secret string `json:"secret"`
+	}{}},
+	{method: "DELETE", path: "/projects/{id}/webhook", legacy: "/api/projects/{id}/webhook", tag: "Configuration", summary: "Disable push webhooks"},
+
+	// Repositories
+	{method: "PUT", path: "/projects/{id}/repo", legacy: "/api/projects/{id}/repo", tag: "Repositories", summary: "Set the addons repository or local folder", body: struct {
+		GitRepoURL          string `json:"git_repo_url"`
+		GitRepoBranch       string `json:"git_repo_branch"`
+		LocalAddonsPath     string `json:"local_addons_path"`
+		EnterpriseEnabled   *bool  `json:"enterprise_enabled,omitempty"`
+		DesignThemesEnabled *bool  `json:"design_themes_enabled,omitempty"`
+	}{}, resp: apiStatus{}},
+	{method: "GET", path: "/projects/{id}/repo/status", legacy: "/api/projects/{id}/repo/status", tag: "Repositories", summary: "Get the branch, ahead/behind counts and changed files", query: []string{"repo"}},
+	{method: "GET", path: "/projects/{id}/repo/log", legacy: "/api/projects/{id}/repo/log", tag: "Repositories", summary: "List the most recent commits", query: []string{"repo", "limit"}},
+	{method: "GET", path: "/projects/{id}/repo/diff", legacy: "/api/projects/{id}/repo/diff", tag: "Repositories", summary: "Get the uncommitted changes", query: []string{"repo", "path"}, raw: "text/plain"},
+	{method: "POST", path: "/projects/{id}/repo/update", legacy: "/api/projects/{id}/update-repo", tag: "Repositories", summary: "Pull the repos and restart Odoo when needed; 409 lists uncommitted changes unless force is set", query: []string{"force"}, status: http.StatusAccepted},
+	{method: "GET", path: "/projects/{id}/repo/revisions", legacy: "/api/projects/{id}/revisions", tag: "Repositories", summary: "Get the current revision and its history", query: []string{"limit"}, resp: struct {
+		Current *store.RepoRevision  `json:"current"`
+		History []store.RepoRevision `json:"history"`
+		Pinned  bool                 `json:"pinned"` // a rollback keeps the repos on Current until the next update
+	}{}},
+	{method: "POST", path: "/projects/{id}/repo/rollback", legacy: "/api/projects/{id}/rollback", tag: "Repositories", summary: "Check out the commits of an earlier revision", query: []string{"force"}, body: struct {
+		RevisionID int64 `json:"revision_id"`
+	}{}, status: http.StatusAccepted},
+	{method: "GET", path: "/repo/branches", legacy: "/api/repo/branches", tag: "Repositories", summary: "List the branches of a repository", query: []string{"url"}, resp: []string{}},
+	{method: "GET", path: "/enterprise/access", legacy: "/api/enterprise/check-access", tag: "Repositories", summary: "Check that the GitHub token can read Odoo Enterprise", resp: struct {
+		Accessible bool   `json:"accessible"`
+		Error      string `json:"error,omitempty"`
+	}{}},
+	{method: "GET", path: "/design-themes/access", legacy: "/api/design-themes/check-access", tag: "Repositories", summary: "Check that the GitHub token can read the design themes", resp: struct {
+		Accessible bool   `json:"accessible"`
+		Error      string `json:"error,omitempty"`
+	}{}},
+	{method: "GET", path: "/updates", legacy: "/api/updates", tag: "Repositories", summary: "Get the last update check of every project, keyed by project ID", resp: map[string]ProjectUpdates{}},
+
+	// Previews
+	{method: "GET", path: "/previews", legacy: "/api/previews", tag: "Previews", summary: "List branch preview environments", resp: []store.Project{}},
+	{method: "POST", path: "/previews", legacy: "/api/previews", tag: "Previews", summary: "Create a preview of a branch from a template project", body: previewRequest{}, resp: store.Project{}, status: http.StatusCreated},
+
+	// Settings, users and API tokens
+	{method: "GET", path: "/settings", legacy: "/api/settings", tag: "Settings", summary: "Get the global settings; secrets are masked", resp: map[string]string{}},
+	{method: "PUT", path: "/settings", legacy: "/api/settings", tag: "Settings", summary: "Change any subset of the global settings", body: map[string]any{}, resp: apiStatus{}},
+	{method: "POST", path: "/settings/github-token/validate", legacy: "/api/settings/validate-token", tag: "Settings", summary: "Check the stored GitHub token", resp: apiStatus{}},
+	{method: "GET", path: "/users", legacy: "/api/users", tag: "Users", summary: "List user accounts", resp: []store.User{}},
+	{method: "POST", path: "/users", legacy: "/api/users", tag: "Users", summary: "Create a user account", body: struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}{}, resp: store.User{}, status: http.StatusCreated},
+	{method: "PUT", path: "/users/{uid}", legacy: "/api/users/{uid}", tag: "Users", summary: "Change a user's role or password", body: struct {
+		Role     string `json:"role,omitempty"`
+		Password string `json:"password,omitempty"`
+	}{}, resp: apiStatus{}},
+	{method: "DELETE", path: "/users/{uid}", legacy: "/api/users/{uid}", tag: "Users", summary: "Delete a user account", resp: apiStatus{}},
+	{method: "PUT", path: "/account/password", legacy: "/api/account/password", tag: "Users", summary: "Change your own password (session only)", body: struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}{}, resp: apiStatus{}},
+	{method: "GET", path: "/tokens", legacy: "/api/tokens", tag: "Users", summary: "List your API tokens (session only)", resp: []store.APIToken{}},
+	{method: "POST", path: "/tokens", legacy: "/api/tokens", tag: "Users", summary: "Create an API token, shown once (session only)", body: struct {
+		Name          string `json:"name"`
+		Scope         string `json:"scope"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/tokens/{tid}", legacy: "/api/tokens/{tid}", tag: "Users", summary: "Revoke one of your API tokens (session only)", resp: apiStatus{}},
+
+	// Maintenance
+	{method: "GET", path: "/maintenance/containers", legacy: "/api/maintenance/preview-containers", tag: "Maintenance", summary: "List orphaned containers", resp: []string{}},
+	{method: "GET", path: "/maintenance/volumes", legacy: "/api/maintenance/preview-volumes", tag: "Maintenance", summary: "List orphaned volumes", resp: []string{}},
+	{method: "GET", path: "/maintenance/images", legacy: "/api/maintenance/preview-images", tag: "Maintenance", summary: "List unused images", resp: []string{}},
+	{method: "GET", path: "/maintenance/networks", legacy: "/api/maintenance/preview-networks", tag: "Maintenance", summary: "List orphaned project networks", resp: []string{}},
+	{method: "POST", path: "/maintenance/containers/clean", legacy: "/api/maintenance/clean-containers", tag: "Maintenance", summary: "Remove orphaned containers"},
+	{method: "POST", path: "/maintenance/volumes/clean", legacy: "/api/maintenance/clean-volumes", tag: "Maintenance", summary: "Remove orphaned volumes"},
+	{method: "POST", path: "/maintenance/images/clean", legacy: "/api/maintenance/clean-images", tag: "Maintenance", summary: "Remove unused images"},
+	{method: "POST", path: "/maintenance/networks/clean", legacy: "/api/maintenance/clean-networks", tag: "Maintenance", summary: "Remove orphaned project networks"},
+
+	// Audit log and events
+	{method: "GET", path: "/audit/logs", legacy: "/api/audit/logs", tag: "Audit", summary: "Page through the audit log, newest last", query: []string{"limit", "before"}, resp: struct {
+		Lines  []string `json:"lines"`
+		Offset int      `json:"offset"`
+	}{}},
+	{method: "GET", path: "/audit/stream", legacy: "/api/audit/stream", tag: "Audit", summary: "Follow new audit entries", stream: true},
+	{method: "GET", path: "/events", legacy: "/api/events", tag: "Events", summary: "Follow project and Docker status changes", stream: true},
+}
+
+// registerAPIv1 adds the versioned routes to mux, reusing the authorized
+// handlers of the legacy routes they map to.
+func (h *Handler) registerAPIv1(mux *http.ServeMux, legacy map[string]http.HandlerFunc) {
+	for _, rt := range apiRoutes {
+		handler, ok := legacy[rt.legacy]
+		if !ok {
+			panic("handlers: API route " + rt.method + " " + rt.path + " maps to unknown route " + rt.legacy)
+		}
+		mux.HandleFunc(rt.method+" "+apiV1Prefix+rt.path, handler)
+	}
+	mux.HandleFunc(apiV1Prefix+"/", h.authorize(apiV1Prefix+"/", h.handleAPINotFound))
+}
+
+// handleAPINotFound answers paths and methods of /api/v1 that match no
+// route. Method-specific patterns would otherwise fall through to the
+// dashboard's catch-all route.
+func (h *Handler) handleAPINotFound(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, rt := range apiRoutes {
+		if pathMatches(rt.path, strings.TrimPrefix(r.URL.Path, apiV1Prefix)) {
+			allowed = append(allowed, rt.method)
+		}
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.Error(w, "Not found", http.StatusNotFound)
+}
+
+// pathMatches reports whether path matches a route path with wildcards.
+func pathMatches(pattern, path string) bool {
+	want := strings.Split(pattern, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if want[i] != got[i] && !(strings.HasPrefix(want[i], "{") && got[i] != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// withAPIErrors gives every failed /api/v1 response, including those of
+// authentication, access checks and the router, the same JSON envelope:
+// { "error": { "code": "not_found", "message": "Project not found" } }.
+// Handlers keep writing plain errors; other paths are left untouched.
+func withAPIErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != apiV1Prefix && !strings.HasPrefix(r.URL.Path, apiV1Prefix+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &apiErrorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// apiErrorWriter holds back error responses so they can be rewritten into
+// the envelope once complete. Successful responses, event streams
+// included, pass straight through.
+type apiErrorWriter struct {
+	http.ResponseWriter
+	status int // error status held back, 0 while the response succeeds
+	body   bytes.Buffer
+}
+
+func (w *apiErrorWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest {
+		if w.status == 0 {
+			w.status = code
+		}
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *apiErrorWriter) Write(b []byte) (int, error) {
+	if w.status != 0 {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *apiErrorWriter) Flush() {
+	if w.status != 0 {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift
+// the write deadline of event streams.
+func (w *apiErrorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes the held back error, if any, as the envelope. A JSON error
+// body keeps its other fields as details.
+func (w *apiErrorWriter) finish() {
+	if w.status == 0 {
+		return
+	}
+	e := apiErrorBody{Code: errorCode(w.status), Message: strings.TrimSpace(w.body.String())}
+	var fields map[string]any
+	if json.Unmarshal(w.body.Bytes(), &fields) == nil {
+		if msg, ok := fields["error"].(string); ok {
+			e.Message = msg
+			delete(fields, "error")
+		}
+		if len(fields) > 0 {
+			e.Details = fields
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(w.status)
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.ResponseWriter.WriteHeader(w.status)
+	json.NewEncoder(w.ResponseWriter).Encode(apiError{Error: e})
+}
+
+// errorCode returns the machine-readable code of an error status.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "bad_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	}
+	if status >= http.StatusInternalServerError {
+		return "internal_error"
+	}
+	return "error"
+}
+
+// handleOpenAPI serves the OpenAPI 3 description of the versioned API.
+// GET /api/v1/openapi.json
+func (h *Handler) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument())
+}
+
+// openAPIDocument is generated once from apiRoutes, with schemas derived
+// from the Go types of request and response bodies.
+var openAPIDocument = sync.OnceValue(func() []byte {
+	g := &schemaGen{components: map[string]any{}}
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(apiError{}))}},
+	}
+
+	paths := map[string]map[string]any{}
+	for _, rt := range apiRoutes {
+		op := map[string]any{
+			"operationId": operationID(rt),
+			"summary":     rt.summary,
+			"tags":        []string{rt.tag},
+		}
+
+		var params []map[string]any
+		for _, name := range pathParams.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{"name": name[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		for _, name := range rt.query {
+			params = append(params, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": "string"}})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		switch {
+		case rt.body != nil:
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.body))}},
+			}
+		case rt.upload != "":
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{rt.upload: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}},
+			}
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]any{"description": http.StatusText(status)}
+		switch {
+		case rt.stream:
+			success["content"] = map[string]any{"text/event-stream": map[string]any{"schema": map[string]any{"type": "string"}}}
+		case rt.raw != "":
+			success["content"] = map[string]any{rt.raw: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+		case rt.resp != nil:
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(rt.resp))}}
+		}
+		op["responses"] = map[string]any{strconv.Itoa(status): success, "default": errorResponse}
+
+		if paths[apiV1Prefix+rt.path] == nil {
+			paths[apiV1Prefix+rt.path] = map[string]any{}
+		}
+		paths[apiV1Prefix+rt.path][strings.ToLower(rt.method)] = op
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Odoo Manager API",
+			"version":     "1",
+			"description": "Authenticate with an API token in an \"Authorization: Bearer\" header, or with a browser session and its X-CSRF-Token header. Errors use the Error schema.",
+		},
+		"servers": []map[string]any{{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"securitySchemes": map[string]any{
+				"token":   map[string]any{"type": "http", "scheme": "bearer"},
+				"session": map[string]any{"type": "apiKey", "in": "cookie", "name": sessionCookie},
+			},
+		},
+		"security": []map[string]any{{"token": []string{}}, {"session": []string{}}},
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic("handlers: cannot encode OpenAPI document: " + err.Error())
+	}
+	return out
+})
+
+var pathParams = regexp.MustCompile(`\{(\w+)\}`)
+
+// operationID names an operation after its method and path, e.g.
+// "post_projects_id_start".
+func operationID(rt apiRoute) string {
+	path := strings.NewReplacer("{", "", "}", "", "-", "_", ".", "_").Replace(rt.path)
+	return strings.ToLower(rt.method) + strings.ReplaceAll(path, "/", "_")
+}
+
+// schemaGen derives JSON schemas from Go types. Named structs become
+// shared components; anonymous ones are inlined.
+type schemaGen struct {
+	components map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		s := g.schema(t.Elem())
+		if _, ref := s["$ref"]; !ref {
+			s["nullable"] = true
+		}
+		return s
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		// apiError becomes Error, previewRequest PreviewRequest
+		name := strings.TrimPrefix(t.Name(), "api")
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = map[string]any{} // placeholder for recursive types
+			g.components[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.object(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// object returns the schema of a struct from its exported JSON fields.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+	}
+	return map[string]any{"type": "object", "properties": props}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestPathMatches(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/projects", "/projects", true},
+		{"/projects/{id}", "/projects/p1", true},
+		{"/projects/{id}/start", "/projects/p1/start", true},
+		{"/backups/{id}/{filename}", "/backups/p1/backup.zip", true},
+		{"/projects", "/projects/", false},
+		{"/projects/{id}", "/projects/", false},
+		{"/projects/{id}", "/projects/p1/start", false},
+		{"/projects/{id}/start", "/projects/p1/stop", false},
+		{"/projects/{id}/start", "/projects//start", false},
+		{"/projects", "/Projects", false},
+		{"/projects", "", false},
+	}
+	for _, tt := range tests {
+		if got := pathMatches(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("pathMatches(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAPIRoutes(t *testing.T) {
+	wildcard := regexp.MustCompile(`\{[a-z]+\}`)
+	seen := map[string]bool{}
+	for _, rt := range apiRoutes {
+		key := rt.method + " " + rt.path
+		if seen[key] {
+			t.Errorf("%s: listed twice", key)
+		}
+		seen[key] = true
+		if _, ok := routePolicies[rt.legacy]; !ok {
+			t.Errorf("%s: legacy route %s has no access policy", key, rt.legacy)
+		}
+		// The legacy handler reads the wildcards by name
+		legacy := map[string]bool{}
+		for _, w := range wildcard.FindAllString(rt.legacy, -1) {
+			legacy[w] = true
+		}
+		for _, w := range wildcard.FindAllString(rt.path, -1) {
+			if !legacy[w] {
+				t.Errorf("%s: wildcard %s is not one of %s", key, w, rt.legacy)
+			}
+		}
+		if rt.summary == "" || rt.tag == "" {
+			t.Errorf("%s: missing summary or tag", key)
+		}
+	}
+}
+
+func TestWithAPIErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		handler     http.HandlerFunc
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantDetails map[string]any
+		wantBody    string // for responses left as they are
+	}{
+		{
+			name: "plain error",
+			path: "/api/v1/projects/p1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Project not found", http.StatusNotFound)
+			},
+			wantStatus: http.StatusNotFound, wantCode: "not_found", wantMessage: "Project not found",
+		},
+		{
+			name: "JSON error keeps its other fields",
+			path: "/api/v1/projects",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]any{"error": "Port 8069 is in use", "port": 8069.0})
+			},
+			wantStatus: http.StatusConflict, wantCode: "conflict", wantMessage: "Port 8069 is in use",
+			wantDetails: map[string]any{"port": 8069.0},
+		},
+		{
+			name: "empty error body",
+			path: "/api/v1/settings",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			},
+			wantStatus: http.StatusForbidden, wantCode: "forbidden", wantMessage: "Forbidden",
+		},
+		{
+			name: "server error",
+			path: "/api/v1/projects",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "database is locked", http.StatusInternalServerError)
+			},
+			wantStatus: http.StatusInternalServerError, wantCode: "internal_error", wantMessage: "database is locked",
+		},
+		{
+			name: "success passes through",
+			path: "/api/v1/projects",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("[]"))
+			},
+			wantStatus: http.StatusOK, wantBody: "[]",
+		},
+		{
+			name: "legacy API is left alone",
+			path: "/api/projects/p1",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Project not found", http.StatusNotFound)
+			},
+			wantStatus: http.StatusNotFound, wantBody: "Project not found\n",
+		},
+		{
+			name: "look-alike prefix is left alone",
+			path: "/api/v1x",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Not found", http.StatusNotFound)
+			},
+			wantStatus: http.StatusNotFound, wantBody: "Not found\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			withAPIErrors(tt.handler).ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantCode == "" {
+				if w.Body.String() != tt.wantBody {
+					t.Fatalf("body = %q, want %q", w.Body.String(), tt.wantBody)
+				}
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var got apiError
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q is not an error envelope: %v", w.Body.String(), err)
+			}
+			if got.Error.Code != tt.wantCode || got.Error.Message != tt.wantMessage {
+				t.Errorf("error = %+v, want code %q and message %q", got.Error, tt.wantCode, tt.wantMessage)
+			}
+			if len(got.Error.Details) != len(tt.wantDetails) {
+				t.Errorf("details = %v, want %v", got.Error.Details, tt.wantDetails)
+			}
+			for k, v := range tt.wantDetails {
+				if got.Error.Details[k] != v {
+					t.Errorf("details[%s] = %v, want %v", k, got.Error.Details[k], v)
+				}
+			}
+		})
+	}
+}
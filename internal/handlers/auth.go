@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/templates"
+)
+
+const (
+	sessionCookie     = "odoo_manager_session"
+	sessionTTL        = 7 * 24 * time.Hour
+	minPasswordLength = 8
+)
+
+// requireAuth lets requests with a valid session cookie, or API calls with a
+// valid "Authorization: Bearer" token, through and stores the signed-in user
+// in their context. Anonymous page loads are redirected to the login page;
+// API calls, SSE streams and SPA navigations get a 401. Requests from other
+// sites, and session requests changing state without the CSRF token, get a
+// 403.
+func (h *Handler) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason, ok := h.checkOrigin(r); !ok {
+			h.deny(w, r, reason)
+			return
+		}
+		h.setCORSHeaders(w, r)
+		if isPreflight(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if value, ok := bearerToken(r); ok && strings.HasPrefix(r.URL.Path, "/api/") {
+			token, user, ok := h.store.APITokenUser(value)
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired API token"})
+				return
+			}
+			ctx := auth.WithToken(auth.WithUser(r.Context(), user), token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			if user, ok := h.store.SessionUser(cookie.Value); ok {
+				ctx := auth.WithCSRFToken(auth.WithUser(r.Context(), user), csrfToken(cookie.Value))
+				if !checkCSRF(r, cookie.Value) {
+					h.deny(w, r.WithContext(ctx), "Forbidden: missing or invalid CSRF token, reload the page")
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/api/") || r.Header.Get("X-Spa") == "1" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+			return
+		}
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	return value, value != ""
+}
+
+// safeRedirect returns next when it is a path on this site, "/" otherwise,
+// so the login form cannot be used to redirect to another host.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// setSessionCookie sends the session cookie; an empty token clears it.
+func (h *Handler) setSessionCookie(w http.ResponseWriter, token string) {
+	cookie := &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   h.tlsMode != "",
+		SameSite: http.SameSiteLaxMode,
+	}
+	if token == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = time.Now().Add(sessionTTL)
+	}
+	http.SetCookie(w, cookie)
+}
+
+// handleLogin renders the login form and signs users in.
+// GET  /login?next=/path
+// POST /login (form: username, password, next)
+func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		next := safeRedirect(r.URL.Query().Get("next"))
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			if _, ok := h.store.SessionUser(cookie.Value); ok {
+				http.Redirect(w, r, next, http.StatusSeeOther)
+				return
+			}
+		}
+		templates.Login("", next, "", h.oidcEnabled()).Render(r.Context(), w)
+
+	case http.MethodPost:
+		// There is no session to take a CSRF token from yet, so only the
+		// origin keeps other sites from signing the browser in
+		if reason, ok := h.checkOrigin(r); !ok {
+			h.deny(w, r, reason)
+			return
+		}
+		username := strings.TrimSpace(r.FormValue("username"))
+		next := safeRedirect(r.FormValue("next"))
+
+		user, ok := h.store.Authenticate(username, r.FormValue("password"))
+		if !ok {
+			if h.audit != nil {
+				h.audit.Log(r, fmt.Sprintf("Failed sign-in as %q", username))
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			templates.Login(username, next, "Invalid username or password", h.oidcEnabled()).Render(r.Context(), w)
+			return
+		}
+
+		token, err := h.store.CreateSession(user.ID, sessionTTL)
+		if err != nil {
+			http.Error(w, "Failed to create session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.setSessionCookie(w, token)
+		if h.audit != nil {
+			h.audit.Log(r.WithContext(auth.WithUser(r.Context(), user)), "Signed in")
+		}
+		http.Redirect(w, r, next, http.StatusSeeOther)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLogout ends the current session.
+// POST /logout (form: csrf_token)
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if reason, ok := h.checkOrigin(r); !ok {
+		h.deny(w, r, reason)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if !checkCSRF(r, cookie.Value) {
+			h.deny(w, r, "Forbidden: missing or invalid CSRF token, reload the page")
+			return
+		}
+		if user, ok := h.store.SessionUser(cookie.Value); ok && h.audit != nil {
+			h.audit.Log(r.WithContext(auth.WithUser(r.Context(), user)), "Signed out")
+		}
+		_ = h.store.DeleteSession(cookie.Value)
+	}
+	h.setSessionCookie(w, "")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// handleAccountPassword changes the signed-in user's password and ends their
+// other sessions.
+// PUT /api/account/password { "current_password": "...", "new_password": "..." }
+func (h *Handler) handleAccountPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := auth.UserFrom(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if user.OIDCSubject != "" {
+		http.Error(w, "Your account signs in through single sign-on and has no password", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.store.Authenticate(user.Username, req.CurrentPassword); !ok {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		http.Error(w, fmt.Sprintf("New password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SetUserPassword(user.ID, req.NewPassword); err != nil {
+		http.Error(w, "Failed to change password: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	keep := ""
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		keep = cookie.Value
+	}
+	_ = h.store.DeleteUserSessions(user.ID, keep)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/docker"
+	"github.com/jota2rz/odoo-manager/internal/events"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// moduleNamePattern matches Odoo module (technical) names.
+var moduleNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// shellProtocol is the Upgrade token of interactive shell connections.
+const shellProtocol = "odoo-shell"
+
+// defaultMaxRestoreSize is the largest backup a restore accepts unless
+// SetMaxRestoreSize changes it.
+const defaultMaxRestoreSize = 10 << 30 // 10 GiB
+
+// restoreUploadDir holds restore uploads while they are loaded. It is kept
+// apart from data/backups so the backup download route cannot serve them.
+const restoreUploadDir = "data/restores"
+
+// SetMaxRestoreSize sets the largest backup zip, in bytes, that a restore
+// accepts. Call it before serving requests.
+func (h *Handler) SetMaxRestoreSize(n int64) {
+	h.maxRestoreSize = n
+}
+
+// databaseJob checks that a project exists and is running, and claims its
+// backup slot so backups, restores and upgrades never overlap. It writes
+// the error response and returns ok=false when the job cannot run; on
+// success the caller MUST call done.
+func (h *Handler) databaseJob(w http.ResponseWriter, r *http.Request, action string) (project *store.Project, dm *docker.Manager, done func(), ok bool) {
+	id := r.PathValue("id")
+	project, ok = h.store.Get(id)
+	if !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+
+	h.dockerMu.RLock()
+	dm = h.dockerManager
+	h.dockerMu.RUnlock()
+	if dm == nil {
+		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
+		return nil, nil, nil, false
+	}
+	if dm.ReconcileStatus(r.Context(), project) != "running" {
+		http.Error(w, fmt.Sprintf("Project must be running to %s", action), http.StatusConflict)
+		return nil, nil, nil, false
+	}
+
+	h.backupMu.Lock()
+	if h.backupsRunning[id] {
+		h.backupMu.Unlock()
+		http.Error(w, "A backup, restore or upgrade is already in progress for this project", http.StatusConflict)
+		return nil, nil, nil, false
+	}
+	h.backupsRunning[id] = true
+	h.backupMu.Unlock()
+
+	// Broadcast backup-pending so all browsers disable the backup button
+	h.events.Publish(events.Event{Type: events.ProjectBackupPending, ProjectID: id})
+	done = func() {
+		h.backupMu.Lock()
+		delete(h.backupsRunning, id)
+		h.backupMu.Unlock()
+		h.events.Publish(events.Event{Type: events.ProjectBackupDone, ProjectID: id})
+	}
+	return project, dm, done, true
+}
+
+// streamJob sends the console output of a database job as SSE, in the
+// format of the backup stream: a data line per output line, then a
+// "complete" event with message or an "error" event.
+func streamJob(w http.ResponseWriter, r *http.Request, dm *docker.Manager, logReader io.Reader, execID, message string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	scanner := bufio.NewScanner(logReader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			flusher.Flush()
+		}
+	}
+
+	exitCode, err := dm.WaitExec(r.Context(), execID)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "event: error\ndata: Failed waiting for the process: %v\n\n", err)
+	case exitCode != 0:
+		fmt.Fprintf(w, "event: error\ndata: Command exited with code %d\n\n", exitCode)
+	default:
+		fmt.Fprintf(w, "event: complete\ndata: %s\n\n", message)
+	}
+	flusher.Flush()
+}
+
+// handleRestoreProject restores a backup zip (as made by the backup
+// endpoint) into a project's database, replacing it, and streams the
+// output via SSE. ?neutralize=true disables outgoing mail servers and
+// scheduled actions in the restored copy. Uploads larger than the restore
+// limit are refused with 413.
+// POST /api/projects/{id}/restore?db=NAME&neutralize=true (body: the zip)
+func (h *Handler) handleRestoreProject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dbName := r.URL.Query().Get("db")
+	if !dbNamePattern.MatchString(dbName) {
+		http.Error(w, "db must be a database name such as odoo or prod_copy", http.StatusBadRequest)
+		return
+	}
+
+	tooLarge := fmt.Sprintf("The backup is larger than the restore limit of %d MB", h.maxRestoreSize>>20)
+	if r.ContentLength > h.maxRestoreSize {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	project, dm, done, ok := h.databaseJob(w, r, "restore a backup")
+	if !ok {
+		return
+	}
+	defer done()
+
+	// Backups can take longer to upload than the server's ReadTimeout
+	_ = http.NewResponseController(w).SetReadDeadline(time.Time{})
+
+	if err := os.MkdirAll(restoreUploadDir, 0o755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmp, err := os.CreateTemp(restoreUploadDir, "restore-*.zip")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, http.MaxBytesReader(w, r.Body, h.maxRestoreSize))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, tooLarge, http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to receive the backup: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !isZipFile(tmp.Name()) {
+		http.Error(w, "The body must be a backup zip", http.StatusBadRequest)
+		return
+	}
+
+	logReader, execID, cleanup, err := dm.RestoreDatabase(r.Context(), project, dbName, tmp.Name(), isTruthy(r.URL.Query().Get("neutralize")))
+	if err != nil {
+		http.Error(w, "Failed to start restore: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cleanup()
+	streamJob(w, r, dm, logReader, execID, fmt.Sprintf("Database %s restored", dbName))
+}
+
+// isZipFile reports whether the file at path starts like a zip archive.
+func isZipFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	_, err = io.ReadFull(f, magic)
+	return err == nil && bytes.Equal(magic, []byte("PK\x03\x04"))
+}
+
+// handleUpgradeModules upgrades modules of a project's database ("odoo -u")
+// and streams the output via SSE. "all" upgrades every installed module.
+// POST /api/projects/{id}/modules/upgrade { "database": "odoo", "modules": ["sale", "my_module"] }
+func (h *Handler) handleUpgradeModules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Database string   `json:"database"`
+		Modules  []string `json:"modules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !dbNamePattern.MatchString(body.Database) {
+		http.Error(w, "database must be a database name such as odoo or prod_copy", http.StatusBadRequest)
+		return
+	}
+	if len(body.Modules) == 0 {
+		http.Error(w, "modules must list at least one module, or all", http.StatusBadRequest)
+		return
+	}
+	for _, m := range body.Modules {
+		if !moduleNamePattern.MatchString(m) {
+			http.Error(w, fmt.Sprintf("Invalid module name %q", m), http.StatusBadRequest)
+			return
+		}
+	}
+
+	project, dm, done, ok := h.databaseJob(w, r, "upgrade modules")
+	if !ok {
+		return
+	}
+	defer done()
+
+	logReader, execID, cleanup, err := dm.UpgradeModules(r.Context(), project, body.Database, body.Modules)
+	if err != nil {
+		http.Error(w, "Failed to start upgrade: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cleanup()
+	streamJob(w, r, dm, logReader, execID, fmt.Sprintf("Upgraded %s on %s", strings.Join(body.Modules, ", "), body.Database))
+}
+
+// handleProjectShell opens an interactive "odoo shell" on a project's
+// database. The request must ask to switch to the odoo-shell protocol;
+// after the 101 response the connection carries the raw terminal in both
+// directions until the shell exits or the client disconnects.
+// POST /api/projects/{id}/shell?db=NAME&rows=N&cols=N (Connection: Upgrade, Upgrade: odoo-shell)
+func (h *Handler) handleProjectShell(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), shellProtocol) {
+		w.Header().Set("Upgrade", shellProtocol)
+		http.Error(w, "The shell requires Upgrade: "+shellProtocol, http.StatusUpgradeRequired)
+		return
+	}
+	dbName := r.URL.Query().Get("db")
+	if !dbNamePattern.MatchString(dbName) {
+		http.Error(w, "db must be a database name such as odoo or prod_copy", http.StatusBadRequest)
+		return
+	}
+	rows, _ := strconv.ParseUint(r.URL.Query().Get("rows"), 10, 16)
+	cols, _ := strconv.ParseUint(r.URL.Query().Get("cols"), 10, 16)
+
+	project, ok := h.store.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	h.dockerMu.RLock()
+	dm := h.dockerManager
+	h.dockerMu.RUnlock()
+	if dm == nil {
+		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
+		return
+	}
+	if dm.ReconcileStatus(r.Context(), project) != "running" {
+		http.Error(w, "Project must be running to open a shell", http.StatusConflict)
+		return
+	}
+
+	output, input, cleanup, err := dm.OpenShell(r.Context(), project, dbName, uint(rows), uint(cols))
+	if err != nil {
+		http.Error(w, "Failed to open shell: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer cleanup()
+
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "Shell connections need HTTP/1.1: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Time{})
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", shellProtocol)
+	if err := buf.Flush(); err != nil {
+		return
+	}
+
+	// Keystrokes go to the shell; when the client hangs up the exec is
+	// closed, which also ends the copy of its output below
+	go func() {
+		io.Copy(input, buf.Reader)
+		cleanup()
+	}()
+	io.Copy(conn, output)
+}
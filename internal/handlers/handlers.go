@@ -4,12 +4,15 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +24,7 @@ import (
 	"github.com/jota2rz/odoo-manager/internal/docker"
 	"github.com/jota2rz/odoo-manager/internal/events"
 	"github.com/jota2rz/odoo-manager/internal/gitops"
+	"github.com/jota2rz/odoo-manager/internal/oidc"
 	"github.com/jota2rz/odoo-manager/internal/store"
 	"github.com/jota2rz/odoo-manager/templates"
 )
@@ -34,13 +38,27 @@ type Handler struct {
 	version       string
 	audit         *audit.Logger
 
+	portsMu sync.Mutex // held from picking or checking a project's ports until they are stored
+
 	backupMu       sync.Mutex
 	backupsRunning map[string]bool // projectID -> true while a backup is in progress
+	maxRestoreSize int64           // largest backup zip a restore accepts, in bytes
 
 	dockerMu sync.RWMutex
 	dockerUp bool // last known Docker daemon reachability
 
 	gitAvailable bool // whether git CLI was found at startup
+
+	updatesMu sync.RWMutex
+	updates   map[string]*ProjectUpdates // projectID -> last upstream/image update check
+
+	tlsMode  string // "", "files" or "local-ca"
+	caPEM    []byte // local CA certificate, nil unless tlsMode is "local-ca"
+	proxyTLS bool   // projects are served through the TLS reverse proxy
+
+	oidcMu     sync.Mutex
+	oidcProv   *oidc.Provider        // discovered single sign-on provider, nil until first use
+	oidcLogins map[string]*oidcLogin // state -> sign-in waiting for the provider's callback
 }
 
 // NewHandler creates a new HTTP handler
@@ -67,8 +85,11 @@ func NewHandler(projectStore *store.ProjectStore, staticFS http.Handler, eventHu
 		version:        version,
 		audit:          auditLogger,
 		backupsRunning: make(map[string]bool),
+		maxRestoreSize: defaultMaxRestoreSize,
 		dockerUp:       dockerUp,
+		updates:        make(map[string]*ProjectUpdates),
 		gitAvailable:   gitAvailable,
+		oidcLogins:     make(map[string]*oidcLogin),
 	}
 }
 
@@ -178,49 +199,99 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 		mux.Handle("/static/", h.staticFS)
 	}
 
+	// Sign-in, and endpoints that authenticate on their own or are public
+	mux.HandleFunc("/login", h.withPageHeaders(h.handleLogin))
+	mux.HandleFunc("/logout", h.handleLogout)
+	mux.HandleFunc("/auth/oidc/login", h.handleOIDCLogin)
+	mux.HandleFunc("/auth/oidc/callback", h.withPageHeaders(h.handleOIDCCallback))
+	mux.HandleFunc("/api/webhooks/git", h.withAudit(h.handleGitWebhook))
+	mux.HandleFunc("/api/tls/ca.pem", h.handleCACert)
+
+	// Everything else requires a signed-in user
+	app := http.NewServeMux()
+	mux.Handle("/", withAPIErrors(h.requireAuth(app)))
+	h.registerAppRoutes(app)
+}
+
+// registerAppRoutes sets up the pages, API endpoints and SSE streams that
+// require a signed-in user.
+func (h *Handler) registerAppRoutes(mux *http.ServeMux) {
+	// Every route is checked against its entry in routePolicies
+	routes := make(map[string]http.HandlerFunc)
+	handle := func(pattern string, handler http.HandlerFunc) {
+		routes[pattern] = h.authorize(pattern, handler)
+		mux.HandleFunc(pattern, routes[pattern])
+	}
+
 	// Pages
-	mux.HandleFunc("/", h.handleIndex)
-	mux.HandleFunc("/projects", h.handleProjects)
-	mux.HandleFunc("/audit", h.handleAuditPage)
-	mux.HandleFunc("/maintenance", h.handleMaintenancePage)
-	mux.HandleFunc("/configuration", h.handleConfigurationPage)
+	handle("/", h.withPageHeaders(h.handleIndex))
+	handle("/projects", h.withPageHeaders(h.handleProjects))
+	handle("/audit", h.withPageHeaders(h.handleAuditPage))
+	handle("/maintenance", h.withPageHeaders(h.handleMaintenancePage))
+	handle("/configuration", h.withPageHeaders(h.handleConfigurationPage))
 
 	// API endpoints
-	mux.HandleFunc("/api/projects", h.withAudit(h.handleAPIProjects))
-	mux.HandleFunc("/api/projects/", h.withAudit(h.handleAPIProject))
-	mux.HandleFunc("/api/projects/{id}/start", h.withAudit(h.handleStartProject))
-	mux.HandleFunc("/api/projects/{id}/stop", h.withAudit(h.handleStopProject))
-	mux.HandleFunc("/api/projects/{id}/logs", h.withAudit(h.handleProjectLogs))
-	mux.HandleFunc("/api/projects/{id}/databases", h.withAudit(h.handleListDatabases))
-	mux.HandleFunc("/api/projects/{id}/backup", h.withAudit(h.handleBackupProject))
-	mux.HandleFunc("/api/projects/{id}/config", h.withAudit(h.handleProjectConfig))
-	mux.HandleFunc("/api/projects/{id}/repo", h.withAudit(h.handleProjectRepo))
-	mux.HandleFunc("/api/repo/branches", h.handleRepoBranches)
-	mux.HandleFunc("/api/enterprise/check-access", h.handleEnterpriseCheckAccess)
-	mux.HandleFunc("/api/design-themes/check-access", h.handleDesignThemesCheckAccess)
-	mux.HandleFunc("/api/backup/download/", h.withAudit(h.handleBackupDownload))
-	mux.HandleFunc("/api/projects/{id}/update-odoo", h.withAudit(h.handleUpdateOdoo))
-	mux.HandleFunc("/api/projects/{id}/update-repo", h.withAudit(h.handleUpdateRepos))
-	mux.HandleFunc("/api/projects/{id}/restart-odoo", h.withAudit(h.handleRestartOdoo))
-
-	// Settings endpoints
-	mux.HandleFunc("/api/settings", h.withAudit(h.handleSettings))
-	mux.HandleFunc("/api/settings/validate-token", h.withAudit(h.handleValidateToken))
+	handle("/api/projects", h.withAudit(h.handleAPIProjects))
+	handle("/api/projects/{id}", h.withAudit(h.handleAPIProject))
+	handle("/api/projects/{id}/start", h.withAudit(h.handleStartProject))
+	handle("/api/projects/{id}/stop", h.withAudit(h.handleStopProject))
+	handle("/api/projects/{id}/logs", h.withAudit(h.handleProjectLogs))
+	handle("/api/projects/{id}/databases", h.withAudit(h.handleListDatabases))
+	handle("/api/projects/{id}/backup", h.withAudit(h.handleBackupProject))
+	handle("/api/projects/{id}/restore", h.withAudit(h.handleRestoreProject))
+	handle("/api/projects/{id}/modules/upgrade", h.withAudit(h.handleUpgradeModules))
+	handle("/api/projects/{id}/shell", h.withAudit(h.handleProjectShell))
+	handle("/api/projects/{id}/config", h.withAudit(h.handleProjectConfig))
+	handle("/api/projects/{id}/repo", h.withAudit(h.handleProjectRepo))
+	handle("/api/projects/{id}/repo/status", h.handleRepoStatus)
+	handle("/api/projects/{id}/repo/log", h.handleRepoLog)
+	handle("/api/projects/{id}/repo/diff", h.handleRepoDiff)
+	handle("/api/repo/branches", h.handleRepoBranches)
+	handle("/api/enterprise/check-access", h.handleEnterpriseCheckAccess)
+	handle("/api/design-themes/check-access", h.handleDesignThemesCheckAccess)
+	handle("/api/backup/download/{id}/{filename}", h.withAudit(h.handleBackupDownload))
+	handle("/api/projects/{id}/update-odoo", h.withAudit(h.handleUpdateOdoo))
+	handle("/api/projects/{id}/update-repo", h.withAudit(h.handleUpdateRepos))
+	handle("/api/projects/{id}/restart-odoo", h.withAudit(h.handleRestartOdoo))
+	handle("/api/projects/{id}/revisions", h.withAudit(h.handleRepoRevisions))
+	handle("/api/projects/{id}/rollback", h.withAudit(h.handleRollbackRepos))
+	handle("/api/projects/{id}/webhook", h.withAudit(h.handleProjectWebhook))
+	handle("/api/projects/{id}/ports", h.withAudit(h.handleProjectPorts))
+	handle("/api/projects/{id}/db-credentials", h.withAudit(h.handleProjectDBCredentials))
+	handle("/api/projects/{id}/master-password", h.withAudit(h.handleProjectMasterPassword))
+	handle("/api/projects/{id}/members", h.withAudit(h.handleProjectMembers))
+	handle("/api/updates", h.handleUpdates)
+	handle("/api/previews", h.withAudit(h.handlePreviews))
+
+	// Settings and user endpoints
+	handle("/api/settings", h.withAudit(h.handleSettings))
+	handle("/api/settings/validate-token", h.withAudit(h.handleValidateToken))
+	handle("/api/users", h.withAudit(h.handleUsers))
+	handle("/api/users/{uid}", h.withAudit(h.handleUser))
+	handle("/api/account/password", h.withAudit(h.handleAccountPassword))
+	handle("/api/tokens", h.withAudit(h.handleTokens))
+	handle("/api/tokens/{tid}", h.withAudit(h.handleToken))
 
 	// Maintenance endpoints
-	mux.HandleFunc("/api/maintenance/preview-containers", h.handlePreviewOrphaned("containers"))
-	mux.HandleFunc("/api/maintenance/preview-volumes", h.handlePreviewOrphaned("volumes"))
-	mux.HandleFunc("/api/maintenance/preview-images", h.handlePreviewOrphaned("images"))
-	mux.HandleFunc("/api/maintenance/clean-containers", h.withAudit(h.handleCleanContainers))
-	mux.HandleFunc("/api/maintenance/clean-volumes", h.withAudit(h.handleCleanVolumes))
-	mux.HandleFunc("/api/maintenance/clean-images", h.withAudit(h.handleCleanImages))
+	handle("/api/maintenance/preview-containers", h.handlePreviewOrphaned("containers"))
+	handle("/api/maintenance/preview-volumes", h.handlePreviewOrphaned("volumes"))
+	handle("/api/maintenance/preview-images", h.handlePreviewOrphaned("images"))
+	handle("/api/maintenance/preview-networks", h.handlePreviewOrphaned("networks"))
+	handle("/api/maintenance/clean-containers", h.withAudit(h.handleCleanContainers))
+	handle("/api/maintenance/clean-volumes", h.withAudit(h.handleCleanVolumes))
+	handle("/api/maintenance/clean-images", h.withAudit(h.handleCleanImages))
+	handle("/api/maintenance/clean-networks", h.withAudit(h.handleCleanNetworks))
 
 	// Audit endpoints
-	mux.HandleFunc("/api/audit/logs", h.handleAuditLogs)
-	mux.HandleFunc("/api/audit/stream", h.handleAuditStream)
+	handle("/api/audit/logs", h.handleAuditLogs)
+	handle("/api/audit/stream", h.handleAuditStream)
 
 	// SSE event stream
-	mux.HandleFunc("/api/events", h.handleSSE)
+	handle("/api/events", h.handleSSE)
+
+	// Versioned API, served by the handlers above, and its description
+	handle("GET /api/v1/openapi.json", h.handleOpenAPI)
+	h.registerAPIv1(mux, routes)
 }
 
 // withAudit wraps an HTTP handler to log each request to the audit log.
@@ -231,10 +302,9 @@ func (h *Handler) withAudit(next http.HandlerFunc) http.HandlerFunc {
 		if h.audit != nil {
 			msg := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
 
-			// Try to resolve project name from URL path
-			parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-			if len(parts) >= 3 && parts[0] == "api" && parts[1] == "projects" {
-				if project, ok := h.store.Get(parts[2]); ok {
+			// Resolve the project name from the {id} path value
+			if id := r.PathValue("id"); id != "" {
+				if project, ok := h.store.Get(id); ok {
 					msg += fmt.Sprintf(" (%s)", project.Name)
 				}
 			}
@@ -252,7 +322,7 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	projects := h.store.List()
+	projects := h.visibleProjects(r)
 
 	// Reconcile project statuses with actual Docker state
 	for _, project := range projects {
@@ -279,7 +349,7 @@ func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 // handleProjects serves the projects page
 func (h *Handler) handleProjects(w http.ResponseWriter, r *http.Request) {
-	projects := h.store.List()
+	projects := h.visibleProjects(r)
 
 	// Reconcile project statuses with actual Docker state
 	for _, project := range projects {
@@ -302,7 +372,10 @@ func (h *Handler) handleProjects(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) handleAPIProjects(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		projects := h.store.List()
+		projects := h.visibleProjects(r)
+		if projects == nil {
+			projects = []*store.Project{} // encode as [] rather than null
+		}
 
 		// Reconcile statuses with actual Docker state
 		for _, project := range projects {
@@ -332,11 +405,29 @@ func (h *Handler) handleAPIProjects(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "A project with this name already exists", http.StatusConflict)
 			return
 		}
-		if h.store.PortExists(project.Port, "") {
-			http.Error(w, "A project with this port already exists", http.StatusConflict)
+		if project.BindAddress == "" {
+			project.BindAddress = h.bindAddress()
+		} else if addr, ok := parseBindAddress(project.BindAddress); ok {
+			project.BindAddress = addr
+		} else {
+			http.Error(w, "bind_address must be an IP address such as 127.0.0.1 or 0.0.0.0", http.StatusBadRequest)
 			return
 		}
 
+		// A local folder is the alternative to a git repo as addons source
+		if project.LocalAddonsPath != "" {
+			if project.GitRepoURL != "" {
+				http.Error(w, "Choose either a repository URL or a local addons folder, not both", http.StatusBadRequest)
+				return
+			}
+			dir, err := validateLocalAddonsDir(project.LocalAddonsPath)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			project.LocalAddonsPath = dir
+		}
+
 		// Validate git repo URL if provided
 		if project.GitRepoURL != "" {
 			if err := gitops.ValidateRepoURL(project.GitRepoURL); err != nil {
@@ -352,8 +443,18 @@ func (h *Handler) handleAPIProjects(w http.ResponseWriter, r *http.Request) {
 
 		project.ID = uuid.New().String()
 		project.Status = "creating"
-
-		if err := h.store.Create(&project); err != nil {
+		// Only POST /api/previews creates previews, which expire and are
+		// deleted automatically
+		project.PreviewOf, project.ExpiresAt = "", nil
+
+		// Pick a port from the configured range when none is given, and make
+		// sure nothing on the host holds the requested ones
+		conflict, err := h.createProjectWithPorts(r.Context(), &project, 0)
+		if conflict != nil {
+			writePortConflict(w, conflict)
+			return
+		}
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -379,13 +480,7 @@ func (h *Handler) handleAPIProjects(w http.ResponseWriter, r *http.Request) {
 
 // handleAPIProject handles operations on a specific project
 func (h *Handler) handleAPIProject(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from path
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
-		return
-	}
-	id := parts[2]
+	id := r.PathValue("id")
 
 	switch r.Method {
 	case http.MethodGet:
@@ -410,17 +505,42 @@ func (h *Handler) handleAPIProject(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(project)
 
 	case http.MethodPut:
-		var project store.Project
-		if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		// Only the name and description change here: the addons source,
+		// ports and bind address go through their own validated endpoints
+		var body struct {
+			Name        *string `json:"name"`
+			Description *string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		project, ok := h.store.Get(id)
+		if !ok {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		if body.Name != nil {
+			name := strings.TrimSpace(*body.Name)
+			if name == "" {
+				http.Error(w, "name is required", http.StatusBadRequest)
+				return
+			}
+			if h.store.NameExists(name, id) {
+				http.Error(w, "A project with this name already exists", http.StatusConflict)
+				return
+			}
+			project.Name = name
+		}
+		if body.Description != nil {
+			project.Description = *body.Description
+		}
 
-		project.ID = id
-		if err := h.store.Update(&project); err != nil {
+		if err := h.store.Update(project); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(project)
@@ -482,6 +602,7 @@ func (h *Handler) deleteProject(project *store.Project) {
 		log.Printf("Warning: Failed to delete project %s from store: %v", project.ID, err)
 		return
 	}
+	h.forgetProjectUpdates(project.ID)
 
 	h.events.Publish(events.Event{
 		Type:      events.ProjectDeleted,
@@ -490,14 +611,31 @@ func (h *Handler) deleteProject(project *store.Project) {
 }
 
 // addonsHostDir resolves the absolute host path for a project's addons
-// bind mount. If the project has a git repo configured, it clones/pulls it
-// and returns the local directory. Otherwise returns empty string.
-func (h *Handler) addonsHostDir(ctx context.Context, projectID, repoURL, branch string) string {
+// bind mount. A local addons folder is mounted as-is; if the project has a
+// git repo configured, it clones/pulls it and returns the local directory.
+// Otherwise returns empty string.
+func (h *Handler) addonsHostDir(ctx context.Context, project *store.Project) string {
+	if project.LocalAddonsPath != "" {
+		dir, err := validateLocalAddonsDir(project.LocalAddonsPath)
+		if err != nil {
+			log.Printf("Warning: project %s: %v", project.ID, err)
+			return ""
+		}
+		return dir
+	}
+	projectID, repoURL, branch := project.ID, project.GitRepoURL, project.GitRepoBranch
 	if repoURL == "" {
 		return ""
 	}
+	ctx = h.withGitProgress(ctx, projectID, "addons")
 	token := h.store.GetSetting("github_pat")
 	dir, err := gitops.CloneOrPull(ctx, projectID, repoURL, token, branch)
+	var dirty *gitops.DirtyTreeError
+	if errors.As(err, &dirty) {
+		// Keep mounting the checkout as-is rather than losing local work
+		log.Printf("Warning: project %s: %v, skipping update", projectID, err)
+		dir, err = gitops.RepoDir(projectID), nil
+	}
 	if err != nil {
 		log.Printf("Warning: git clone/pull failed for project %s: %v", projectID, err)
 		return ""
@@ -509,6 +647,22 @@ func (h *Handler) addonsHostDir(ctx context.Context, projectID, repoURL, branch
 	return abs
 }
 
+// withGitProgress returns a context whose git commands publish their
+// progress as GitProgress events for the project's dashboard card. Updates
+// within a phase are throttled so they can't crowd other events out of the
+// SSE client buffers.
+func (h *Handler) withGitProgress(ctx context.Context, projectID, repo string) context.Context {
+	var lastPhase string
+	var lastSent time.Time
+	return gitops.WithProgress(ctx, repo, func(p gitops.Progress) {
+		if p.Phase == lastPhase && p.Percent < 100 && time.Since(lastSent) < 250*time.Millisecond {
+			return
+		}
+		lastPhase, lastSent = p.Phase, time.Now()
+		h.events.Publish(events.Event{Type: events.GitProgress, ProjectID: projectID, Data: p})
+	})
+}
+
 // enterpriseHostDir resolves the absolute host path for the Odoo Enterprise
 // addons bind mount. If enterprise is enabled, it clones/pulls the enterprise
 // repo using the project's Odoo version as the branch. Returns empty string
@@ -517,8 +671,15 @@ func (h *Handler) enterpriseHostDir(ctx context.Context, projectID, odooVersion
 	if !enabled {
 		return ""
 	}
+	ctx = h.withGitProgress(ctx, projectID, "enterprise")
 	token := h.store.GetSetting("github_pat")
 	dir, err := gitops.CloneOrPullEnterprise(ctx, projectID, token, odooVersion)
+	var dirty *gitops.DirtyTreeError
+	if errors.As(err, &dirty) {
+		// Keep mounting the checkout as-is rather than losing local work
+		log.Printf("Warning: project %s: %v, skipping update", projectID, err)
+		dir, err = gitops.EnterpriseRepoDir(projectID), nil
+	}
 	if err != nil {
 		log.Printf("Warning: enterprise clone/pull failed for project %s: %v", projectID, err)
 		return ""
@@ -538,8 +699,15 @@ func (h *Handler) designThemesHostDir(ctx context.Context, projectID, odooVersio
 	if !enabled {
 		return ""
 	}
+	ctx = h.withGitProgress(ctx, projectID, "design-themes")
 	token := h.store.GetSetting("github_pat")
 	dir, err := gitops.CloneOrPullDesignThemes(ctx, projectID, token, odooVersion)
+	var dirty *gitops.DirtyTreeError
+	if errors.As(err, &dirty) {
+		// Keep mounting the checkout as-is rather than losing local work
+		log.Printf("Warning: project %s: %v, skipping update", projectID, err)
+		dir, err = gitops.DesignThemesRepoDir(projectID), nil
+	}
 	if err != nil {
 		log.Printf("Warning: design-themes clone/pull failed for project %s: %v", projectID, err)
 		return ""
@@ -551,6 +719,28 @@ func (h *Handler) designThemesHostDir(ctx context.Context, projectID, odooVersio
 	return abs
 }
 
+// projectHostDirs resolves the addons, Enterprise and Design Themes mounts
+// for starting or recreating a project's containers. Repos rolled back to an
+// earlier revision are mounted as they are, so the rollback survives until
+// the next explicit update; otherwise they are cloned or pulled.
+func (h *Handler) projectHostDirs(ctx context.Context, project *store.Project) (addonsDir, entDir, dtDir string) {
+	if h.store.PinnedRepoRevision(project.ID) != 0 {
+		log.Printf("Project %s: repos pinned by a rollback, not pulling", project.ID)
+		return currentHostDirs(project)
+	}
+	addonsDir = h.addonsHostDir(ctx, project)
+	entDir = h.enterpriseHostDir(ctx, project.ID, project.OdooVersion, project.EnterpriseEnabled)
+	dtDir = h.designThemesHostDir(ctx, project.ID, project.OdooVersion, project.DesignThemesEnabled)
+	return addonsDir, entDir, dtDir
+}
+
+// unpinRepos lets a project's repos follow its ref again after a rollback.
+func (h *Handler) unpinRepos(projectID string) {
+	if err := h.store.SetPinnedRepoRevision(projectID, 0); err != nil {
+		log.Printf("Warning: project %s: failed to unpin repos: %v", projectID, err)
+	}
+}
+
 // createProjectContainers pulls images and creates containers for a newly created project.
 // Runs asynchronously after the create HTTP response has been sent.
 func (h *Handler) createProjectContainers(projectID string) {
@@ -599,9 +789,11 @@ func (h *Handler) createProjectContainers(projectID string) {
 
 	log.Printf("Project %s: resolving addons host directories...", projectID)
 
-	addonsDir := h.addonsHostDir(gitCtx, project.ID, project.GitRepoURL, project.GitRepoBranch)
+	addonsDir := h.addonsHostDir(gitCtx, project)
 	if project.GitRepoURL != "" {
 		log.Printf("Project %s: addons repo cloned (dir=%q)", projectID, addonsDir)
+	} else if project.LocalAddonsPath != "" {
+		log.Printf("Project %s: mounting local addons folder (dir=%q)", projectID, addonsDir)
 	}
 
 	entDir := h.enterpriseHostDir(gitCtx, project.ID, project.OdooVersion, project.EnterpriseEnabled)
@@ -613,6 +805,7 @@ func (h *Handler) createProjectContainers(projectID string) {
 	if project.DesignThemesEnabled {
 		log.Printf("Project %s: design-themes repo cloned (dir=%q)", projectID, dtDir)
 	}
+	h.recordRevision(gitCtx, project, addonsDir, entDir, dtDir)
 
 	log.Printf("Project %s: creating Docker containers...", projectID)
 
@@ -628,6 +821,11 @@ func (h *Handler) createProjectContainers(projectID string) {
 		return
 	}
 
+	h.ensureProxyMode(project.ID)
+	if _, err := h.setMasterPassword(context.Background(), project.ID); err != nil {
+		log.Printf("Warning: project %s: failed to set master password: %v", projectID, err)
+	}
+
 	project.Status = "stopped"
 	if err := h.store.Update(project); err != nil {
 		log.Printf("Warning: Failed to update project status: %v", err)
@@ -668,9 +866,8 @@ func (h *Handler) startProjectContainers(projectID string) {
 	gitCtx, gitCancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer gitCancel()
 
-	addonsDir := h.addonsHostDir(gitCtx, project.ID, project.GitRepoURL, project.GitRepoBranch)
-	entDir := h.enterpriseHostDir(gitCtx, project.ID, project.OdooVersion, project.EnterpriseEnabled)
-	dtDir := h.designThemesHostDir(gitCtx, project.ID, project.OdooVersion, project.DesignThemesEnabled)
+	addonsDir, entDir, dtDir := h.projectHostDirs(gitCtx, project)
+	h.recordRevision(gitCtx, project, addonsDir, entDir, dtDir)
 
 	if err := h.dockerManager.StartProject(context.Background(), project, addonsDir, entDir, dtDir); err != nil {
 		log.Printf("Warning: Failed to start containers for project %s: %v", projectID, err)
@@ -735,13 +932,7 @@ func (h *Handler) handleStartProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract ID from path
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
-		return
-	}
-	id := parts[2]
+	id := r.PathValue("id")
 
 	project, ok := h.store.Get(id)
 	if !ok {
@@ -773,6 +964,12 @@ func (h *Handler) handleStartProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Fail early with the owner instead of an opaque ContainerStart error
+	if conflict := h.checkProjectPorts(r.Context(), project); conflict != nil {
+		writePortConflict(w, conflict)
+		return
+	}
+
 	// Broadcast pending state so all browsers show a spinner
 	h.events.Publish(events.Event{
 		Type:      events.ProjectActionPending,
@@ -796,13 +993,7 @@ func (h *Handler) handleStopProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract ID from path
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
-		return
-	}
-	id := parts[2]
+	id := r.PathValue("id")
 
 	project, ok := h.store.Get(id)
 	if !ok {
@@ -878,7 +1069,7 @@ func (h *Handler) handleListDatabases(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	databases, err := dm.ListDatabases(r.Context(), id)
+	databases, err := dm.ListDatabases(r.Context(), project)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to list databases: %v", err), http.StatusInternalServerError)
 		return
@@ -892,7 +1083,10 @@ func (h *Handler) handleListDatabases(w http.ResponseWriter, r *http.Request) {
 // The exec command runs "odoo db dump" inside the container, redirecting the
 // zip to a file while streaming console output (stderr) back to the browser.
 // When the command finishes the backup file is copied out of the container
-// and a download URL is sent as the final SSE event.
+// and a download URL is sent as the final SSE event. Session requests must
+// carry the CSRF token, as ?csrf_token= on GET.
+// GET /api/projects/{id}/backup?db=NAME&csrf_token=TOKEN (web UI, via EventSource)
+// POST /api/v1/projects/{id}/backups?db=NAME
 func (h *Handler) handleBackupProject(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
@@ -900,6 +1094,22 @@ func (h *Handler) handleBackupProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Backups write a dump to disk, so the EventSource GET must carry the
+	// CSRF token too
+	if !isUnsafeMethod(r.Method) && !checkCSRFQuery(r) {
+		h.deny(w, r, "Forbidden: missing or invalid CSRF token, reload the page")
+		return
+	}
+
+	dbName := r.URL.Query().Get("db")
+	if dbName == "" {
+		dbName = "postgres"
+	}
+	if !dbNamePattern.MatchString(dbName) {
+		http.Error(w, "db must be a database name such as odoo or prod_copy", http.StatusBadRequest)
+		return
+	}
+
 	project, ok := h.store.Get(id)
 	if !ok {
 		http.Error(w, "Project not found", http.StatusNotFound)
@@ -961,7 +1171,6 @@ func (h *Handler) handleBackupProject(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	sendLog := func(line string) {
 		fmt.Fprintf(w, "data: %s\n\n", line)
@@ -972,14 +1181,9 @@ func (h *Handler) handleBackupProject(w http.ResponseWriter, r *http.Request) {
 		flusher.Flush()
 	}
 
-	dbName := r.URL.Query().Get("db")
-	if dbName == "" {
-		dbName = "postgres"
-	}
-
 	sendLog(fmt.Sprintf("Starting backup of database %q for project %s…", dbName, project.Name))
 
-	logReader, execID, cleanup, err := dm.BackupDatabase(r.Context(), id, dbName)
+	logReader, execID, cleanup, err := dm.BackupDatabase(r.Context(), project, dbName)
 	if err != nil {
 		sendEvent("error", fmt.Sprintf("Failed to start backup: %v", err))
 		return
@@ -1018,8 +1222,10 @@ func (h *Handler) handleBackupProject(w http.ResponseWriter, r *http.Request) {
 	// Copy the backup file out of the container
 	sendLog("Backup command completed, extracting file…")
 	timestamp := time.Now().Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s-%s.zip", project.Name, dbName, timestamp)
-	destPath := fmt.Sprintf("data/backups/%s", filename)
+	// Project names are free-form; keep path separators out of the file name
+	safeName := strings.NewReplacer("/", "_", `\`, "_").Replace(project.Name)
+	filename := fmt.Sprintf("%s-%s-%s.zip", safeName, dbName, timestamp)
+	destPath := fmt.Sprintf("data/backups/%s/%s", id, filename)
 
 	if err := dm.CopyBackupFromContainer(r.Context(), id, destPath); err != nil {
 		sendEvent("error", fmt.Sprintf("Failed to extract backup: %v", err))
@@ -1027,54 +1233,70 @@ func (h *Handler) handleBackupProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sendLog("Backup ready for download.")
-	sendEvent("complete", fmt.Sprintf("/api/backup/download/%s", filename))
+	// Point clients of the versioned API at its own download route
+	download := "/api/backup/download/" + url.PathEscape(id) + "/"
+	if strings.HasPrefix(r.URL.Path, apiV1Prefix) {
+		download = apiV1Prefix + "/projects/" + url.PathEscape(id) + "/backups/"
+	}
+	sendEvent("complete", download+url.PathEscape(filename))
 }
 
 // handleBackupDownload serves a previously-created backup file and removes it
-// from disk once fully sent.
+// from disk once fully sent. Backups are kept in a directory per project,
+// data/backups/{id}/, so the route's project policy covers who may download
+// them.
 func (h *Handler) handleBackupDownload(w http.ResponseWriter, r *http.Request) {
-	// Path: /api/backup/download/{filename}
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) < 4 {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+	// Sending the file deletes it, so a link from another site must not be
+	// able to trigger it
+	if !checkCSRFQuery(r) {
+		h.deny(w, r, "Forbidden: missing or invalid CSRF token, reload the page")
 		return
 	}
-	filename := parts[3]
 
-	filePath := fmt.Sprintf("data/backups/%s", filename)
-	info, err := os.Stat(filePath)
+	id := r.PathValue("id")
+	filename := r.PathValue("filename")
+
+	// Path values are unescaped, so %2F arrives as "/": only accept a plain
+	// zip name, and open it through a root confined to the project's
+	// backup directory
+	if !filepath.IsLocal(id) || filename != filepath.Base(filename) || !filepath.IsLocal(filename) || !strings.HasSuffix(filename, ".zip") {
+		http.Error(w, "Invalid backup file name", http.StatusBadRequest)
+		return
+	}
+	root, err := os.OpenRoot(filepath.Join("data", "backups", id))
 	if err != nil {
 		http.Error(w, "Backup file not found", http.StatusNotFound)
 		return
 	}
+	defer root.Close()
+	f, err := root.Open(filename)
+	if err != nil {
+		http.Error(w, "Backup file not found", http.StatusNotFound)
+		return
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		http.Error(w, "Backup file not found", http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/zip")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
 
-	f, err := os.Open(filePath)
-	if err != nil {
-		http.Error(w, "Failed to open backup file", http.StatusInternalServerError)
-		return
-	}
-	defer f.Close()
-
 	io.Copy(w, f)
+	f.Close()
 
 	// Clean up the file after serving
-	go os.Remove(filePath)
+	_ = root.Remove(filename)
 }
 
 // handleProjectConfig reads or writes odoo.conf for a project.
 // GET  → returns { "content": "<odoo.conf text>" }
 // PUT  → accepts { "content": "<new odoo.conf text>" } and writes it
 func (h *Handler) handleProjectConfig(w http.ResponseWriter, r *http.Request) {
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
-		return
-	}
-	id := parts[2]
+	id := r.PathValue("id")
 
 	if _, ok := h.store.Get(id); !ok {
 		http.Error(w, "Project not found", http.StatusNotFound)
@@ -1104,32 +1326,41 @@ func (h *Handler) handleProjectConfig(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		previous, _ := h.dockerManager.ReadOdooConfig(r.Context(), id)
 		if err := h.dockerManager.WriteOdooConfig(r.Context(), id, body.Content); err != nil {
 			http.Error(w, "Failed to write odoo.conf: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		resp := map[string]string{"status": "ok"}
+		if masterPasswordRemoved(previous, body.Content) {
+			resp["warning"] = "admin_passwd was removed: the database manager of this instance is no longer protected by a master password"
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		json.NewEncoder(w).Encode(resp)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-// handleProjectLogs streams logs via SSE
+// handleProjectLogs streams logs via SSE. By default it sends the last 100
+// lines and then follows; ?tail=N|all changes the backlog and
+// ?follow=false ends the stream after it.
 func (h *Handler) handleProjectLogs(w http.ResponseWriter, r *http.Request) {
-	// Extract ID and container type from query
-	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) < 3 {
-		http.Error(w, "Invalid project ID", http.StatusBadRequest)
-		return
-	}
-	id := parts[2]
+	id := r.PathValue("id")
 
 	containerType := r.URL.Query().Get("container")
 	if containerType == "" {
 		containerType = "odoo"
 	}
+	follow := r.URL.Query().Get("follow") == "" || isTruthy(r.URL.Query().Get("follow"))
+	tail := r.URL.Query().Get("tail")
+	if tail == "" {
+		tail = "100"
+	} else if n, err := strconv.Atoi(tail); tail != "all" && (err != nil || n < 0) {
+		http.Error(w, "tail must be a number of lines or all", http.StatusBadRequest)
+		return
+	}
 
 	if h.dockerManager == nil {
 		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
@@ -1140,14 +1371,13 @@ func (h *Handler) handleProjectLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	// Disable the server's WriteTimeout for this long-lived connection
 	rc := http.NewResponseController(w)
 	_ = rc.SetWriteDeadline(time.Time{})
 
 	// Get logs stream
-	logs, hasTTY, err := h.dockerManager.GetLogs(r.Context(), id, containerType)
+	logs, hasTTY, err := h.dockerManager.GetLogs(r.Context(), id, containerType, follow, tail)
 	if err != nil {
 		fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
 		return
@@ -1206,7 +1436,6 @@ func (h *Handler) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	ch := h.events.Subscribe()
 	defer h.events.Unsubscribe(ch)
@@ -1239,6 +1468,11 @@ func (h *Handler) handleSSE(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
+			// Only pass on events of projects the user can see; deletions
+			// always go through since the membership is gone by then
+			if evt.ProjectID != "" && evt.Type != events.ProjectDeleted && !h.canSeeProject(r, evt.ProjectID) {
+				continue
+			}
 			data, err := json.Marshal(evt)
 			if err != nil {
 				continue
@@ -1294,6 +1528,8 @@ func (h *Handler) handlePreviewOrphaned(kind string) http.HandlerFunc {
 			names, err = h.dockerManager.ListOrphanedVolumes(r.Context(), knownIDs)
 		case "images":
 			names, err = h.dockerManager.ListOrphanedImages(r.Context(), knownIDs)
+		case "networks":
+			names, err = h.dockerManager.ListOrphanedNetworks(r.Context(), knownIDs)
 		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -1364,6 +1600,25 @@ func (h *Handler) handleCleanImages(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleCleanNetworks removes all orphaned project networks.
+func (h *Handler) handleCleanNetworks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.dockerManager == nil {
+		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
+		return
+	}
+	result, err := h.dockerManager.CleanOrphanedNetworks(r.Context(), h.knownProjectIDs())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // handleAuditLogs returns the last N lines of the audit log as a JSON array
 // of strings. Supports pagination via ?before=<offset>&limit=<n>.
 func (h *Handler) handleAuditLogs(w http.ResponseWriter, r *http.Request) {
@@ -1435,7 +1690,6 @@ func (h *Handler) handleAuditStream(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	ch := h.audit.Subscribe()
 	defer h.audit.Unsubscribe(ch)
@@ -1474,8 +1728,8 @@ func (h *Handler) handleConfigurationPage(w http.ResponseWriter, r *http.Request
 }
 
 // handleSettings handles GET/PUT for global settings (e.g. GitHub PAT).
-// GET  → returns { "github_pat": "<masked or empty>" }
-// PUT  → accepts { "github_pat": "<token>" } and stores it
+// GET  → returns { "github_pat": "<masked or empty>", "github_pat_valid": "...", "update_poll_minutes": "15", "port_range": "8069-8199", "bind_address": "127.0.0.1", "tls": "off|files|local-ca" }
+// PUT  → accepts { "github_pat": "<token>", "update_poll_minutes": 15, "port_range": "8069-8199", "bind_address": "127.0.0.1" } (any subset) and stores it
 func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -1489,30 +1743,121 @@ func (h *Handler) handleSettings(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		patValid := h.store.GetSetting("github_pat_valid")
+		start, end := h.portRange()
+		tlsMode := h.tlsMode
+		if tlsMode == "" {
+			tlsMode = "off"
+		}
+		oidcCfg := h.oidcSettings()
+		oidcSecret := ""
+		if oidcCfg.clientSecret != "" {
+			oidcSecret = "****"
+		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"github_pat": masked, "github_pat_valid": patValid})
+		json.NewEncoder(w).Encode(map[string]string{
+			"github_pat":          masked,
+			"github_pat_valid":    patValid,
+			"update_poll_minutes": strconv.Itoa(int(h.updatePollInterval() / time.Minute)),
+			"port_range":          fmt.Sprintf("%d-%d", start, end),
+			"bind_address":        h.bindAddress(),
+			"tls":                 tlsMode,
+			"allowed_origins":     strings.Join(h.allowedOrigins(), "\n"),
+			"oidc_issuer":         oidcCfg.issuer,
+			"oidc_client_id":      oidcCfg.clientID,
+			"oidc_client_secret":  oidcSecret,
+			"oidc_scopes":         oidcCfg.scopes,
+			"oidc_redirect_url":   oidcCfg.redirectURL,
+			"oidc_groups_claim":   oidcCfg.groupsClaim,
+			"oidc_role_mapping":   oidcCfg.roleMapping,
+			"oidc_default_role":   oidcCfg.defaultRole,
+		})
 
 	case http.MethodPut:
+		// Only the settings present in the body are changed
 		var body struct {
-			GitHubPAT string `json:"github_pat"`
+			GitHubPAT         *string `json:"github_pat"`
+			UpdatePollMinutes *int    `json:"update_poll_minutes"`
+			PortRange         *string `json:"port_range"`
+			BindAddress       *string `json:"bind_address"`
+			AllowedOrigins    *string `json:"allowed_origins"`
+
+			OIDCIssuer       *string `json:"oidc_issuer"`
+			OIDCClientID     *string `json:"oidc_client_id"`
+			OIDCClientSecret *string `json:"oidc_client_secret"`
+			OIDCScopes       *string `json:"oidc_scopes"`
+			OIDCRedirectURL  *string `json:"oidc_redirect_url"`
+			OIDCGroupsClaim  *string `json:"oidc_groups_claim"`
+			OIDCRoleMapping  *string `json:"oidc_role_mapping"`
+			OIDCDefaultRole  *string `json:"oidc_default_role"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if err := h.store.SetSetting("github_pat", body.GitHubPAT); err != nil {
-			http.Error(w, "Failed to save setting: "+err.Error(), http.StatusInternalServerError)
+		if body.UpdatePollMinutes != nil {
+			if *body.UpdatePollMinutes < 0 {
+				http.Error(w, "update_poll_minutes must be 0 (disabled) or more", http.StatusBadRequest)
+				return
+			}
+			if err := h.store.SetSetting("update_poll_minutes", strconv.Itoa(*body.UpdatePollMinutes)); err != nil {
+				http.Error(w, "Failed to save setting: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if body.PortRange != nil {
+			start, end, ok := parsePortRange(*body.PortRange)
+			if !ok {
+				http.Error(w, "port_range must be \"start-end\" within 1024-65535", http.StatusBadRequest)
+				return
+			}
+			if err := h.store.SetSetting("port_range", fmt.Sprintf("%d-%d", start, end)); err != nil {
+				http.Error(w, "Failed to save setting: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if body.BindAddress != nil {
+			addr, ok := parseBindAddress(*body.BindAddress)
+			if !ok {
+				http.Error(w, "bind_address must be an IP address such as 127.0.0.1 or 0.0.0.0", http.StatusBadRequest)
+				return
+			}
+			if err := h.store.SetSetting("bind_address", addr); err != nil {
+				http.Error(w, "Failed to save setting: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if body.AllowedOrigins != nil {
+			origins, err := parseAllowedOrigins(*body.AllowedOrigins)
+			if err != nil {
+				http.Error(w, "allowed_origins: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := h.store.SetSetting("allowed_origins", strings.Join(origins, ",")); err != nil {
+				http.Error(w, "Failed to save setting: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := h.saveOIDCSettings(r.Context(), body.OIDCIssuer, body.OIDCClientID, body.OIDCClientSecret,
+			body.OIDCScopes, body.OIDCRedirectURL, body.OIDCGroupsClaim, body.OIDCRoleMapping, body.OIDCDefaultRole); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		// Re-validate the new token and store result
-		if body.GitHubPAT != "" {
-			if err := gitops.ValidateToken(r.Context(), body.GitHubPAT); err != nil {
-				_ = h.store.SetSetting("github_pat_valid", "false")
+		if body.GitHubPAT != nil {
+			pat := *body.GitHubPAT
+			if err := h.store.SetSetting("github_pat", pat); err != nil {
+				http.Error(w, "Failed to save setting: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// Re-validate the new token and store result
+			if pat != "" {
+				if err := gitops.ValidateToken(r.Context(), pat); err != nil {
+					_ = h.store.SetSetting("github_pat_valid", "false")
+				} else {
+					_ = h.store.SetSetting("github_pat_valid", "true")
+				}
 			} else {
-				_ = h.store.SetSetting("github_pat_valid", "true")
+				_ = h.store.SetSetting("github_pat_valid", "")
 			}
-		} else {
-			_ = h.store.SetSetting("github_pat_valid", "")
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -1556,8 +1901,9 @@ func (h *Handler) handleValidateToken(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
 }
 
-// handleProjectRepo handles PUT for a project's git repo URL and enterprise setting.
-// PUT → validates URL format, checks accessibility, and saves.
+// handleProjectRepo handles PUT for a project's addons source (git repo URL
+// or local folder) and enterprise setting.
+// PUT → validates the URL or folder, checks accessibility, and saves.
 func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1574,6 +1920,7 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		GitRepoURL          string `json:"git_repo_url"`
 		GitRepoBranch       string `json:"git_repo_branch"`
+		LocalAddonsPath     string `json:"local_addons_path"`     // host folder used instead of a repo
 		EnterpriseEnabled   *bool  `json:"enterprise_enabled"`    // pointer to detect if field was sent
 		DesignThemesEnabled *bool  `json:"design_themes_enabled"` // pointer to detect if field was sent
 	}
@@ -1582,8 +1929,26 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if body.LocalAddonsPath != "" {
+		errMsg := ""
+		if body.GitRepoURL != "" {
+			errMsg = "Choose either a repository URL or a local addons folder, not both"
+		} else if dir, err := validateLocalAddonsDir(body.LocalAddonsPath); err != nil {
+			errMsg = err.Error()
+		} else {
+			body.LocalAddonsPath = dir
+		}
+		if errMsg != "" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]string{"error": errMsg})
+			return
+		}
+	}
+
 	previousURL := project.GitRepoURL
 	previousBranch := project.GitRepoBranch
+	previousLocal := project.LocalAddonsPath
 	previousEnterprise := project.EnterpriseEnabled
 	previousDesignThemes := project.DesignThemesEnabled
 
@@ -1596,7 +1961,7 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 		project.DesignThemesEnabled = *body.DesignThemesEnabled
 	}
 
-	// Allow clearing the repo URL
+	// Allow clearing the repo URL, possibly in favour of a local folder
 	if body.GitRepoURL == "" {
 		// Remove existing clone if any
 		if previousURL != "" {
@@ -1604,13 +1969,17 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 		}
 		project.GitRepoURL = ""
 		project.GitRepoBranch = ""
+		project.LocalAddonsPath = body.LocalAddonsPath
 		if err := h.store.Update(project); err != nil {
 			http.Error(w, "Failed to update project: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Recreate container without the addons bind mount (enterprise/design-themes may still change)
-		if (previousURL != "" || previousEnterprise != project.EnterpriseEnabled || previousDesignThemes != project.DesignThemesEnabled) && h.dockerManager != nil {
+		// Recreate container without the addons bind mount, or with the local
+		// folder (enterprise/design-themes may still change)
+		if (previousURL != "" || previousLocal != project.LocalAddonsPath || previousEnterprise != project.EnterpriseEnabled || previousDesignThemes != project.DesignThemesEnabled) && h.dockerManager != nil {
+			h.unpinRepos(project.ID)
+			addonsDir := h.addonsHostDir(r.Context(), project)
 			entDir := h.enterpriseHostDir(r.Context(), project.ID, project.OdooVersion, project.EnterpriseEnabled)
 			dtDir := h.designThemesHostDir(r.Context(), project.ID, project.OdooVersion, project.DesignThemesEnabled)
 			if !project.EnterpriseEnabled && previousEnterprise {
@@ -1619,12 +1988,14 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 			if !project.DesignThemesEnabled && previousDesignThemes {
 				gitops.RemoveDesignThemesRepo(project.ID)
 			}
-			if err := h.dockerManager.RecreateOdooContainer(r.Context(), project, "", entDir, dtDir); err != nil {
+			if err := h.dockerManager.RecreateOdooContainer(r.Context(), project, addonsDir, entDir, dtDir); err != nil {
 				log.Printf("Warning: failed to recreate container for project %s after clearing repo: %v", project.ID, err)
 			}
-			// Update odoo.conf addons_path: remove /mnt/extra-addons since repo was cleared
-			if previousURL != "" {
-				if err := h.dockerManager.UpdateOdooConfigExtraAddons(r.Context(), project.ID, false); err != nil {
+			// Update odoo.conf addons_path when /mnt/extra-addons appears or goes away
+			hasAddons := project.LocalAddonsPath != ""
+			hadAddons := previousURL != "" || previousLocal != ""
+			if hasAddons != hadAddons {
+				if err := h.dockerManager.UpdateOdooConfigExtraAddons(r.Context(), project.ID, hasAddons); err != nil {
 					log.Printf("Warning: failed to update odoo.conf extra-addons for project %s: %v", project.ID, err)
 				}
 			}
@@ -1666,14 +2037,16 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 
 	project.GitRepoURL = body.GitRepoURL
 	project.GitRepoBranch = body.GitRepoBranch
+	project.LocalAddonsPath = ""
 	if err := h.store.Update(project); err != nil {
 		http.Error(w, "Failed to update project: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// If the repo URL, branch, enterprise, or design themes flag changed, recreate the Odoo container
-	needsRecreate := previousURL != body.GitRepoURL || previousBranch != body.GitRepoBranch || previousEnterprise != project.EnterpriseEnabled || previousDesignThemes != project.DesignThemesEnabled
+	needsRecreate := previousURL != body.GitRepoURL || previousBranch != body.GitRepoBranch || previousLocal != "" || previousEnterprise != project.EnterpriseEnabled || previousDesignThemes != project.DesignThemesEnabled
 	if needsRecreate && h.dockerManager != nil {
+		h.unpinRepos(project.ID)
 		// Remove old clone if URL changed so we get a fresh checkout
 		if previousURL != body.GitRepoURL {
 			gitops.RemoveRepo(project.ID)
@@ -1686,15 +2059,16 @@ func (h *Handler) handleProjectRepo(w http.ResponseWriter, r *http.Request) {
 		if !project.DesignThemesEnabled && previousDesignThemes {
 			gitops.RemoveDesignThemesRepo(project.ID)
 		}
-		addonsDir := h.addonsHostDir(r.Context(), project.ID, project.GitRepoURL, project.GitRepoBranch)
+		addonsDir := h.addonsHostDir(r.Context(), project)
 		entDir := h.enterpriseHostDir(r.Context(), project.ID, project.OdooVersion, project.EnterpriseEnabled)
 		dtDir := h.designThemesHostDir(r.Context(), project.ID, project.OdooVersion, project.DesignThemesEnabled)
+		h.recordRevision(r.Context(), project, addonsDir, entDir, dtDir)
 		if err := h.dockerManager.RecreateOdooContainer(r.Context(), project, addonsDir, entDir, dtDir); err != nil {
 			log.Printf("Warning: failed to recreate container for project %s: %v", project.ID, err)
 		}
 		// Update odoo.conf addons_path when repo URL is added or removed
 		hasRepo := body.GitRepoURL != ""
-		hadRepo := previousURL != ""
+		hadRepo := previousURL != "" || previousLocal != ""
 		if hasRepo != hadRepo {
 			if err := h.dockerManager.UpdateOdooConfigExtraAddons(r.Context(), project.ID, hasRepo); err != nil {
 				log.Printf("Warning: failed to update odoo.conf extra-addons for project %s: %v", project.ID, err)
@@ -1823,9 +2197,10 @@ func (h *Handler) handleDesignThemesCheckAccess(w http.ResponseWriter, r *http.R
 }
 
 // handleUpdateOdoo pulls the latest Odoo image, recreates the Odoo container
-// (preserving data volumes), and git-pulls all configured repos.
+// (preserving data volumes), and git-pulls all configured repos. It takes
+// POST like the other actions, and PUT as in the first API.
 func (h *Handler) handleUpdateOdoo(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -1857,9 +2232,8 @@ func (h *Handler) handleUpdateOdoo(w http.ResponseWriter, r *http.Request) {
 		gitCtx, gitCancel := context.WithTimeout(context.Background(), 10*time.Minute)
 		defer gitCancel()
 
-		addonsDir := h.addonsHostDir(gitCtx, project.ID, project.GitRepoURL, project.GitRepoBranch)
-		entDir := h.enterpriseHostDir(gitCtx, project.ID, project.OdooVersion, project.EnterpriseEnabled)
-		dtDir := h.designThemesHostDir(gitCtx, project.ID, project.OdooVersion, project.DesignThemesEnabled)
+		addonsDir, entDir, dtDir := h.projectHostDirs(gitCtx, project)
+		h.recordRevision(gitCtx, project, addonsDir, entDir, dtDir)
 
 		log.Printf("Project %s: pulling latest Odoo image and recreating container...", id)
 		if err := h.dockerManager.UpdateOdooContainer(context.Background(), project, addonsDir, entDir, dtDir); err != nil {
@@ -1875,6 +2249,7 @@ func (h *Handler) handleUpdateOdoo(w http.ResponseWriter, r *http.Request) {
 		_ = h.store.Update(project)
 		log.Printf("Project %s: Odoo update complete (status=%s)", id, status)
 		h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+		h.refreshProjectUpdates(id)
 	}()
 
 	w.WriteHeader(http.StatusAccepted)
@@ -1883,7 +2258,10 @@ func (h *Handler) handleUpdateOdoo(w http.ResponseWriter, r *http.Request) {
 // handleUpdateRepos git-pulls the project's addons, enterprise, and
 // design-themes repos. If odoo.conf contains dev=all or dev=reload it
 // skips the restart (Odoo auto-reloads), otherwise it restarts the Odoo
-// container.
+// container. Checkouts with uncommitted changes are refused with 409 and
+// the list of modified files; ?force=true stashes the changes and updates.
+// For a local addons folder there is nothing to pull, so it only restarts
+// Odoo to pick up the edited files.
 func (h *Handler) handleUpdateRepos(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1897,7 +2275,7 @@ func (h *Handler) handleUpdateRepos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if project.GitRepoURL == "" {
+	if project.GitRepoURL == "" && project.LocalAddonsPath == "" {
 		http.Error(w, "No repository configured", http.StatusBadRequest)
 		return
 	}
@@ -1907,12 +2285,34 @@ func (h *Handler) handleUpdateRepos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if project.LocalAddonsPath != "" {
+		h.startLocalAddonsRestart(project)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	force := isTruthy(r.URL.Query().Get("force"))
+	dirty := h.dirtyRepos(r.Context(), project)
+	if len(dirty) > 0 && !force {
+		writeDirtyConflict(w, dirty)
+		return
+	}
+
+	h.startRepoUpdate(project, dirty)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// startRepoUpdate pulls the project's repos in the background and restarts
+// Odoo unless dev mode makes it auto-reload. Checkouts listed in dirty are
+// stashed first. Shared by the Update Repositories action and git webhooks.
+func (h *Handler) startRepoUpdate(project *store.Project, dirty map[string][]string) {
+	id := project.ID
 	h.events.Publish(events.Event{Type: events.ProjectActionPending, ProjectID: id, Data: "updating-repo"})
 
 	go func() {
 		defer func() {
 			if rv := recover(); rv != nil {
-				log.Printf("PANIC in handleUpdateRepos for %s: %v", id, rv)
+				log.Printf("PANIC in startRepoUpdate for %s: %v", id, rv)
 				project.Status = "error"
 				_ = h.store.Update(project)
 				h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
@@ -1922,7 +2322,15 @@ func (h *Handler) handleUpdateRepos(w http.ResponseWriter, r *http.Request) {
 		gitCtx, gitCancel := context.WithTimeout(context.Background(), 10*time.Minute)
 		defer gitCancel()
 
-		addonsDir := h.addonsHostDir(gitCtx, project.ID, project.GitRepoURL, project.GitRepoBranch)
+		if err := h.stashDirtyRepos(gitCtx, project, dirty); err != nil {
+			log.Printf("Project %s: %v, not updating", id, err)
+			project.Status = "error"
+			_ = h.store.Update(project)
+			h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+			return
+		}
+
+		addonsDir := h.addonsHostDir(gitCtx, project)
 		if addonsDir == "" {
 			log.Printf("Project %s: repo pull failed (addonsHostDir returned empty)", id)
 			status, _ := h.dockerManager.GetProjectStatus(context.Background(), project.ID)
@@ -1933,30 +2341,12 @@ func (h *Handler) handleUpdateRepos(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Also pull enterprise and design-themes repos if enabled
-		h.enterpriseHostDir(gitCtx, project.ID, project.OdooVersion, project.EnterpriseEnabled)
-		h.designThemesHostDir(gitCtx, project.ID, project.OdooVersion, project.DesignThemesEnabled)
-
-		// Check odoo.conf for dev mode (all or reload)
-		needsRestart := true
-		confContent, err := h.dockerManager.ReadOdooConfig(context.Background(), project.ID)
-		if err == nil {
-			for _, line := range strings.Split(string(confContent), "\n") {
-				trimmed := strings.TrimSpace(line)
-				lower := strings.ToLower(trimmed)
-				if strings.HasPrefix(lower, "dev") && !strings.HasPrefix(lower, "dev_") {
-					parts := strings.SplitN(lower, "=", 2)
-					if len(parts) == 2 {
-						val := strings.TrimSpace(parts[1])
-						if strings.Contains(val, "all") || strings.Contains(val, "reload") {
-							needsRestart = false
-							log.Printf("Project %s: dev mode detected (%s), skipping restart", id, val)
-							break
-						}
-					}
-				}
-			}
-		}
+		entDir := h.enterpriseHostDir(gitCtx, project.ID, project.OdooVersion, project.EnterpriseEnabled)
+		dtDir := h.designThemesHostDir(gitCtx, project.ID, project.OdooVersion, project.DesignThemesEnabled)
+		h.recordRevision(gitCtx, project, addonsDir, entDir, dtDir)
+		h.unpinRepos(id)
 
+		needsRestart := h.odooNeedsRestart(project.ID)
 		if needsRestart {
 			log.Printf("Project %s: restarting Odoo container after code update...", id)
 			if err := h.dockerManager.RestartOdooContainer(context.Background(), project.ID); err != nil {
@@ -1969,9 +2359,41 @@ func (h *Handler) handleUpdateRepos(w http.ResponseWriter, r *http.Request) {
 		_ = h.store.Update(project)
 		log.Printf("Project %s: repos update complete (status=%s, restarted=%v)", id, status, needsRestart)
 		h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+		h.refreshProjectUpdates(id)
 	}()
 
-	w.WriteHeader(http.StatusAccepted)
+}
+
+// startLocalAddonsRestart is Update Repositories for a local addons folder:
+// the files are already in place, so Odoo is just restarted in the
+// background to load them.
+func (h *Handler) startLocalAddonsRestart(project *store.Project) {
+	id := project.ID
+	h.events.Publish(events.Event{Type: events.ProjectActionPending, ProjectID: id, Data: "updating-repo"})
+
+	go func() {
+		defer func() {
+			if rv := recover(); rv != nil {
+				log.Printf("PANIC in startLocalAddonsRestart for %s: %v", id, rv)
+				project.Status = "error"
+				_ = h.store.Update(project)
+				h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		log.Printf("Project %s: restarting Odoo to reload local addons folder %s", id, project.LocalAddonsPath)
+		if err := h.dockerManager.RestartOdooContainer(ctx, id); err != nil {
+			log.Printf("Project %s: restart failed: %v", id, err)
+		}
+
+		status, _ := h.dockerManager.GetProjectStatus(context.Background(), id)
+		project.Status = status
+		_ = h.store.Update(project)
+		h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+	}()
 }
 
 // handleRestartOdoo restarts only the Odoo container for a project.
@@ -2016,3 +2438,430 @@ func (h *Handler) handleRestartOdoo(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// odooNeedsRestart reports whether Odoo must be restarted to pick up code
+// changes. If odoo.conf contains dev=all or dev=reload Odoo auto-reloads and
+// no restart is needed.
+func (h *Handler) odooNeedsRestart(projectID string) bool {
+	confContent, err := h.dockerManager.ReadOdooConfig(context.Background(), projectID)
+	if err != nil {
+		return true
+	}
+	for _, line := range strings.Split(string(confContent), "\n") {
+		trimmed := strings.TrimSpace(line)
+		lower := strings.ToLower(trimmed)
+		if strings.HasPrefix(lower, "dev") && !strings.HasPrefix(lower, "dev_") {
+			parts := strings.SplitN(lower, "=", 2)
+			if len(parts) == 2 {
+				val := strings.TrimSpace(parts[1])
+				if strings.Contains(val, "all") || strings.Contains(val, "reload") {
+					log.Printf("Project %s: dev mode detected (%s), skipping restart", projectID, val)
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// headOrEmpty returns the commit checked out in dir, or "" when dir is empty
+// or not a git checkout.
+func headOrEmpty(ctx context.Context, dir string) string {
+	if dir == "" {
+		return ""
+	}
+	sha, err := gitops.HeadCommit(ctx, dir)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return ""
+	}
+	return sha
+}
+
+// recordRevision stores the commits currently checked out in a project's
+// repos so the deployed code can be inspected and rolled back later.
+func (h *Handler) recordRevision(ctx context.Context, project *store.Project, addonsDir, entDir, dtDir string) {
+	if project.LocalAddonsPath != "" {
+		addonsDir = "" // not managed by us, even if it is a git checkout
+	}
+	if addonsDir == "" && entDir == "" && dtDir == "" {
+		return
+	}
+	rev := &store.RepoRevision{
+		ProjectID:          project.ID,
+		Ref:                project.GitRepoBranch,
+		AddonsCommit:       headOrEmpty(ctx, addonsDir),
+		EnterpriseCommit:   headOrEmpty(ctx, entDir),
+		DesignThemesCommit: headOrEmpty(ctx, dtDir),
+	}
+	added, err := h.store.AddRepoRevision(rev)
+	if err != nil {
+		log.Printf("Warning: failed to record repo revision for project %s: %v", project.ID, err)
+		return
+	}
+	if added {
+		log.Printf("Project %s: recorded repo revision %d (addons=%.12s)", project.ID, rev.ID, rev.AddonsCommit)
+	}
+}
+
+// handleRepoRevisions returns the current repo revision and recent history,
+// and whether a rollback pinned the repos to it.
+// GET /api/projects/{id}/revisions?limit=N → { "current": {...}, "history": [...], "pinned": bool }
+func (h *Handler) handleRepoRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, ok := h.store.Get(id); !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	history := h.store.ListRepoRevisions(id, limit)
+	if history == nil {
+		history = []*store.RepoRevision{}
+	}
+	var current *store.RepoRevision
+	if len(history) > 0 {
+		current = history[0]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"current": current,
+		"history": history,
+		"pinned":  h.store.PinnedRepoRevision(id) != 0,
+	})
+}
+
+// handleRollbackRepos checks out the commits of an earlier revision in the
+// project's repos and restarts Odoo (unless dev mode is active). The target
+// defaults to the revision before the current one; { "revision_id": N }
+// selects a specific one. The checkout is detached and kept across starts
+// and recreates until the repos are explicitly updated or reconfigured. Like
+// update-repo, it refuses with 409 when a checkout has local changes
+// unless ?force=true is given.
+// POST /api/projects/{id}/rollback
+func (h *Handler) handleRollbackRepos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	project, ok := h.store.Get(id)
+	if !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	if h.dockerManager == nil {
+		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var body struct {
+		RevisionID int64 `json:"revision_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var target *store.RepoRevision
+	if body.RevisionID != 0 {
+		target, ok = h.store.GetRepoRevision(id, body.RevisionID)
+	} else {
+		target, ok = h.store.PreviousRepoRevision(id)
+	}
+	if !ok {
+		http.Error(w, "No revision to roll back to", http.StatusNotFound)
+		return
+	}
+
+	force := isTruthy(r.URL.Query().Get("force"))
+	dirty := h.dirtyRepos(r.Context(), project)
+	if len(dirty) > 0 && !force {
+		writeDirtyConflict(w, dirty)
+		return
+	}
+
+	h.events.Publish(events.Event{Type: events.ProjectActionPending, ProjectID: id, Data: "updating-repo"})
+
+	go func() {
+		defer func() {
+			if rv := recover(); rv != nil {
+				log.Printf("PANIC in handleRollbackRepos for %s: %v", id, rv)
+				project.Status = "error"
+				_ = h.store.Update(project)
+				h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+			}
+		}()
+
+		gitCtx, gitCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer gitCancel()
+
+		if err := h.stashDirtyRepos(gitCtx, project, dirty); err != nil {
+			log.Printf("Project %s: %v, not rolling back", id, err)
+			project.Status = "error"
+			_ = h.store.Update(project)
+			h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+			return
+		}
+
+		token := h.store.GetSetting("github_pat")
+		checkouts := []struct{ repo, dir, sha string }{
+			{"addons", gitops.RepoDir(id), target.AddonsCommit},
+			{"enterprise", gitops.EnterpriseRepoDir(id), target.EnterpriseCommit},
+			{"design-themes", gitops.DesignThemesRepoDir(id), target.DesignThemesCommit},
+		}
+		failed := false
+		for _, c := range checkouts {
+			if c.sha == "" || (c.repo == "addons" && project.LocalAddonsPath != "") {
+				continue // local addons folders are not under our control
+			}
+			ctx := h.withGitProgress(gitCtx, id, c.repo)
+			if err := gitops.CheckoutCommit(ctx, c.dir, token, c.sha); err != nil {
+				log.Printf("Project %s: rollback of %s failed: %v", id, c.dir, err)
+				failed = true
+			}
+		}
+
+		if !failed {
+			addonsDir, entDir, dtDir := "", "", ""
+			if target.AddonsCommit != "" {
+				addonsDir = gitops.RepoDir(id)
+			}
+			if target.EnterpriseCommit != "" {
+				entDir = gitops.EnterpriseRepoDir(id)
+			}
+			if target.DesignThemesCommit != "" {
+				dtDir = gitops.DesignThemesRepoDir(id)
+			}
+			h.recordRevision(gitCtx, project, addonsDir, entDir, dtDir)
+			if err := h.store.SetPinnedRepoRevision(id, target.ID); err != nil {
+				log.Printf("Warning: project %s: failed to pin repos: %v", id, err)
+			}
+
+			if h.odooNeedsRestart(project.ID) {
+				if err := h.dockerManager.RestartOdooContainer(context.Background(), project.ID); err != nil {
+					log.Printf("Project %s: restart failed: %v", id, err)
+				}
+			}
+		}
+
+		status, _ := h.dockerManager.GetProjectStatus(context.Background(), project.ID)
+		project.Status = status
+		_ = h.store.Update(project)
+		log.Printf("Project %s: rollback to revision %d complete (status=%s, failed=%v)", id, target.ID, status, failed)
+		h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: id, Data: project})
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// projectRepoDirs returns the local checkouts of a project keyed by the
+// repo names used in the API ("addons", "enterprise", "design-themes").
+// Only repos that are configured and already cloned are included.
+func projectRepoDirs(project *store.Project) map[string]string {
+	dirs := map[string]string{}
+	if project.GitRepoURL != "" && gitops.IsRepo(gitops.RepoDir(project.ID)) {
+		dirs["addons"] = gitops.RepoDir(project.ID)
+	}
+	if project.EnterpriseEnabled && gitops.IsRepo(gitops.EnterpriseRepoDir(project.ID)) {
+		dirs["enterprise"] = gitops.EnterpriseRepoDir(project.ID)
+	}
+	if project.DesignThemesEnabled && gitops.IsRepo(gitops.DesignThemesRepoDir(project.ID)) {
+		dirs["design-themes"] = gitops.DesignThemesRepoDir(project.ID)
+	}
+	return dirs
+}
+
+// currentHostDirs returns the absolute host paths of a project's addons,
+// Enterprise and Design Themes mounts as they are on disk, without cloning
+// or updating any checkout. For recreating the Odoo container when only its
+// container settings changed.
+func currentHostDirs(project *store.Project) (addonsDir, entDir, dtDir string) {
+	abs := func(dir string) string {
+		if dir == "" {
+			return ""
+		}
+		if a, err := filepath.Abs(dir); err == nil {
+			return a
+		}
+		return dir
+	}
+	dirs := projectRepoDirs(project)
+	addonsDir = abs(dirs["addons"])
+	if project.LocalAddonsPath != "" {
+		if dir, err := validateLocalAddonsDir(project.LocalAddonsPath); err == nil {
+			addonsDir = dir
+		} else {
+			log.Printf("Warning: project %s: %v", project.ID, err)
+		}
+	}
+	return addonsDir, abs(dirs["enterprise"]), abs(dirs["design-themes"])
+}
+
+// dirtyRepos returns the locally modified tracked files of each of the
+// project's checkouts that has any, keyed by repo name.
+func (h *Handler) dirtyRepos(ctx context.Context, project *store.Project) map[string][]string {
+	dirty := map[string][]string{}
+	for name, dir := range projectRepoDirs(project) {
+		st, err := gitops.Status(ctx, dir)
+		if err != nil {
+			log.Printf("Warning: project %s: %v", project.ID, err)
+			continue
+		}
+		if st.Dirty {
+			dirty[name] = st.DirtyFiles()
+		}
+	}
+	return dirty
+}
+
+// stashDirtyRepos stashes local changes in the named checkouts before a
+// forced update so they can be recovered with "git stash pop". It stops at
+// the first checkout that cannot be stashed, as updating it would lose the
+// changes.
+func (h *Handler) stashDirtyRepos(ctx context.Context, project *store.Project, dirty map[string][]string) error {
+	dirs := projectRepoDirs(project)
+	for name := range dirty {
+		dir, ok := dirs[name]
+		if !ok {
+			continue
+		}
+		if err := gitops.StashChanges(ctx, dir); err != nil {
+			return err
+		}
+		log.Printf("Project %s: stashed local changes in %s repo before update", project.ID, name)
+	}
+	return nil
+}
+
+// writeDirtyConflict reports checkouts with local changes that an update
+// would clobber.
+// 409 → { "error": "...", "repos": { "addons": ["path", ...] } }
+func writeDirtyConflict(w http.ResponseWriter, dirty map[string][]string) {
+	names := make([]string, 0, len(dirty))
+	for name := range dirty {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": fmt.Sprintf("Local changes in %s would be overwritten. Commit or discard them, or force the update to stash them.", strings.Join(names, ", ")),
+		"repos": dirty,
+	})
+}
+
+// isTruthy parses boolean query parameters such as ?force=true or ?force=1.
+func isTruthy(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// projectRepoDir resolves the ?repo= query parameter (default "addons") to
+// one of the project's checkouts, writing an error response if it has none.
+func (h *Handler) projectRepoDir(w http.ResponseWriter, r *http.Request) (string, bool) {
+	project, ok := h.store.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return "", false
+	}
+	name := r.URL.Query().Get("repo")
+	if name == "" {
+		name = "addons"
+	}
+	dir, ok := projectRepoDirs(project)[name]
+	if !ok {
+		http.Error(w, "Repository "+name+" is not cloned for this project", http.StatusNotFound)
+		return "", false
+	}
+	return dir, true
+}
+
+// handleRepoStatus returns the branch, ahead/behind counts and changed files
+// of a project's checkout.
+// GET /api/projects/{id}/repo/status?repo=addons|enterprise|design-themes
+func (h *Handler) handleRepoStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dir, ok := h.projectRepoDir(w, r)
+	if !ok {
+		return
+	}
+
+	st, err := gitops.Status(r.Context(), dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleRepoLog returns the most recent commits of a project's checkout.
+// GET /api/projects/{id}/repo/log?repo=addons&limit=N
+func (h *Handler) handleRepoLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dir, ok := h.projectRepoDir(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
+			limit = n
+		}
+	}
+
+	commits, err := gitops.Log(r.Context(), dir, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commits)
+}
+
+// handleRepoDiff returns the uncommitted changes of a project's checkout
+// as a unified diff against HEAD, optionally limited to one file.
+// GET /api/projects/{id}/repo/diff?repo=addons&path=... → text/plain
+func (h *Handler) handleRepoDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dir, ok := h.projectRepoDir(w, r)
+	if !ok {
+		return
+	}
+
+	diff, truncated, err := gitops.Diff(r.Context(), dir, r.URL.Query().Get("path"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if truncated {
+		w.Header().Set("X-Diff-Truncated", "true")
+	}
+	io.WriteString(w, diff)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// manifestFiles are the file names that mark a directory as an Odoo module.
+var manifestFiles = []string{"__manifest__.py", "__openerp__.py"}
+
+// validateLocalAddonsDir checks that path is an existing host directory
+// whose subdirectories include at least one Odoo module, i.e. something
+// usable as an addons_path entry, and returns its cleaned form.
+func validateLocalAddonsDir(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("local addons path must be absolute: %s", path)
+	}
+	dir := filepath.Clean(path)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("local addons folder does not exist: %s", dir)
+		}
+		return "", fmt.Errorf("cannot access local addons folder: %w", err)
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("local addons path is not a directory: %s", dir)
+	}
+
+	if hasManifest(dir) {
+		return "", fmt.Errorf("%s is a single module; select the folder that contains it", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("cannot read local addons folder: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() && hasManifest(filepath.Join(dir, e.Name())) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no Odoo modules (__manifest__.py) found in %s", dir)
+}
+
+// hasManifest reports whether dir contains an Odoo module manifest.
+func hasManifest(dir string) bool {
+	for _, name := range manifestFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
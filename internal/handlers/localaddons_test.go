@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateLocalAddonsDir(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(parts ...string) string {
+		dir := filepath.Join(append([]string{root}, parts...)...)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	touch := func(parts ...string) string {
+		path := filepath.Join(append([]string{root}, parts...)...)
+		if err := os.WriteFile(path, []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	addons := mkdir("addons", "sale_custom")
+	touch("addons", "sale_custom", "__manifest__.py")
+	addons = filepath.Dir(addons)
+
+	legacy := mkdir("legacy", "old_module")
+	touch("legacy", "old_module", "__openerp__.py")
+	legacy = filepath.Dir(legacy)
+
+	module := mkdir("module")
+	touch("module", "__manifest__.py")
+
+	mkdir("empty")
+	mkdir("plain", "not_a_module")
+	touch("plain", "README.md")
+	mkdir("loose")
+	touch("loose", "__manifest__.py.bak")
+	file := touch("file.txt")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "folder of modules", path: addons, want: addons},
+		{name: "trailing slash and dots are cleaned", path: addons + "/./", want: addons},
+		{name: "parent segments are cleaned", path: filepath.Join(root, "empty") + "/../addons", want: addons},
+		{name: "legacy manifests", path: legacy, want: legacy},
+		{name: "relative path", path: "addons", wantErr: "must be absolute"},
+		{name: "relative with parent segments", path: "../" + filepath.Base(root), wantErr: "must be absolute"},
+		{name: "missing", path: filepath.Join(root, "missing"), wantErr: "does not exist"},
+		{name: "file", path: file, wantErr: "not a directory"},
+		{name: "single module", path: module, wantErr: "single module"},
+		{name: "empty folder", path: filepath.Join(root, "empty"), wantErr: "no Odoo modules"},
+		{name: "no manifests", path: filepath.Join(root, "plain"), wantErr: "no Odoo modules"},
+		{name: "look-alike manifest", path: filepath.Join(root, "loose"), wantErr: "no Odoo modules"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validateLocalAddonsDir(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateLocalAddonsDir(%q) error = %v, want one containing %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("validateLocalAddonsDir(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
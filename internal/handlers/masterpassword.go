@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/jota2rz/odoo-manager/internal/docker"
+)
+
+// masterPasswordKey is the odoo.conf option holding the Odoo master
+// password, which guards the database manager at /web/database.
+const masterPasswordKey = "admin_passwd"
+
+// setMasterPassword writes a new random master password into a project's
+// odoo.conf and returns it. Odoo picks it up on its next restart.
+func (h *Handler) setMasterPassword(ctx context.Context, projectID string) (string, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	password := base64.RawURLEncoding.EncodeToString(buf)
+	if _, err := h.dockerManager.SetOdooConfigOption(ctx, projectID, masterPasswordKey, password); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// isHashedMasterPassword reports whether a stored master password is a
+// passlib hash, which Odoo writes when the password is changed from the
+// database manager. The plaintext cannot be shown then.
+func isHashedMasterPassword(value string) bool {
+	return strings.HasPrefix(value, "$pbkdf2-") || strings.HasPrefix(value, "$argon2")
+}
+
+// masterPasswordRemoved reports whether an odoo.conf edit drops a master
+// password that was set before.
+func masterPasswordRemoved(previous, next string) bool {
+	before, _ := docker.OdooConfigValue(previous, masterPasswordKey)
+	after, _ := docker.OdooConfigValue(next, masterPasswordKey)
+	return before != "" && after == ""
+}
+
+// handleProjectMasterPassword shows and rotates a project's Odoo master
+// password. A new password takes effect when Odoo restarts.
+// GET  /api/projects/{id}/master-password → { "password": "...", "hashed": bool }
+// POST /api/projects/{id}/master-password → { "password": "..." } (new password)
+func (h *Handler) handleProjectMasterPassword(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := h.store.Get(id); !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if h.dockerManager == nil {
+		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		content, err := h.dockerManager.ReadOdooConfig(r.Context(), id)
+		if err != nil {
+			http.Error(w, "Failed to read odoo.conf: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		password, _ := docker.OdooConfigValue(content, masterPasswordKey)
+		hashed := isHashedMasterPassword(password)
+		if hashed {
+			password = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"password": password, "hashed": hashed})
+
+	case http.MethodPost:
+		password, err := h.setMasterPassword(r.Context(), id)
+		if err != nil {
+			http.Error(w, "Failed to set master password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"password": password})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/internal/oidc"
+	"github.com/jota2rz/odoo-manager/internal/store"
+	"github.com/jota2rz/odoo-manager/templates"
+)
+
+const (
+	oidcStateCookie   = "odoo_manager_oidc"
+	oidcLoginTTL      = 10 * time.Minute
+	defaultOIDCScopes = "openid profile email groups"
+	defaultOIDCGroups = "groups"
+)
+
+// oidcLogin is a sign-in that was sent to the provider and has not come
+// back yet, keyed by its state.
+type oidcLogin struct {
+	verifier string
+	nonce    string
+	next     string
+	expires  time.Time
+}
+
+// oidcSettings is the single sign-on configuration kept in settings.
+type oidcSettings struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	scopes       string
+	redirectURL  string // empty to derive it from the request
+	groupsClaim  string
+	roleMapping  string // "group=role" entries separated by commas or newlines
+	defaultRole  string // role for users in no mapped group, empty to refuse them
+}
+
+func (h *Handler) oidcSettings() oidcSettings {
+	cfg := oidcSettings{
+		issuer:       h.store.GetSetting("oidc_issuer"),
+		clientID:     h.store.GetSetting("oidc_client_id"),
+		clientSecret: h.store.GetSetting("oidc_client_secret"),
+		scopes:       h.store.GetSetting("oidc_scopes"),
+		redirectURL:  h.store.GetSetting("oidc_redirect_url"),
+		groupsClaim:  h.store.GetSetting("oidc_groups_claim"),
+		roleMapping:  h.store.GetSetting("oidc_role_mapping"),
+		defaultRole:  h.store.GetSetting("oidc_default_role"),
+	}
+	if cfg.scopes == "" {
+		cfg.scopes = defaultOIDCScopes
+	}
+	if cfg.groupsClaim == "" {
+		cfg.groupsClaim = defaultOIDCGroups
+	}
+	return cfg
+}
+
+// oidcEnabled reports whether single sign-on is configured.
+func (h *Handler) oidcEnabled() bool {
+	return h.store.GetSetting("oidc_issuer") != "" && h.store.GetSetting("oidc_client_id") != ""
+}
+
+// oidcProvider returns the discovered provider for the current settings,
+// discovering it again when they changed.
+func (h *Handler) oidcProvider(ctx context.Context, r *http.Request) (*oidc.Provider, oidcSettings, error) {
+	settings := h.oidcSettings()
+	cfg := oidc.Config{
+		Issuer:       settings.issuer,
+		ClientID:     settings.clientID,
+		ClientSecret: settings.clientSecret,
+		RedirectURL:  settings.redirectURL,
+		Scopes:       settings.scopes,
+	}
+	if cfg.RedirectURL == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		cfg.RedirectURL = scheme + "://" + r.Host + "/auth/oidc/callback"
+	}
+
+	h.oidcMu.Lock()
+	defer h.oidcMu.Unlock()
+	if h.oidcProv != nil && h.oidcProv.Config() == cfg {
+		return h.oidcProv, settings, nil
+	}
+	p, err := oidc.Discover(ctx, cfg)
+	if err != nil {
+		return nil, settings, err
+	}
+	h.oidcProv = p
+	return p, settings, nil
+}
+
+// saveOIDCSettings validates and stores the single sign-on settings present
+// in a settings update. A new issuer must answer discovery.
+func (h *Handler) saveOIDCSettings(ctx context.Context, issuer, clientID, clientSecret, scopes, redirectURL, groupsClaim, roleMapping, defaultRole *string) error {
+	if issuer != nil {
+		*issuer = strings.TrimSuffix(strings.TrimSpace(*issuer), "/")
+		if *issuer != "" {
+			if u, err := url.Parse(*issuer); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return fmt.Errorf("oidc_issuer must be an http(s) URL")
+			}
+			if _, err := oidc.Discover(ctx, oidc.Config{Issuer: *issuer}); err != nil {
+				return fmt.Errorf("oidc_issuer: %v", err)
+			}
+		}
+	}
+	if clientSecret != nil && *clientSecret == "****" {
+		clientSecret = nil // the masked value from GET, keep the stored secret
+	}
+	if scopes != nil {
+		*scopes = strings.Join(strings.Fields(*scopes), " ")
+		if *scopes != "" && !strings.Contains(" "+*scopes+" ", " openid ") {
+			return fmt.Errorf("oidc_scopes must include openid")
+		}
+	}
+	if redirectURL != nil {
+		*redirectURL = strings.TrimSpace(*redirectURL)
+		if *redirectURL != "" && !strings.HasSuffix(*redirectURL, "/auth/oidc/callback") {
+			return fmt.Errorf("oidc_redirect_url must end with /auth/oidc/callback")
+		}
+	}
+	if roleMapping != nil {
+		if _, err := parseRoleMapping(*roleMapping); err != nil {
+			return err
+		}
+	}
+	if defaultRole != nil && *defaultRole != "" && !store.IsValidRole(*defaultRole) {
+		return fmt.Errorf("oidc_default_role must be empty or one of %s", strings.Join(store.Roles, ", "))
+	}
+
+	for key, value := range map[string]*string{
+		"oidc_issuer":        issuer,
+		"oidc_client_id":     clientID,
+		"oidc_client_secret": clientSecret,
+		"oidc_scopes":        scopes,
+		"oidc_redirect_url":  redirectURL,
+		"oidc_groups_claim":  groupsClaim,
+		"oidc_role_mapping":  roleMapping,
+		"oidc_default_role":  defaultRole,
+	} {
+		if value == nil {
+			continue
+		}
+		if err := h.store.SetSetting(key, strings.TrimSpace(*value)); err != nil {
+			return fmt.Errorf("failed to save %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// parseRoleMapping parses "group=role" entries separated by commas or
+// newlines into a map from group to role.
+func parseRoleMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, entry := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		group, role, ok := strings.Cut(entry, "=")
+		group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+		if !ok || group == "" || !store.IsValidRole(role) {
+			return nil, fmt.Errorf("invalid role mapping %q, expected group=role with role one of %s", entry, strings.Join(store.Roles, ", "))
+		}
+		mapping[group] = role
+	}
+	return mapping, nil
+}
+
+// oidcRole returns the most privileged role granted by the user's groups,
+// falling back to the default role, or "" when the user gets no access.
+func oidcRole(settings oidcSettings, groups []string) string {
+	mapping, _ := parseRoleMapping(settings.roleMapping)
+	best := ""
+	for _, group := range groups {
+		if role, ok := mapping[group]; ok && roleGrants[role] > roleGrants[best] {
+			best = role
+		}
+	}
+	if best == "" {
+		return settings.defaultRole
+	}
+	return best
+}
+
+// handleOIDCLogin sends the browser to the provider with a fresh state,
+// nonce and PKCE verifier.
+// GET /auth/oidc/login?next=/path
+func (h *Handler) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.URL.Query().Get("next"))
+	if !h.oidcEnabled() {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(next), http.StatusSeeOther)
+		return
+	}
+	provider, _, err := h.oidcProvider(r.Context(), r)
+	if err != nil {
+		h.oidcFailed(w, r, next, err)
+		return
+	}
+
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.Error(w, "Failed to start sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	h.oidcMu.Lock()
+	for s, login := range h.oidcLogins {
+		if now.After(login.expires) {
+			delete(h.oidcLogins, s)
+		}
+	}
+	h.oidcLogins[state] = &oidcLogin{verifier: verifier, nonce: nonce, next: next, expires: now.Add(oidcLoginTTL)}
+	h.oidcMu.Unlock()
+
+	// The cookie ties the state to this browser so a callback URL cannot be
+	// replayed in someone else's.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/auth/oidc/",
+		MaxAge:   int(oidcLoginTTL / time.Second),
+		HttpOnly: true,
+		Secure:   h.tlsMode != "",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, provider.AuthCodeURL(state, nonce, oidc.Challenge(verifier)), http.StatusFound)
+}
+
+// handleOIDCCallback finishes a sign-in: it exchanges the code, verifies
+// the ID token, provisions or updates the user from its claims and starts
+// a session.
+// GET /auth/oidc/callback?code=...&state=...
+func (h *Handler) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	state := q.Get("state")
+
+	h.oidcMu.Lock()
+	login, ok := h.oidcLogins[state]
+	delete(h.oidcLogins, state)
+	h.oidcMu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/", MaxAge: -1})
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if !ok || err != nil || cookie.Value != state || time.Now().After(login.expires) {
+		h.oidcFailed(w, r, "/", fmt.Errorf("the sign-in expired or was started in another browser"))
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		h.oidcFailed(w, r, login.next, fmt.Errorf("the provider answered %s %s", e, q.Get("error_description")))
+		return
+	}
+
+	provider, settings, err := h.oidcProvider(r.Context(), r)
+	if err != nil {
+		h.oidcFailed(w, r, login.next, err)
+		return
+	}
+	claims, err := provider.Exchange(r.Context(), q.Get("code"), login.verifier, login.nonce)
+	if err != nil {
+		h.oidcFailed(w, r, login.next, err)
+		return
+	}
+
+	user, err := h.oidcUser(r, settings, claims)
+	if err != nil {
+		h.oidcFailed(w, r, login.next, err)
+		return
+	}
+
+	token, err := h.store.CreateSession(user.ID, sessionTTL)
+	if err != nil {
+		http.Error(w, "Failed to create session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.setSessionCookie(w, token)
+	if h.audit != nil {
+		h.audit.Log(r.WithContext(auth.WithUser(r.Context(), user)), "Signed in through single sign-on")
+	}
+	http.Redirect(w, r, login.next, http.StatusSeeOther)
+}
+
+// oidcUser returns the user for verified claims, creating it on first
+// sign-in and updating its role from the mapped groups on every sign-in.
+func (h *Handler) oidcUser(r *http.Request, settings oidcSettings, claims oidc.Claims) (*store.User, error) {
+	// Subjects are only unique per provider
+	issuer, subject := strings.TrimSuffix(claims.String("iss"), "/"), claims.String("sub")
+	role := oidcRole(settings, claims.Strings(settings.groupsClaim))
+	if role == "" {
+		return nil, fmt.Errorf("none of your groups grants access to Odoo Manager")
+	}
+
+	if user, ok := h.store.GetUserByOIDCSubject(issuer, subject); ok {
+		if user.Role != role {
+			if user.Role == store.RoleAdmin && h.store.CountAdmins() <= 1 {
+				return user, nil // never lock out the last admin
+			}
+			if err := h.store.SetUserRole(user.ID, role); err != nil {
+				return nil, err
+			}
+			if h.audit != nil {
+				h.audit.Log(r.WithContext(auth.WithUser(r.Context(), user)), fmt.Sprintf("Role changed from %s to %s by single sign-on groups", user.Role, role))
+			}
+			user.Role = role
+		}
+		return user, nil
+	}
+
+	username := claims.String("preferred_username")
+	if username == "" {
+		username = claims.String("email")
+	}
+	if username == "" {
+		username = subject
+	}
+	if _, exists := h.store.GetUserByUsername(username); exists {
+		return nil, fmt.Errorf("a user named %q already exists; ask an admin to remove it before signing in with single sign-on", username)
+	}
+	user, err := h.store.CreateOIDCUser(username, issuer, subject, role)
+	if err != nil {
+		return nil, err
+	}
+	if h.audit != nil {
+		h.audit.Log(r.WithContext(auth.WithUser(r.Context(), user)), fmt.Sprintf("Provisioned user %q with the %s role through single sign-on", username, role))
+	}
+	return user, nil
+}
+
+// oidcFailed records a failed single sign-on and shows the reason on the
+// login page.
+func (h *Handler) oidcFailed(w http.ResponseWriter, r *http.Request, next string, err error) {
+	if h.audit != nil {
+		h.audit.Log(r, "Failed single sign-on: "+err.Error())
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	templates.Login("", next, "Single sign-on failed: "+err.Error(), h.oidcEnabled()).Render(r.Context(), w)
+}
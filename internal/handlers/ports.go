@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jota2rz/odoo-manager/internal/events"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// Ports of new projects are allocated from this range unless the
+// port_range setting (e.g. "8069-8199") overrides it.
+const (
+	defaultPortRangeStart = 8069
+	defaultPortRangeEnd   = 8199
+)
+
+// defaultBindAddress is the host IP new projects publish their ports on
+// unless the bind_address setting overrides it: only this machine can
+// reach them.
+const defaultBindAddress = "127.0.0.1"
+
+// parseBindAddress validates a bind address: 127.0.0.1 (this machine only),
+// 0.0.0.0 (all interfaces) or the IP of a specific interface.
+func parseBindAddress(v string) (string, bool) {
+	ip := net.ParseIP(strings.TrimSpace(v))
+	if ip == nil {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+// bindAddress returns the configured bind address for new projects.
+func (h *Handler) bindAddress() string {
+	if addr, ok := parseBindAddress(h.store.GetSetting("bind_address")); ok {
+		return addr
+	}
+	return defaultBindAddress
+}
+
+// portConflict describes who holds a port a project cannot use.
+type portConflict struct {
+	Error string `json:"error"`
+	Port  int    `json:"port"`
+	Owner string `json:"owner,omitempty"` // project or Docker container name; empty for another host process
+}
+
+// parsePortRange parses "start-end" into a valid unprivileged port range.
+func parsePortRange(v string) (int, int, bool) {
+	a, b, ok := strings.Cut(v, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err1 := strconv.Atoi(strings.TrimSpace(a))
+	end, err2 := strconv.Atoi(strings.TrimSpace(b))
+	if err1 != nil || err2 != nil || start < 1024 || end > 65535 || start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// portRange returns the configured range for allocated ports.
+func (h *Handler) portRange() (int, int) {
+	if start, end, ok := parsePortRange(h.store.GetSetting("port_range")); ok {
+		return start, end
+	}
+	return defaultPortRangeStart, defaultPortRangeEnd
+}
+
+// hostPortFree reports whether nothing on the host listens on port.
+func hostPortFree(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// portUsage is a snapshot of the ports taken by projects and by running
+// Docker containers.
+type portUsage struct {
+	projects   map[int]*store.Project
+	containers map[int]string // port → container name
+}
+
+// currentPortUsage collects the ports assigned to projects and published
+// by running containers.
+func (h *Handler) currentPortUsage(ctx context.Context) portUsage {
+	u := portUsage{projects: map[int]*store.Project{}, containers: map[int]string{}}
+	for _, p := range h.store.List() {
+		for _, port := range projectPorts(p) {
+			u.projects[port] = p
+		}
+	}
+	if h.dockerManager != nil && h.IsDockerUp() {
+		ports, err := h.dockerManager.PublishedPorts(ctx)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			u.containers = ports
+		}
+	}
+	return u
+}
+
+// conflict reports why port cannot be used by the project with projectID
+// ("" for a new project), probing the host for listeners Docker does not
+// know about. Returns nil if the port is free.
+func (u portUsage) conflict(port int, projectID string) *portConflict {
+	if p, ok := u.projects[port]; ok && p.ID != projectID {
+		return &portConflict{
+			Error: fmt.Sprintf("Port %d is already assigned to project %q", port, p.Name),
+			Port:  port,
+			Owner: p.Name,
+		}
+	}
+	if name, ok := u.containers[port]; ok {
+		if projectID != "" && (name == "odoo-"+projectID || name == "postgres-"+projectID) {
+			return nil // the project's own running container
+		}
+		return &portConflict{
+			Error: fmt.Sprintf("Port %d is in use by Docker container %s", port, name),
+			Port:  port,
+			Owner: name,
+		}
+	}
+	if !hostPortFree(port) {
+		return &portConflict{
+			Error: fmt.Sprintf("Port %d is in use by another process on the host", port),
+			Port:  port,
+		}
+	}
+	return nil
+}
+
+// projectPorts returns every host port a project publishes.
+func projectPorts(p *store.Project) []int {
+	ports := []int{p.Port}
+	for _, port := range []int{p.GeventPort, p.DebugPort, p.DBPort} {
+		if port != 0 {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// checkProjectPorts reports the first of a project's ports it cannot
+// publish, including ports it assigns twice. project.ID is "" for a new
+// project.
+func (h *Handler) checkProjectPorts(ctx context.Context, project *store.Project) *portConflict {
+	usage := h.currentPortUsage(ctx)
+	seen := map[int]bool{}
+	for _, port := range projectPorts(project) {
+		if port < 1024 || port > 65535 {
+			return &portConflict{Error: fmt.Sprintf("Port %d is outside 1024-65535", port), Port: port}
+		}
+		if seen[port] {
+			return &portConflict{Error: fmt.Sprintf("Port %d is assigned twice", port), Port: port}
+		}
+		seen[port] = true
+		if c := usage.conflict(port, project.ID); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// allocatePort returns the first free port of the configured range,
+// searching from `from` (wrapping around) when it lies within the range.
+func (h *Handler) allocatePort(ctx context.Context, from int) (int, error) {
+	start, end := h.portRange()
+	if from < start || from > end {
+		from = start
+	}
+	usage := h.currentPortUsage(ctx)
+	for i := 0; i <= end-start; i++ {
+		port := start + (from-start+i)%(end-start+1)
+		if usage.conflict(port, "") == nil {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port in range %d-%d", start, end)
+}
+
+// createProjectWithPorts stores a new project, first picking a free port of
+// the range (searching from `from`) when it has none and checking that
+// nothing holds its ports. portsMu is held until the project is stored, so
+// concurrent creates cannot be given the same port. A conflict is returned
+// instead of an error when the ports are unavailable.
+func (h *Handler) createProjectWithPorts(ctx context.Context, project *store.Project, from int) (*portConflict, error) {
+	h.portsMu.Lock()
+	defer h.portsMu.Unlock()
+	if project.Port == 0 {
+		port, err := h.allocatePort(ctx, from)
+		if err != nil {
+			return &portConflict{Error: err.Error()}, nil
+		}
+		project.Port = port
+	}
+	if conflict := h.checkProjectPorts(ctx, project); conflict != nil {
+		return conflict, nil
+	}
+	return nil, h.store.Create(project)
+}
+
+// writePortConflict responds 409 with the port and its owner.
+func writePortConflict(w http.ResponseWriter, c *portConflict) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(c)
+}
+
+// handleProjectPorts reads and changes the optional extra ports of a
+// project: longpolling/gevent (8072), debugpy (5678) and PostgreSQL (5432),
+// and the host IP all its ports are published on. A 0 leaves the port
+// unpublished; a debug port also starts Odoo under debugpy. Changes
+// recreate the affected containers, keeping their data.
+// GET /api/projects/{id}/ports → { "port", "gevent_port", "debug_port", "db_port", "bind_address", "exposed" }
+// PUT /api/projects/{id}/ports { "gevent_port"?, "debug_port"?, "db_port"?, "bind_address"? } → same
+func (h *Handler) handleProjectPorts(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	project, ok := h.store.Get(id)
+	if !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPut:
+		var body struct {
+			GeventPort  *int    `json:"gevent_port"`
+			DebugPort   *int    `json:"debug_port"`
+			DBPort      *int    `json:"db_port"`
+			BindAddress *string `json:"bind_address"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if isTransientStatus(project.Status) {
+			http.Error(w, "Project is busy, try again when the current operation finishes", http.StatusConflict)
+			return
+		}
+
+		previous := *project
+		if body.GeventPort != nil {
+			project.GeventPort = *body.GeventPort
+		}
+		if body.DebugPort != nil {
+			project.DebugPort = *body.DebugPort
+		}
+		if body.DBPort != nil {
+			project.DBPort = *body.DBPort
+		}
+		if body.BindAddress != nil {
+			addr, ok := parseBindAddress(*body.BindAddress)
+			if !ok {
+				http.Error(w, "bind_address must be an IP address such as 127.0.0.1 or 0.0.0.0", http.StatusBadRequest)
+				return
+			}
+			project.BindAddress = addr
+		}
+		// Same lock as creates, so two changes cannot claim one free port
+		h.portsMu.Lock()
+		var err error
+		conflict := h.checkProjectPorts(r.Context(), project)
+		if conflict == nil {
+			err = h.store.Update(project)
+		}
+		h.portsMu.Unlock()
+		if conflict != nil {
+			writePortConflict(w, conflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update project: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if h.dockerManager != nil {
+			// Recreate Postgres first so Odoo starts against the new container
+			bindChanged := project.BindAddress != previous.BindAddress
+			if project.DBPort != previous.DBPort || (bindChanged && project.DBPort != 0) {
+				if err := h.dockerManager.RecreatePostgresContainer(r.Context(), project); err != nil {
+					log.Printf("Warning: failed to recreate postgres container for project %s: %v", project.ID, err)
+				}
+			}
+			if bindChanged || project.DBPort != previous.DBPort || project.GeventPort != previous.GeventPort || project.DebugPort != previous.DebugPort {
+				// Only the published ports change: keep the checkouts as they are
+				addonsDir, entDir, dtDir := currentHostDirs(project)
+				if err := h.dockerManager.RecreateOdooContainer(r.Context(), project, addonsDir, entDir, dtDir); err != nil {
+					log.Printf("Warning: failed to recreate odoo container for project %s: %v", project.ID, err)
+				}
+			}
+		}
+		h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: project.ID, Data: project})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"port":         project.Port,
+		"gevent_port":  project.GeventPort,
+		"debug_port":   project.DebugPort,
+		"db_port":      project.DBPort,
+		"bind_address": project.BindAddress,
+		"exposed":      project.Exposed,
+	})
+}
+
+// handleProjectDBCredentials returns the PostgreSQL login of a project, for
+// connecting local database tools through its published PostgreSQL port.
+// GET /api/projects/{id}/db-credentials → { "user", "password" }
+func (h *Handler) handleProjectDBCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := h.store.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"user":     project.DBUser,
+		"password": project.DBPassword,
+	})
+}
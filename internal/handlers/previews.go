@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jota2rz/odoo-manager/internal/events"
+	"github.com/jota2rz/odoo-manager/internal/gitops"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+const (
+	defaultPreviewTTL = 72 * time.Hour
+	maxPreviewTTL     = 30 * 24 * time.Hour
+)
+
+// dbNamePattern restricts database names passed to the odoo CLI.
+var dbNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// previewRequest is the body of POST /api/previews.
+type previewRequest struct {
+	RepoURL    string `json:"repo_url"`
+	Branch     string `json:"branch"`
+	TemplateID string `json:"template_id"` // optional if one project uses repo_url
+	Database   string `json:"database"`    // optional if the template has one database
+	TTLHours   int    `json:"ttl_hours"`   // default 72
+}
+
+// handlePreviews lists and creates branch preview environments: short-lived
+// copies of a template project with another branch of its repository checked
+// out and a clone of its database.
+// GET  /api/previews → [ project, ... ]
+// POST /api/previews { "repo_url", "branch", "template_id"?, "database"?, "ttl_hours"? } → 201 project
+func (h *Handler) handlePreviews(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		previews := []*store.Project{}
+		for _, p := range h.visibleProjects(r) {
+			if p.PreviewOf != "" {
+				previews = append(previews, p)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(previews)
+
+	case http.MethodPost:
+		h.createPreview(w, r)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) createPreview(w http.ResponseWriter, r *http.Request) {
+	var body previewRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	body.Branch = strings.TrimSpace(body.Branch)
+	if body.RepoURL == "" || body.Branch == "" {
+		http.Error(w, "repo_url and branch are required", http.StatusBadRequest)
+		return
+	}
+	if h.dockerManager == nil {
+		http.Error(w, "Docker manager not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	template, status, msg := h.previewTemplate(body.TemplateID, body.RepoURL)
+	if template == nil {
+		http.Error(w, msg, status)
+		return
+	}
+	if template.Status != "running" {
+		http.Error(w, "Template project must be running to clone its database", http.StatusConflict)
+		return
+	}
+
+	token := h.store.GetSetting("github_pat")
+	branches, err := gitops.ListBranches(r.Context(), template.GitRepoURL, token)
+	if err != nil {
+		http.Error(w, "Failed to list branches: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !slices.Contains(branches, body.Branch) {
+		http.Error(w, fmt.Sprintf("Branch %q not found in %s", body.Branch, template.GitRepoURL), http.StatusUnprocessableEntity)
+		return
+	}
+
+	database := body.Database
+	if database == "" {
+		dbs, err := h.dockerManager.ListDatabases(r.Context(), template)
+		if err != nil {
+			http.Error(w, "Failed to list template databases: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		switch len(dbs) {
+		case 0:
+			http.Error(w, "Template project has no database to clone", http.StatusConflict)
+			return
+		case 1:
+			database = dbs[0]
+		default:
+			http.Error(w, "Template project has several databases, choose one with \"database\": "+strings.Join(dbs, ", "), http.StatusConflict)
+			return
+		}
+	}
+	if !dbNamePattern.MatchString(database) {
+		http.Error(w, "Invalid database name", http.StatusBadRequest)
+		return
+	}
+
+	ttl := defaultPreviewTTL
+	if body.TTLHours > 0 {
+		ttl = time.Duration(body.TTLHours) * time.Hour
+	}
+	if ttl > maxPreviewTTL {
+		http.Error(w, fmt.Sprintf("ttl_hours must be at most %d", int(maxPreviewTTL.Hours())), http.StatusBadRequest)
+		return
+	}
+	expires := time.Now().Add(ttl)
+
+	preview := store.Project{
+		ID:                  uuid.New().String(),
+		Name:                h.uniqueProjectName(template.Name + " @ " + body.Branch),
+		Description:         fmt.Sprintf("Preview of %s on branch %s", template.Name, body.Branch),
+		OdooVersion:         template.OdooVersion,
+		PostgresVersion:     template.PostgresVersion,
+		BindAddress:         template.BindAddress,
+		Status:              "creating",
+		GitRepoURL:          template.GitRepoURL,
+		GitRepoBranch:       body.Branch,
+		EnterpriseEnabled:   template.EnterpriseEnabled,
+		DesignThemesEnabled: template.DesignThemesEnabled,
+		PreviewOf:           template.ID,
+		ExpiresAt:           &expires,
+	}
+	conflict, err := h.createProjectWithPorts(r.Context(), &preview, template.Port+1)
+	if conflict != nil {
+		writePortConflict(w, conflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Preview %s: %s branch %s from template %s, expires %s", preview.ID, preview.GitRepoURL, body.Branch, template.ID, expires.Format(time.RFC3339))
+
+	h.events.Publish(events.Event{
+		Type:      events.ProjectCreated,
+		ProjectID: preview.ID,
+		Data:      preview,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(preview)
+
+	go h.provisionPreview(preview.ID, template.ID, database)
+}
+
+// previewTemplate resolves the template project of a preview: the one with
+// templateID, or else the only non-preview project using repoURL. On
+// failure it returns nil with an HTTP status and message.
+func (h *Handler) previewTemplate(templateID, repoURL string) (*store.Project, int, string) {
+	key := normalizeRepoURL(repoURL)
+	if templateID != "" {
+		p, ok := h.store.Get(templateID)
+		if !ok {
+			return nil, http.StatusNotFound, "Template project not found"
+		}
+		if p.GitRepoURL == "" || normalizeRepoURL(p.GitRepoURL) != key {
+			return nil, http.StatusUnprocessableEntity, "Template project does not use this repository"
+		}
+		return p, 0, ""
+	}
+
+	var matches []*store.Project
+	for _, p := range h.store.List() {
+		if p.PreviewOf == "" && p.GitRepoURL != "" && normalizeRepoURL(p.GitRepoURL) == key {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, http.StatusNotFound, "No project uses this repository"
+	case 1:
+		return matches[0], 0, ""
+	default:
+		return nil, http.StatusConflict, "Several projects use this repository, choose one with \"template_id\""
+	}
+}
+
+// uniqueProjectName returns name, or name with a numeric suffix if it is
+// already taken.
+func (h *Handler) uniqueProjectName(name string) string {
+	candidate := name
+	for i := 2; h.store.NameExists(candidate, ""); i++ {
+		candidate = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return candidate
+}
+
+// provisionPreview creates and starts a preview's containers with the
+// template's odoo.conf and clones the template's database into it. Runs
+// asynchronously; progress is reported through the usual project events.
+func (h *Handler) provisionPreview(previewID, templateID, database string) {
+	defer func() {
+		if rv := recover(); rv != nil {
+			log.Printf("PANIC in provisionPreview for %s: %v", previewID, rv)
+			if project, ok := h.store.Get(previewID); ok {
+				project.Status = "error"
+				_ = h.store.Update(project)
+				h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: previewID, Data: project})
+			}
+		}
+	}()
+
+	h.createProjectContainers(previewID)
+	preview, ok := h.store.Get(previewID)
+	if !ok || preview.Status == "error" {
+		return
+	}
+
+	// Same settings as the template; both mount the same kinds of addons
+	if conf, err := h.dockerManager.ReadOdooConfig(context.Background(), templateID); err == nil {
+		if err := h.dockerManager.WriteOdooConfig(context.Background(), previewID, conf); err != nil {
+			log.Printf("Warning: preview %s: failed to copy odoo.conf: %v", previewID, err)
+		}
+	}
+	// ...but not the template's master password
+	if _, err := h.setMasterPassword(context.Background(), previewID); err != nil {
+		log.Printf("Warning: preview %s: failed to set master password: %v", previewID, err)
+	}
+
+	h.events.Publish(events.Event{Type: events.ProjectActionPending, ProjectID: previewID, Data: "starting"})
+	h.startProjectContainers(previewID)
+	preview, ok = h.store.Get(previewID)
+	if !ok || preview.Status != "running" {
+		return
+	}
+
+	h.events.Publish(events.Event{Type: events.ProjectActionPending, ProjectID: previewID, Data: "cloning database"})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	log.Printf("Preview %s: cloning database %s from %s ...", previewID, database, templateID)
+	template, ok := h.store.Get(templateID)
+	if !ok {
+		log.Printf("Preview %s: template project %s no longer exists", previewID, templateID)
+		preview.Status = "error"
+	} else if err := h.dockerManager.CloneDatabase(ctx, template, preview, database); err != nil {
+		log.Printf("Preview %s: database clone failed: %v", previewID, err)
+		preview.Status = "error"
+	} else {
+		log.Printf("Preview %s: database %s cloned", previewID, database)
+		preview.Status, _ = h.dockerManager.GetProjectStatus(context.Background(), previewID)
+	}
+	_ = h.store.Update(preview)
+	h.events.Publish(events.Event{Type: events.ProjectStatusChanged, ProjectID: previewID, Data: preview})
+}
+
+// StartPreviewReaper runs a background loop that deletes preview projects
+// once their expiry time has passed.
+func (h *Handler) StartPreviewReaper(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+			h.reapExpiredPreviews()
+		}
+	}()
+}
+
+// reapExpiredPreviews deletes every expired preview that is not in the
+// middle of another operation.
+func (h *Handler) reapExpiredPreviews() {
+	now := time.Now()
+	for _, p := range h.store.List() {
+		if p.ExpiresAt == nil || p.ExpiresAt.After(now) || isTransientStatus(p.Status) {
+			continue
+		}
+		log.Printf("Preview %s (%s) expired at %s, deleting", p.ID, p.Name, p.ExpiresAt.Format(time.RFC3339))
+		h.events.Publish(events.Event{Type: events.ProjectActionPending, ProjectID: p.ID, Data: "deleting"})
+		h.deleteProject(p)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+)
+
+const (
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+)
+
+// csrfToken returns the CSRF token of a session. It is derived from the
+// session token so it needs no storage and changes with every sign-in.
+func csrfToken(sessionToken string) string {
+	sum := sha256.Sum256([]byte("odoo-manager csrf\x00" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// isUnsafeMethod reports whether a request method can change state.
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// checkCSRF reports whether an unsafe request carries the CSRF token of
+// the session, in the X-CSRF-Token header or the csrf_token form field.
+func checkCSRF(r *http.Request, sessionToken string) bool {
+	if !isUnsafeMethod(r.Method) {
+		return true
+	}
+	sent := r.Header.Get(csrfHeader)
+	if sent == "" {
+		sent = r.PostFormValue(csrfField)
+	}
+	return sent != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(csrfToken(sessionToken))) == 1
+}
+
+// checkCSRFQuery reports whether a session request carries its CSRF token
+// in the csrf_token query parameter. It guards GET routes that change state
+// and are opened with EventSource, which cannot send headers, so a link or
+// top-level navigation from another site cannot trigger them. Requests
+// authenticated with an API token cannot be forged and pass.
+func checkCSRFQuery(r *http.Request) bool {
+	if _, ok := auth.TokenFrom(r.Context()); ok {
+		return true
+	}
+	want := auth.CSRFToken(r.Context())
+	sent := r.URL.Query().Get(csrfField)
+	return want != "" && sent != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(want)) == 1
+}
+
+// allowedOrigins returns the extra origins, besides the manager's own, that
+// may call the API from a browser, as "scheme://host[:port]".
+func (h *Handler) allowedOrigins() []string {
+	origins, _ := parseAllowedOrigins(h.store.GetSetting("allowed_origins"))
+	return origins
+}
+
+// parseAllowedOrigins parses a comma- or whitespace-separated list of
+// origins.
+func parseAllowedOrigins(s string) ([]string, error) {
+	var origins []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' }) {
+		u, err := url.Parse(field)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return nil, fmt.Errorf("invalid origin %q, expected scheme://host[:port]", field)
+		}
+		origins = append(origins, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+	return origins, nil
+}
+
+// originAllowed reports whether origin is the manager's own or one of the
+// allowed origins.
+func (h *Handler) originAllowed(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	origin = strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range h.allowedOrigins() {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin rejects requests from other sites: any request whose Origin
+// header is not allowed, and unsafe requests whose Referer is not. Requests
+// without either, such as those from scripts, are left to authentication
+// and the CSRF token.
+func (h *Handler) checkOrigin(r *http.Request) (string, bool) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !h.originAllowed(r, origin) {
+			return fmt.Sprintf("Forbidden: requests from %s are not allowed", origin), false
+		}
+		return "", true
+	}
+	if referer := r.Header.Get("Referer"); referer != "" && isUnsafeMethod(r.Method) {
+		if !h.originAllowed(r, referer) {
+			return "Forbidden: requests from other sites are not allowed", false
+		}
+	}
+	return "", true
+}
+
+// setCORSHeaders lets an allowed origin read API responses and event
+// streams, and answers its preflight requests. Other sites get no CORS
+// headers at all.
+func (h *Handler) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if origin == "" || !h.originAllowed(r, origin) {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	if isPreflight(r) {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+csrfHeader)
+		w.Header().Set("Access-Control-Max-Age", "600")
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// withPageHeaders sets the security headers of HTML pages: a content
+// security policy limiting scripts, styles and connections to this site,
+// and framing limited to the allowed origins.
+func (h *Handler) withPageHeaders(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		frameAncestors := "'none'"
+		if origins := h.allowedOrigins(); len(origins) > 0 {
+			frameAncestors = strings.Join(origins, " ")
+		} else {
+			w.Header().Set("X-Frame-Options", "DENY")
+		}
+		// Inline handlers are used throughout the templates, hence
+		// 'unsafe-inline'; everything is still limited to this origin.
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; "+
+			"script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; "+
+			"img-src 'self' data:; connect-src 'self'; object-src 'none'; "+
+			"base-uri 'self'; form-action 'self'; frame-ancestors "+frameAncestors)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "same-origin")
+		next(w, r)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+func TestCheckCSRF(t *testing.T) {
+	const session = "session-token"
+	token := csrfToken(session)
+	tests := []struct {
+		name   string
+		method string
+		header string
+		form   string
+		want   bool
+	}{
+		{name: "GET needs no token", method: "GET", want: true},
+		{name: "HEAD needs no token", method: "HEAD", want: true},
+		{name: "OPTIONS needs no token", method: "OPTIONS", want: true},
+		{name: "POST with header", method: "POST", header: token, want: true},
+		{name: "DELETE with header", method: "DELETE", header: token, want: true},
+		{name: "POST with form field", method: "POST", form: token, want: true},
+		{name: "POST without token", method: "POST"},
+		{name: "PUT without token", method: "PUT"},
+		{name: "wrong token", method: "POST", header: "guess"},
+		{name: "token of another session", method: "POST", header: csrfToken("other-session")},
+		{name: "session token instead of CSRF token", method: "POST", header: session},
+		{name: "wrong header wins over form field", method: "POST", header: "guess", form: token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{csrfField: {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			r := httptest.NewRequest(tt.method, "/api/projects", body)
+			if tt.form != "" {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.header != "" {
+				r.Header.Set(csrfHeader, tt.header)
+			}
+			if got := checkCSRF(r, session); got != tt.want {
+				t.Errorf("checkCSRF = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCSRFQuery(t *testing.T) {
+	token := csrfToken("session-token")
+	tests := []struct {
+		name     string
+		query    string
+		session  string // CSRF token of the request's session
+		apiToken bool
+		want     bool
+	}{
+		{name: "matching token", query: token, session: token, want: true},
+		{name: "missing token", session: token},
+		{name: "wrong token", query: "guess", session: token},
+		{name: "no session", query: token},
+		{name: "empty token and session"},
+		{name: "API token", apiToken: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/projects/p1/backup"
+			if tt.query != "" {
+				target += "?" + url.Values{csrfField: {tt.query}}.Encode()
+			}
+			r := httptest.NewRequest("GET", target, nil)
+			ctx := r.Context()
+			if tt.session != "" {
+				ctx = auth.WithCSRFToken(ctx, tt.session)
+			}
+			if tt.apiToken {
+				ctx = auth.WithToken(ctx, &store.APIToken{ID: 1})
+			}
+			if got := checkCSRFQuery(r.WithContext(ctx)); got != tt.want {
+				t.Errorf("checkCSRFQuery = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "https://app.example.com", want: []string{"https://app.example.com"}},
+		{in: "https://App.Example.com:8443/", want: []string{"https://app.example.com:8443"}},
+		{in: "https://a.example, http://b.example:3000\nhttps://c.example", want: []string{"https://a.example", "http://b.example:3000", "https://c.example"}},
+		{in: " , ", want: nil},
+		{in: "app.example.com", wantErr: true},
+		{in: "ftp://app.example.com", wantErr: true},
+		{in: "https://", wantErr: true},
+		{in: "https://app.example.com/path", wantErr: true},
+		{in: "https://app.example.com/?q=1", wantErr: true},
+		{in: "https://ok.example, javascript:alert(1)", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseAllowedOrigins(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseAllowedOrigins(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAllowedOrigins(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	h := newTestHandler(t)
+	if err := h.store.SetSetting("allowed_origins", "https://app.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		method  string
+		origin  string
+		referer string
+		want    bool
+	}{
+		{name: "no origin or referer", method: "POST", want: true},
+		{name: "same origin", method: "POST", origin: "http://manager.local", want: true},
+		{name: "same origin other case", method: "POST", origin: "http://MANAGER.local", want: true},
+		{name: "allowed origin", method: "POST", origin: "https://app.example.com", want: true},
+		{name: "allowed host over another scheme", method: "POST", origin: "http://app.example.com"},
+		{name: "other origin", method: "POST", origin: "https://evil.example"},
+		{name: "other origin on GET", method: "GET", origin: "https://evil.example"},
+		{name: "null origin", method: "POST", origin: "null"},
+		{name: "same site referer", method: "POST", referer: "http://manager.local/projects", want: true},
+		{name: "other site referer", method: "POST", referer: "https://evil.example/page"},
+		{name: "other site referer on GET", method: "GET", referer: "https://evil.example/page", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://manager.local/api/projects", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.referer != "" {
+				r.Header.Set("Referer", tt.referer)
+			}
+			if msg, got := h.checkOrigin(r); got != tt.want {
+				t.Errorf("checkOrigin = %v (%q), want %v", got, msg, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+// SetTLS records how the manager serves HTTPS: "files" for configured
+// certificate files, "local-ca" for certificates issued by the local CA,
+// whose certificate caPEM is then offered for download.
+func (h *Handler) SetTLS(mode string, caPEM []byte) {
+	h.tlsMode = mode
+	h.caPEM = caPEM
+}
+
+// SetProxyTLS marks projects as served through the TLS-terminating reverse
+// proxy and turns on proxy_mode in every project's odoo.conf, so Odoo
+// trusts X-Forwarded-* and generates https:// URLs. Existing projects pick
+// the change up on their next restart.
+func (h *Handler) SetProxyTLS() {
+	h.proxyTLS = true
+	for _, p := range h.store.List() {
+		if h.ensureProxyMode(p.ID) {
+			log.Printf("Project %s: enabled proxy_mode in odoo.conf (applies on next restart)", p.ID)
+		}
+	}
+}
+
+// ensureProxyMode sets proxy_mode = True in a project's odoo.conf when
+// projects are served through the TLS proxy. Reports whether it changed.
+func (h *Handler) ensureProxyMode(projectID string) bool {
+	if !h.proxyTLS || h.dockerManager == nil {
+		return false
+	}
+	changed, err := h.dockerManager.SetOdooConfigOption(context.Background(), projectID, "proxy_mode", "True")
+	if err != nil {
+		log.Printf("Warning: project %s: failed to enable proxy_mode: %v", projectID, err)
+		return false
+	}
+	return changed
+}
+
+// handleCACert serves the local CA certificate so browsers and operating
+// systems can be told to trust it.
+// GET /api/tls/ca.pem
+func (h *Handler) handleCACert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.caPEM == nil {
+		http.Error(w, "The local certificate authority is not enabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="odoo-manager-ca.pem"`)
+	w.Write(h.caPEM)
+}
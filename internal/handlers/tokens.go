@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// tokenNameRe limits token names to characters that read well in the
+// audit log.
+var tokenNameRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// handleTokens lists and creates the signed-in user's API tokens. The token
+// value is only returned by POST.
+// GET  /api/tokens → [{ "id": 1, "name": "ci", "scope": "operate", "expires_at": ..., "last_used_at": ... }]
+// POST /api/tokens { "name": "ci", "scope": "operate", "expires_in_days": 90 } → { "token": "omt_...", ... }
+func (h *Handler) handleTokens(w http.ResponseWriter, r *http.Request) {
+	user, ok := auth.UserFrom(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens := h.store.ListAPITokens(user.ID)
+		if tokens == nil {
+			tokens = []*store.APIToken{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+
+	case http.MethodPost:
+		var req struct {
+			Name          string `json:"name"`
+			Scope         string `json:"scope"`
+			ExpiresInDays int    `json:"expires_in_days"` // 0 = never
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if !tokenNameRe.MatchString(req.Name) {
+			http.Error(w, "name must be 1-64 letters, digits, dots, dashes or underscores", http.StatusBadRequest)
+			return
+		}
+		if !store.IsValidScope(req.Scope) {
+			http.Error(w, "scope must be one of "+strings.Join(store.Scopes, ", "), http.StatusBadRequest)
+			return
+		}
+		if scopeGrants[req.Scope] > roleGrants[user.Role] {
+			http.Error(w, fmt.Sprintf("The %s role cannot create tokens with the %s scope", user.Role, req.Scope), http.StatusForbidden)
+			return
+		}
+		if req.ExpiresInDays < 0 {
+			http.Error(w, "expires_in_days cannot be negative", http.StatusBadRequest)
+			return
+		}
+
+		token, value, err := h.store.CreateAPIToken(user.ID, req.Name, req.Scope, time.Duration(req.ExpiresInDays)*24*time.Hour)
+		if err != nil {
+			http.Error(w, "Failed to create token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if h.audit != nil {
+			h.audit.Log(r, fmt.Sprintf("Created API token %q with the %s scope", token.Name, token.Scope))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(struct {
+			*store.APIToken
+			Token string `json:"token"`
+		}{token, value})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleToken revokes one of the signed-in user's API tokens.
+// DELETE /api/tokens/{tid}
+func (h *Handler) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, ok := auth.UserFrom(r.Context())
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("tid"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.store.DeleteAPIToken(user.ID, id); err != nil {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/events"
+	"github.com/jota2rz/odoo-manager/internal/gitops"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// defaultUpdatePollMinutes is how often upstream repos and images are
+// checked unless the update_poll_minutes setting overrides it (0 disables).
+const defaultUpdatePollMinutes = 15
+
+// RepoUpdate is the upstream state of one of a project's repos.
+type RepoUpdate struct {
+	Repo   string `json:"repo"` // addons, enterprise or design-themes
+	Remote string `json:"remote,omitempty"`
+	Behind int    `json:"behind"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ProjectUpdates is the result of the last update check of a project.
+type ProjectUpdates struct {
+	Repos       []RepoUpdate `json:"repos"`
+	ImageUpdate bool         `json:"image_update"` // a newer odoo:{version} image exists
+	CheckedAt   time.Time    `json:"checked_at"`
+}
+
+// changedFrom reports whether u differs from prev in anything shown to
+// the user.
+func (u *ProjectUpdates) changedFrom(prev *ProjectUpdates) bool {
+	if prev == nil {
+		return true
+	}
+	if u.ImageUpdate != prev.ImageUpdate || len(u.Repos) != len(prev.Repos) {
+		return true
+	}
+	for i := range u.Repos {
+		if u.Repos[i].Repo != prev.Repos[i].Repo || u.Repos[i].Behind != prev.Repos[i].Behind {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePollInterval reads the poll interval from settings. Returns 0 when
+// polling is disabled.
+func (h *Handler) updatePollInterval() time.Duration {
+	minutes := defaultUpdatePollMinutes
+	if v := h.store.GetSetting("update_poll_minutes"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			minutes = n
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// StartUpdatePoller runs a background loop that periodically compares each
+// project's checked-out commits with the remote branch heads and its Odoo
+// image with the registry, for teams that cannot receive webhooks. Changes
+// are broadcast as project_updates SSE events.
+func (h *Handler) StartUpdatePoller(ctx context.Context) {
+	go func() {
+		// Give startup (and Docker) a moment before the first round
+		delay := time.Minute
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			interval := h.updatePollInterval()
+			if interval == 0 {
+				delay = time.Minute // re-check the setting
+				continue
+			}
+			delay = interval
+
+			for _, p := range h.store.List() {
+				if ctx.Err() != nil {
+					return
+				}
+				if isTransientStatus(p.Status) {
+					continue
+				}
+				h.checkProjectUpdates(ctx, p)
+			}
+		}
+	}()
+}
+
+// isTransientStatus reports whether a background operation is in progress
+// for a project with the given status.
+func isTransientStatus(status string) bool {
+	switch status {
+	case "creating", "starting", "stopping", "deleting", "updating", "updating-repo":
+		return true
+	}
+	return false
+}
+
+// refreshProjectUpdates re-checks a project after its repos or image were
+// updated so a stale "update available" badge is cleared promptly.
+func (h *Handler) refreshProjectUpdates(projectID string) {
+	project, ok := h.store.Get(projectID)
+	if !ok {
+		return
+	}
+	h.checkProjectUpdates(context.Background(), project)
+}
+
+// checkProjectUpdates computes and stores the update state of a project
+// and publishes it if it changed.
+func (h *Handler) checkProjectUpdates(ctx context.Context, project *store.Project) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	token := h.store.GetSetting("github_pat")
+	upstreams := map[string][2]string{ // repo → {url, branch}
+		"addons":        {project.GitRepoURL, project.GitRepoBranch},
+		"enterprise":    {gitops.EnterpriseRepoURL, project.OdooVersion},
+		"design-themes": {gitops.DesignThemesRepoURL, project.OdooVersion},
+	}
+
+	result := &ProjectUpdates{Repos: []RepoUpdate{}, CheckedAt: time.Now()}
+	dirs := projectRepoDirs(project)
+	for _, repo := range []string{"addons", "enterprise", "design-themes"} {
+		dir, ok := dirs[repo]
+		if !ok {
+			continue
+		}
+		up := upstreams[repo]
+		behind, remote, err := gitops.CommitsBehind(ctx, dir, up[0], token, up[1])
+		if errors.Is(err, gitops.ErrNotBranch) {
+			continue // pinned to a tag or commit
+		}
+		ru := RepoUpdate{Repo: repo, Remote: remote, Behind: behind}
+		if err != nil {
+			log.Printf("Project %s: update check of %s repo failed: %v", project.ID, repo, err)
+			ru.Error = err.Error()
+		}
+		result.Repos = append(result.Repos, ru)
+	}
+
+	if h.dockerManager != nil && h.IsDockerUp() {
+		avail, err := h.dockerManager.OdooImageUpdateAvailable(ctx, project)
+		if err != nil {
+			log.Printf("Project %s: image update check failed: %v", project.ID, err)
+		}
+		result.ImageUpdate = avail
+	}
+
+	h.updatesMu.Lock()
+	prev := h.updates[project.ID]
+	h.updates[project.ID] = result
+	h.updatesMu.Unlock()
+
+	if result.changedFrom(prev) {
+		h.events.Publish(events.Event{Type: events.ProjectUpdates, ProjectID: project.ID, Data: result})
+	}
+}
+
+// forgetProjectUpdates drops the stored update state of a deleted project.
+func (h *Handler) forgetProjectUpdates(projectID string) {
+	h.updatesMu.Lock()
+	delete(h.updates, projectID)
+	h.updatesMu.Unlock()
+}
+
+// handleUpdates returns the last update check of every project the user can
+// see, keyed by project ID.
+// GET /api/updates → { "<id>": { "repos": [...], "image_update": bool, "checked_at": ... } }
+func (h *Handler) handleUpdates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.updatesMu.RLock()
+	out := make(map[string]*ProjectUpdates, len(h.updates))
+	for id, u := range h.updates {
+		out[id] = u
+	}
+	h.updatesMu.RUnlock()
+
+	for id := range out {
+		if !h.canSeeProject(r, id) {
+			delete(out, id)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jota2rz/odoo-manager/internal/auth"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// handleUsers lists and creates user accounts.
+// GET  /api/users → [{ "id": 1, "username": "...", "role": "...", ... }]
+// POST /api/users { "username": "...", "password": "...", "role": "developer" }
+func (h *Handler) handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users := h.store.ListUsers()
+		if users == nil {
+			users = []*store.User{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+
+	case http.MethodPost:
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Role     string `json:"role"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Username = strings.TrimSpace(req.Username)
+		if req.Username == "" {
+			http.Error(w, "username is required", http.StatusBadRequest)
+			return
+		}
+		if !store.IsValidRole(req.Role) {
+			http.Error(w, "role must be one of "+strings.Join(store.Roles, ", "), http.StatusBadRequest)
+			return
+		}
+		if len(req.Password) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+		if _, exists := h.store.GetUserByUsername(req.Username); exists {
+			http.Error(w, fmt.Sprintf("User %q already exists", req.Username), http.StatusConflict)
+			return
+		}
+
+		user, err := h.store.CreateUser(req.Username, req.Password, req.Role)
+		if err != nil {
+			http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(user)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleUser changes the role or password of a user, or deletes them. The
+// last admin can neither be demoted nor deleted, and admins cannot delete
+// themselves.
+// PUT    /api/users/{uid} { "role": "viewer", "password": "..." } (either or both)
+// DELETE /api/users/{uid}
+func (h *Handler) handleUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("uid"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	user, ok := h.store.GetUser(id)
+	if !ok {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	lastAdmin := user.Role == store.RoleAdmin && h.store.CountAdmins() <= 1
+
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Role     *string `json:"role"`
+			Password *string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Role != nil && *req.Role != user.Role {
+			if !store.IsValidRole(*req.Role) {
+				http.Error(w, "role must be one of "+strings.Join(store.Roles, ", "), http.StatusBadRequest)
+				return
+			}
+			if lastAdmin {
+				http.Error(w, "The last admin cannot be given another role", http.StatusConflict)
+				return
+			}
+			if err := h.store.SetUserRole(id, *req.Role); err != nil {
+				http.Error(w, "Failed to change role: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if req.Password != nil {
+			if user.OIDCSubject != "" {
+				http.Error(w, "Users that sign in through single sign-on have no password", http.StatusBadRequest)
+				return
+			}
+			if len(*req.Password) < minPasswordLength {
+				http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+				return
+			}
+			if err := h.store.SetUserPassword(id, *req.Password); err != nil {
+				http.Error(w, "Failed to change password: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_ = h.store.DeleteUserSessions(id, "")
+		}
+		user, _ = h.store.GetUser(id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+
+	case http.MethodDelete:
+		if current, ok := auth.UserFrom(r.Context()); ok && current.ID == id {
+			http.Error(w, "You cannot delete your own account", http.StatusConflict)
+			return
+		}
+		if lastAdmin {
+			http.Error(w, "The last admin cannot be deleted", http.StatusConflict)
+			return
+		}
+		if err := h.store.DeleteUser(id); err != nil {
+			http.Error(w, "Failed to delete user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleProjectMembers shows and replaces the users assigned to a project.
+// Only developers and viewers can be assigned; admins and maintainers see
+// every project anyway.
+// GET /api/projects/{id}/members → { "member_ids": [2, 5], "users": [{ "id": 2, "username": "...", "role": "developer" }] }
+// PUT /api/projects/{id}/members { "member_ids": [2, 5] }
+func (h *Handler) handleProjectMembers(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := h.store.Get(id); !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	assignable := map[int64]*store.User{}
+	users := []*store.User{}
+	for _, u := range h.store.ListUsers() {
+		if !u.SeesAllProjects() {
+			assignable[u.ID] = u
+			users = append(users, u)
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		memberIDs := []int64{}
+		for _, uid := range h.store.ProjectMemberIDs(id) {
+			if assignable[uid] != nil {
+				memberIDs = append(memberIDs, uid)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"member_ids": memberIDs, "users": users})
+
+	case http.MethodPut:
+		var req struct {
+			MemberIDs []int64 `json:"member_ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		for _, uid := range req.MemberIDs {
+			if assignable[uid] == nil {
+				http.Error(w, fmt.Sprintf("User %d is not a developer or viewer", uid), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := h.store.SetProjectMembers(id, req.MemberIDs); err != nil {
+			http.Error(w, "Failed to save members: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// maxWebhookBody caps the size of accepted webhook payloads.
+const maxWebhookBody = 5 << 20
+
+// pushEvent is the subset of a GitHub, GitLab or Gitea push payload needed
+// to find the projects to update. GitHub and Gitea describe the repository
+// under "repository", GitLab under "project".
+type pushEvent struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL      string `json:"clone_url"`
+		HTMLURL       string `json:"html_url"`
+		SSHURL        string `json:"ssh_url"`
+		GitHTTPURL    string `json:"git_http_url"`
+		Homepage      string `json:"homepage"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Project struct {
+		GitHTTPURL    string `json:"git_http_url"`
+		WebURL        string `json:"web_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"project"`
+}
+
+// repoKeys returns the normalised forms of every repository URL in the
+// payload.
+func (e *pushEvent) repoKeys() map[string]bool {
+	keys := map[string]bool{}
+	for _, u := range []string{
+		e.Repository.CloneURL, e.Repository.HTMLURL, e.Repository.SSHURL,
+		e.Repository.GitHTTPURL, e.Repository.Homepage,
+		e.Project.GitHTTPURL, e.Project.WebURL,
+	} {
+		if k := normalizeRepoURL(u); k != "" {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+// defaultBranch returns the repository's default branch, if reported.
+func (e *pushEvent) defaultBranch() string {
+	if e.Repository.DefaultBranch != "" {
+		return e.Repository.DefaultBranch
+	}
+	return e.Project.DefaultBranch
+}
+
+// normalizeRepoURL reduces a repository URL to "host/owner/repo" so HTTPS,
+// SSH and web URLs of the same repository compare equal.
+func normalizeRepoURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	// scp-like SSH syntax: git@github.com:owner/repo.git
+	if !strings.Contains(raw, "://") {
+		if at := strings.Index(raw, "@"); at >= 0 {
+			raw = raw[at+1:]
+		}
+		raw = "ssh://" + strings.Replace(raw, ":", "/", 1)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return strings.ToLower(u.Hostname() + "/" + path)
+}
+
+// verifyWebhook checks the request against a project's webhook secret:
+// GitHub's X-Hub-Signature-256 and Gitea's X-Gitea-Signature are
+// HMAC-SHA256 signatures of the body, GitLab sends the secret itself in
+// X-Gitlab-Token.
+func verifyWebhook(r *http.Request, body []byte, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+	sig := strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if sig == "" {
+		sig = r.Header.Get("X-Gitea-Signature")
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// webhookEvent returns the event name from the provider's header.
+func webhookEvent(r *http.Request) string {
+	for _, h := range []string{"X-GitHub-Event", "X-Gitea-Event", "X-Gitlab-Event"} {
+		if v := r.Header.Get(h); v != "" {
+			return strings.ToLower(v)
+		}
+	}
+	return ""
+}
+
+// handleGitWebhook receives push events from GitHub, GitLab or Gitea and
+// updates the repos of every project whose repository URL and branch match
+// and whose webhook secret verifies the request. Projects with local
+// changes in their checkouts are skipped rather than stashed.
+// POST /api/webhooks/git → 202 { "updated": [ids], "skipped": { id: reason } }
+func (h *Handler) handleGitWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var evt pushEvent
+	if err := json.Unmarshal(body, &evt); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	keys := evt.repoKeys()
+
+	// Only projects whose secret signs this request may be acted on, and
+	// only they learn whether the repository matched
+	var verified []*store.Project
+	for _, p := range h.store.List() {
+		if p.GitRepoURL == "" || !keys[normalizeRepoURL(p.GitRepoURL)] {
+			continue
+		}
+		if verifyWebhook(r, body, h.store.GetWebhookSecret(p.ID)) {
+			verified = append(verified, p)
+		}
+	}
+	if len(verified) == 0 {
+		http.Error(w, "No project matches this repository and signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := webhookEvent(r)
+	if event == "ping" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "pong"})
+		return
+	}
+	if event != "push" && event != "push hook" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	branch, isBranch := strings.CutPrefix(evt.Ref, "refs/heads/")
+	updated := []string{}
+	skipped := map[string]string{}
+	for _, p := range verified {
+		projectBranch := p.GitRepoBranch
+		if projectBranch == "" {
+			projectBranch = evt.defaultBranch()
+		}
+		switch {
+		case !isBranch || branch != projectBranch:
+			skipped[p.ID] = "branch does not match"
+		case h.dockerManager == nil:
+			skipped[p.ID] = "docker not available"
+		default:
+			if dirty := h.dirtyRepos(r.Context(), p); len(dirty) > 0 {
+				skipped[p.ID] = "local changes in working tree"
+				log.Printf("Project %s: webhook update skipped, local changes in %d repo(s)", p.ID, len(dirty))
+				continue
+			}
+			log.Printf("Project %s: webhook push to %s, updating repos", p.ID, branch)
+			h.startRepoUpdate(p, nil)
+			updated = append(updated, p.ID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"updated": updated,
+		"skipped": skipped,
+	})
+}
+
+// handleProjectWebhook manages a project's webhook secret.
+// GET    /api/projects/{id}/webhook → { "enabled": bool, "path": "/api/webhooks/git" }
+// POST   /api/projects/{id}/webhook → { "secret": "..." } (new secret, shown once)
+// DELETE /api/projects/{id}/webhook → disables webhooks for the project
+func (h *Handler) handleProjectWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := h.store.Get(id); !ok {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"enabled": h.store.GetWebhookSecret(id) != "",
+			"path":    "/api/webhooks/git",
+		})
+
+	case http.MethodPost:
+		buf := make([]byte, 24)
+		if _, err := rand.Read(buf); err != nil {
+			http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		secret := hex.EncodeToString(buf)
+		if err := h.store.SetWebhookSecret(id, secret); err != nil {
+			http.Error(w, "Failed to save secret", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"secret": secret,
+			"path":   "/api/webhooks/git",
+		})
+
+	case http.MethodDelete:
+		if err := h.store.SetWebhookSecret(id, ""); err != nil {
+			http.Error(w, "Failed to disable webhook", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
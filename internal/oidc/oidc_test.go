@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProvider is a minimal OIDC provider serving discovery, its key set
+// and a token endpoint that returns the ID token built by claims.
+type testProvider struct {
+	t      *testing.T
+	srv    *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+
+	challenge string // code_challenge of the last authorization request
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testProvider{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.srv.URL,
+			"authorization_endpoint": p.srv.URL + "/authorize",
+			"token_endpoint":         p.srv.URL + "/token",
+			"jwks_uri":               p.srv.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   enc.EncodeToString(key.N.Bytes()),
+			"e":   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "the-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		if Challenge(r.Form.Get("code_verifier")) != p.challenge {
+			http.Error(w, `{"error":"invalid_grant","error_description":"PKCE verification failed"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(p.claims)})
+	})
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+// sign returns an RS256 JWT of claims.
+func (p *testProvider) sign(claims map[string]any) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return signed + "." + enc.EncodeToString(sig)
+}
+
+// authorize follows AuthCodeURL as a browser would and records the PKCE
+// challenge the provider received.
+func (p *testProvider) authorize(authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		p.t.Fatalf("code_challenge_method = %q, want S256", q.Get("code_challenge_method"))
+	}
+	p.challenge = q.Get("code_challenge")
+}
+
+func TestExchange(t *testing.T) {
+	p := newTestProvider(t)
+	ctx := context.Background()
+	provider, err := Discover(ctx, Config{
+		Issuer:      p.srv.URL,
+		ClientID:    "odoo-manager",
+		RedirectURL: "https://manager.example/auth/oidc/callback",
+		Scopes:      "openid profile",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   p.srv.URL,
+			"aud":   "odoo-manager",
+			"sub":   "user-1",
+			"nonce": "the-nonce",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	tests := []struct {
+		name     string
+		mutate   func(c map[string]any)
+		verifier string // sent to the token endpoint; empty means the matching one
+		wantErr  string
+	}{
+		{name: "valid", mutate: func(map[string]any) {}},
+		{name: "audience list", mutate: func(c map[string]any) { c["aud"] = []string{"other", "odoo-manager"} }},
+		{name: "wrong PKCE verifier", mutate: func(map[string]any) {}, verifier: "not-the-verifier", wantErr: "PKCE"},
+		{name: "wrong nonce", mutate: func(c map[string]any) { c["nonce"] = "replayed" }, wantErr: "nonce"},
+		{name: "missing nonce", mutate: func(c map[string]any) { delete(c, "nonce") }, wantErr: "nonce"},
+		{name: "wrong audience", mutate: func(c map[string]any) { c["aud"] = "other-client" }, wantErr: "not meant for this client"},
+		{name: "wrong issuer", mutate: func(c map[string]any) { c["iss"] = "https://evil.example" }, wantErr: "issued by"},
+		{name: "expired", mutate: func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: "expired"},
+		{name: "missing expiry", mutate: func(c map[string]any) { delete(c, "exp") }, wantErr: "expired"},
+		{name: "missing subject", mutate: func(c map[string]any) { delete(c, "sub") }, wantErr: "no subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.mutate(claims)
+			p.claims = claims
+
+			verifier, err := RandomString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.authorize(provider.AuthCodeURL("the-state", "the-nonce", Challenge(verifier)))
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			got, err := provider.Exchange(ctx, "the-code", verifier, "the-nonce")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if got.String("sub") != "user-1" || got.String("iss") != p.srv.URL {
+				t.Fatalf("claims = %v", got)
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsForgedSignature(t *testing.T) {
+	p := newTestProvider(t)
+	ctx := context.Background()
+	provider, err := Discover(ctx, Config{Issuer: p.srv.URL, ClientID: "odoo-manager", Scopes: "openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := map[string]any{"iss": p.srv.URL, "aud": "odoo-manager", "sub": "user-1", "nonce": "n", "exp": time.Now().Add(time.Hour).Unix()}
+	token := p.sign(claims)
+
+	// Swap the payload for one claiming another subject, keeping the signature
+	claims["sub"] = "admin"
+	payload, _ := json.Marshal(claims)
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	if _, err := provider.verify(ctx, forged, "n"); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("verify error = %v, want an invalid signature", err)
+	}
+	if _, err := provider.verify(ctx, token, "n"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jota2rz/odoo-manager/internal/events"
+	"github.com/jota2rz/odoo-manager/internal/store"
+)
+
+// DefaultDomain is the wildcard domain projects are served under unless
+// configured otherwise. Browsers resolve *.localhost to the loopback
+// address without any DNS setup.
+const DefaultDomain = "localhost"
+
+// route is where requests for one project are forwarded.
+type route struct {
+	projectID  string
+	name       string
+	host       string // address the ports are reachable at
+	port       int    // Odoo HTTP port
+	geventPort int    // longpolling/gevent port, 0 if not published
+	createdAt  time.Time
+}
+
+// Proxy is a reverse proxy that serves each project's Odoo instance at
+// {project-name}.{domain}. Its routing table follows project events.
+type Proxy struct {
+	domain string
+
+	mu     sync.RWMutex
+	byID   map[string]route
+	byHost map[string]route // hostname label → route
+
+	rp *httputil.ReverseProxy
+}
+
+// New creates a proxy for hostnames under domain (DefaultDomain if empty).
+func New(domain string) *Proxy {
+	domain = strings.Trim(strings.ToLower(domain), ".")
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	p := &Proxy{
+		domain: domain,
+		byID:   map[string]route{},
+		byHost: map[string]route{},
+	}
+	p.rp = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(r.In.Context().Value(targetKey{}).(*url.URL))
+			r.SetXForwarded()
+			r.Out.Host = r.In.Host // Odoo builds its URLs from the public host
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Proxy: %s%s: %v", r.Host, r.URL.Path, err)
+			http.Error(w, "Project is not reachable — is it running?", http.StatusBadGateway)
+		},
+	}
+	return p
+}
+
+// targetKey carries the upstream URL from ServeHTTP to Rewrite.
+type targetKey struct{}
+
+// Domain returns the wildcard domain the proxy serves.
+func (p *Proxy) Domain() string { return p.domain }
+
+// Slug reduces a project name to the DNS label it is served at: lowercase
+// letters, digits and single hyphens, e.g. "Sales V17" → "sales-v17".
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "-")
+	if len(s) > 63 {
+		s = strings.TrimSuffix(s[:63], "-")
+	}
+	return s
+}
+
+// Load replaces the routing table with the given projects.
+func (p *Proxy) Load(projects []*store.Project) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.byID = map[string]route{}
+	for _, project := range projects {
+		p.byID[project.ID] = routeFor(project)
+	}
+	p.reindex()
+}
+
+// Run keeps the routing table in sync with project events until ctx is
+// cancelled.
+func (p *Proxy) Run(ctx context.Context, hub *events.Hub) {
+	ch := hub.Subscribe()
+	go func() {
+		defer hub.Unsubscribe(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-ch:
+				p.apply(evt)
+			}
+		}
+	}()
+}
+
+// apply updates the routing table from one event.
+func (p *Proxy) apply(evt events.Event) {
+	var project *store.Project
+	switch evt.Type {
+	case events.ProjectDeleted:
+		p.mu.Lock()
+		delete(p.byID, evt.ProjectID)
+		p.reindex()
+		p.mu.Unlock()
+		return
+	case events.ProjectCreated, events.ProjectStatusChanged:
+		switch d := evt.Data.(type) {
+		case *store.Project:
+			project = d
+		case store.Project:
+			project = &d
+		}
+	}
+	if project == nil {
+		return
+	}
+
+	r := routeFor(project)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.byID[project.ID] == r {
+		return
+	}
+	p.byID[project.ID] = r
+	p.reindex()
+}
+
+// reindex rebuilds the hostname index. When two names reduce to the same
+// label the older project keeps it. Callers hold p.mu.
+func (p *Proxy) reindex() {
+	p.byHost = make(map[string]route, len(p.byID))
+	for _, r := range p.byID {
+		label := Slug(r.name)
+		if label == "" {
+			continue
+		}
+		if cur, ok := p.byHost[label]; ok && !r.createdAt.Before(cur.createdAt) {
+			continue
+		}
+		p.byHost[label] = r
+	}
+}
+
+func routeFor(project *store.Project) route {
+	return route{
+		projectID:  project.ID,
+		name:       project.Name,
+		host:       upstreamHost(project.BindAddress),
+		port:       project.Port,
+		geventPort: project.GeventPort,
+		createdAt:  project.CreatedAt,
+	}
+}
+
+// upstreamHost returns the address to reach ports published on bindAddress:
+// loopback for all-interface binds, the bound IP otherwise.
+func upstreamHost(bindAddress string) string {
+	ip := net.ParseIP(bindAddress)
+	if ip == nil || ip.IsUnspecified() {
+		return "127.0.0.1"
+	}
+	return ip.String()
+}
+
+// lookup returns the route for a request's Host header.
+func (p *Proxy) lookup(host string) (route, string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+p.domain)
+	if !ok || strings.Contains(label, ".") {
+		return route{}, "", false
+	}
+	p.mu.RLock()
+	r, ok := p.byHost[label]
+	p.mu.RUnlock()
+	return r, label, ok
+}
+
+// isGeventPath reports whether path belongs to Odoo's longpolling/gevent
+// worker: the websocket bus (Odoo 16+) or the older longpolling endpoint.
+func isGeventPath(path string) bool {
+	return path == "/websocket" || strings.HasPrefix(path, "/websocket/") ||
+		strings.HasPrefix(path, "/longpolling/")
+}
+
+// ServeHTTP forwards the request to the project named by its Host header.
+// Websocket and longpolling requests go to the gevent port when the
+// project publishes one.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt, label, ok := p.lookup(r.Host)
+	if !ok {
+		if label == "" {
+			http.Error(w, fmt.Sprintf("Open a project at http://{project-name}.%s", p.domain), http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("No project is served at %s.%s", label, p.domain), http.StatusNotFound)
+		}
+		return
+	}
+
+	port := rt.port
+	if rt.geventPort != 0 && isGeventPath(r.URL.Path) {
+		port = rt.geventPort
+	}
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort(rt.host, strconv.Itoa(port))}
+	p.rp.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), targetKey{}, target)))
+}
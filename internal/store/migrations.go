@@ -117,6 +117,188 @@ var migrations = []migration{
 			return err
 		},
 	},
+	{
+		version:     7,
+		description: "create repo_revisions table and pinned_revision column",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS repo_revisions (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					project_id TEXT NOT NULL,
+					ref TEXT NOT NULL DEFAULT '',
+					addons_commit TEXT NOT NULL DEFAULT '',
+					enterprise_commit TEXT NOT NULL DEFAULT '',
+					design_themes_commit TEXT NOT NULL DEFAULT '',
+					created_at DATETIME NOT NULL
+				)
+			`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_repo_revisions_project ON repo_revisions (project_id, id)`); err != nil {
+				return err
+			}
+			// Revision a project was rolled back to, 0 when its repos follow their ref
+			_, err := tx.Exec(`ALTER TABLE projects ADD COLUMN pinned_revision INTEGER NOT NULL DEFAULT 0`)
+			return err
+		},
+	},
+	{
+		version:     8,
+		description: "add webhook_secret column",
+		up: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`ALTER TABLE projects ADD COLUMN webhook_secret TEXT NOT NULL DEFAULT ''`)
+			return err
+		},
+	},
+	{
+		version:     9,
+		description: "add local_addons_path column",
+		up: func(tx *sql.Tx) error {
+			_, err := tx.Exec(`ALTER TABLE projects ADD COLUMN local_addons_path TEXT NOT NULL DEFAULT ''`)
+			return err
+		},
+	},
+	{
+		version:     10,
+		description: "add preview_of and expires_at columns",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`ALTER TABLE projects ADD COLUMN preview_of TEXT NOT NULL DEFAULT ''`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`ALTER TABLE projects ADD COLUMN expires_at DATETIME`)
+			return err
+		},
+	},
+	{
+		version:     11,
+		description: "add gevent_port, debug_port and db_port columns",
+		up: func(tx *sql.Tx) error {
+			for _, col := range []string{"gevent_port", "debug_port", "db_port"} {
+				if _, err := tx.Exec(`ALTER TABLE projects ADD COLUMN ` + col + ` INTEGER NOT NULL DEFAULT 0`); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version:     12,
+		description: "add db_user and db_password columns",
+		up: func(tx *sql.Tx) error {
+			// Existing projects keep the credentials their Postgres volume was
+			// initialised with. The password is stored in plaintext here and
+			// encrypted by ResealSecrets on startup.
+			if _, err := tx.Exec(`ALTER TABLE projects ADD COLUMN db_user TEXT NOT NULL DEFAULT 'odoo'`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`ALTER TABLE projects ADD COLUMN db_password TEXT NOT NULL DEFAULT 'odoo'`)
+			return err
+		},
+	},
+	{
+		version:     13,
+		description: "add bind_address column",
+		up: func(tx *sql.Tx) error {
+			// Existing projects stay published on all interfaces
+			_, err := tx.Exec(`ALTER TABLE projects ADD COLUMN bind_address TEXT NOT NULL DEFAULT '0.0.0.0'`)
+			return err
+		},
+	},
+	{
+		version:     14,
+		description: "create users and sessions tables",
+		up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS users (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+					password_hash TEXT NOT NULL,
+					created_at DATETIME NOT NULL,
+					updated_at DATETIME NOT NULL
+				)
+			`); err != nil {
+				return err
+			}
+			// Sessions are looked up by the SHA-256 of the cookie token so a
+			// leaked database does not hand out valid cookies.
+			if _, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS sessions (
+					token_hash TEXT PRIMARY KEY,
+					user_id INTEGER NOT NULL,
+					created_at DATETIME NOT NULL,
+					expires_at DATETIME NOT NULL
+				)
+			`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id)`)
+			return err
+		},
+	},
+	{
+		version:     15,
+		description: "add user roles and project_members table",
+		up: func(tx *sql.Tx) error {
+			// Accounts created before roles existed could do everything
+			if _, err := tx.Exec(`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'viewer'`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`UPDATE users SET role = 'admin'`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS project_members (
+					project_id TEXT NOT NULL,
+					user_id INTEGER NOT NULL,
+					PRIMARY KEY (project_id, user_id)
+				)
+			`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_project_members_user ON project_members (user_id)`)
+			return err
+		},
+	},
+	{
+		version:     16,
+		description: "create api_tokens table",
+		up: func(tx *sql.Tx) error {
+			// Like sessions, tokens are stored as the SHA-256 of their value.
+			if _, err := tx.Exec(`
+				CREATE TABLE IF NOT EXISTS api_tokens (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					user_id INTEGER NOT NULL,
+					name TEXT NOT NULL,
+					scope TEXT NOT NULL,
+					token_hash TEXT NOT NULL UNIQUE,
+					created_at DATETIME NOT NULL,
+					expires_at DATETIME,
+					last_used_at DATETIME
+				)
+			`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens (user_id)`)
+			return err
+		},
+	},
+	{
+		version:     17,
+		description: "add oidc_issuer and oidc_subject to users",
+		up: func(tx *sql.Tx) error {
+			// Users signing in through OpenID Connect are matched by the
+			// provider's issuer and subject, as subjects are only unique
+			// per issuer; local users leave both NULL.
+			if _, err := tx.Exec(`ALTER TABLE users ADD COLUMN oidc_issuer TEXT`); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`ALTER TABLE users ADD COLUMN oidc_subject TEXT`); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_oidc_identity ON users (oidc_issuer, oidc_subject)`)
+			return err
+		},
+	},
 }
 
 // getSchemaVersion returns the current schema version using SQLite's built-in user_version pragma.
@@ -0,0 +1,110 @@
+package store
+
+import "time"
+
+// RepoRevision records the commits checked out for a project's repos after a
+// clone or pull. Empty commit fields mean the repo was not configured.
+type RepoRevision struct {
+	ID                 int64     `json:"id"`
+	ProjectID          string    `json:"project_id"`
+	Ref                string    `json:"ref"`
+	AddonsCommit       string    `json:"addons_commit"`
+	EnterpriseCommit   string    `json:"enterprise_commit"`
+	DesignThemesCommit string    `json:"design_themes_commit"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// sameCommits reports whether two revisions point at identical commits.
+func (r *RepoRevision) sameCommits(o *RepoRevision) bool {
+	return r.AddonsCommit == o.AddonsCommit &&
+		r.EnterpriseCommit == o.EnterpriseCommit &&
+		r.DesignThemesCommit == o.DesignThemesCommit
+}
+
+// AddRepoRevision appends a revision to a project's history. Nothing is
+// written when the commits are identical to the current revision, so the
+// history only contains actual changes. Returns whether a row was added.
+func (s *ProjectStore) AddRepoRevision(rev *RepoRevision) (bool, error) {
+	if cur, ok := s.CurrentRepoRevision(rev.ProjectID); ok && cur.sameCommits(rev) {
+		return false, nil
+	}
+
+	rev.CreatedAt = time.Now()
+	result, err := s.db.Exec(
+		`INSERT INTO repo_revisions (project_id, ref, addons_commit, enterprise_commit, design_themes_commit, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		rev.ProjectID, rev.Ref, rev.AddonsCommit, rev.EnterpriseCommit, rev.DesignThemesCommit, rev.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	rev.ID, _ = result.LastInsertId()
+	return true, nil
+}
+
+// ListRepoRevisions returns up to limit revisions for a project, newest first.
+func (s *ProjectStore) ListRepoRevisions(projectID string, limit int) []*RepoRevision {
+	rows, err := s.db.Query(
+		`SELECT id, project_id, ref, addons_commit, enterprise_commit, design_themes_commit, created_at
+		 FROM repo_revisions WHERE project_id = ? ORDER BY id DESC LIMIT ?`, projectID, limit)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var revs []*RepoRevision
+	for rows.Next() {
+		r := &RepoRevision{}
+		if err := rows.Scan(&r.ID, &r.ProjectID, &r.Ref, &r.AddonsCommit, &r.EnterpriseCommit, &r.DesignThemesCommit, &r.CreatedAt); err != nil {
+			continue
+		}
+		revs = append(revs, r)
+	}
+	return revs
+}
+
+// GetRepoRevision retrieves a single revision of a project by ID.
+func (s *ProjectStore) GetRepoRevision(projectID string, id int64) (*RepoRevision, bool) {
+	r := &RepoRevision{}
+	err := s.db.QueryRow(
+		`SELECT id, project_id, ref, addons_commit, enterprise_commit, design_themes_commit, created_at
+		 FROM repo_revisions WHERE project_id = ? AND id = ?`, projectID, id,
+	).Scan(&r.ID, &r.ProjectID, &r.Ref, &r.AddonsCommit, &r.EnterpriseCommit, &r.DesignThemesCommit, &r.CreatedAt)
+	if err != nil {
+		return nil, false
+	}
+	return r, true
+}
+
+// CurrentRepoRevision returns the most recent revision of a project.
+func (s *ProjectStore) CurrentRepoRevision(projectID string) (*RepoRevision, bool) {
+	revs := s.ListRepoRevisions(projectID, 1)
+	if len(revs) == 0 {
+		return nil, false
+	}
+	return revs[0], true
+}
+
+// PreviousRepoRevision returns the revision before the current one.
+func (s *ProjectStore) PreviousRepoRevision(projectID string) (*RepoRevision, bool) {
+	revs := s.ListRepoRevisions(projectID, 2)
+	if len(revs) < 2 {
+		return nil, false
+	}
+	return revs[1], true
+}
+
+// PinnedRepoRevision returns the ID of the revision a project's repos were
+// rolled back to, or 0 when they follow the project's ref.
+func (s *ProjectStore) PinnedRepoRevision(projectID string) int64 {
+	var id int64
+	s.db.QueryRow(`SELECT pinned_revision FROM projects WHERE id = ?`, projectID).Scan(&id)
+	return id
+}
+
+// SetPinnedRepoRevision keeps a project's repos at a revision until they
+// are explicitly updated. 0 lets them follow the project's ref again.
+func (s *ProjectStore) SetPinnedRepoRevision(projectID string, id int64) error {
+	_, err := s.db.Exec(`UPDATE projects SET pinned_revision = ? WHERE id = ?`, id, projectID)
+	return err
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Secrets are encrypted with AES-256-GCM before they are written to SQLite.
+//
+// The key material comes from the ODOO_MANAGER_SECRET_KEY environment
+// variable or, when unset, from a keyfile next to the database
+// (data/secret.key) which is generated on first run. Both hold one or more
+// base64-encoded 32-byte keys separated by newlines or commas: the first key
+// encrypts, every key can decrypt. To rotate, put a new key first and keep
+// the old one after it; on startup all secrets are re-encrypted with the new
+// key, after which the old one can be removed.
+const (
+	secretKeyEnv  = "ODOO_MANAGER_SECRET_KEY"
+	secretKeyFile = "secret.key"
+
+	// secretPrefix marks an encrypted value: enc:v1:<key-id>:<base64(nonce|ciphertext)>
+	secretPrefix = "enc:v1:"
+)
+
+// secretSettingSuffixes lists the setting key suffixes treated as secrets.
+// Any setting whose key ends with one of these is encrypted at rest.
+var secretSettingSuffixes = []string{"_pat", "_token", "_password", "_secret", "_private_key"}
+
+// isSecretSetting reports whether a settings key holds sensitive data.
+func isSecretSetting(key string) bool {
+	for _, suffix := range secretSettingSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretBox encrypts and decrypts secret values with a keyring.
+type secretBox struct {
+	primary string // key ID used for encryption
+	aeads   map[string]cipher.AEAD
+}
+
+// loadSecretBox builds the keyring from the environment or the keyfile in
+// dir, generating a new keyfile if neither exists.
+func loadSecretBox(dir string) (*secretBox, error) {
+	raw := os.Getenv(secretKeyEnv)
+	if raw == "" {
+		path := filepath.Join(dir, secretKeyFile)
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			raw = string(data)
+		case os.IsNotExist(err):
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, fmt.Errorf("generate secret key: %w", err)
+			}
+			raw = base64.StdEncoding.EncodeToString(key)
+			if err := os.WriteFile(path, []byte(raw+"\n"), 0o600); err != nil {
+				return nil, fmt.Errorf("write secret keyfile: %w", err)
+			}
+			log.Printf("Generated new secret key at %s — back it up, secrets cannot be decrypted without it", path)
+		default:
+			return nil, fmt.Errorf("read secret keyfile: %w", err)
+		}
+	}
+
+	box := &secretBox{aeads: make(map[string]cipher.AEAD)}
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ',' || r == ' '
+	})
+	for _, f := range fields {
+		key, err := base64.StdEncoding.DecodeString(f)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("secret key must be base64-encoded 32 bytes")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		id := keyID(key)
+		if box.primary == "" {
+			box.primary = id
+		}
+		box.aeads[id] = aead
+	}
+	if box.primary == "" {
+		return nil, errors.New("no secret key configured")
+	}
+	return box, nil
+}
+
+// keyID returns a short non-secret identifier for a key.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// isEncrypted reports whether a stored value is already encrypted.
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// encrypt seals a plaintext value with the primary key. Empty values are
+// stored as-is so "not configured" stays distinguishable.
+func (b *secretBox) encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	aead := b.aeads[b.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + b.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value produced by encrypt. Plaintext values (written
+// before encryption was introduced) are returned unchanged.
+func (b *secretBox) decrypt(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	rest := strings.TrimPrefix(value, secretPrefix)
+	id, payload, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	aead, ok := b.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("no secret key with id %s", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted value: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value too short")
+	}
+	nonce, ct := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ct, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// needsReseal reports whether a stored value should be re-encrypted: it is
+// either plaintext or sealed with a key other than the primary.
+func (b *secretBox) needsReseal(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !isEncrypted(value) {
+		return true
+	}
+	return !strings.HasPrefix(value, secretPrefix+b.primary+":")
+}
+
+// EncryptSecret encrypts an arbitrary value with the store's primary key so
+// callers can keep secrets in their own columns.
+func (s *ProjectStore) EncryptSecret(plaintext string) (string, error) {
+	return s.secrets.encrypt(plaintext)
+}
+
+// DecryptSecret reverses EncryptSecret. Plaintext input is returned as-is.
+func (s *ProjectStore) DecryptSecret(value string) (string, error) {
+	return s.secrets.decrypt(value)
+}
+
+// ResealSecrets encrypts any plaintext secret settings and re-encrypts those
+// (and the secret project columns) sealed with a non-primary key. Called on
+// startup to migrate existing plaintext values and to complete a key
+// rotation. Returns the number of values rewritten.
+func (s *ProjectStore) ResealSecrets() (int, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return 0, err
+	}
+	pending := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if isSecretSetting(key) && s.secrets.needsReseal(value) {
+			pending[key] = value
+		}
+	}
+	rows.Close()
+
+	n := 0
+	for key, value := range pending {
+		plain, err := s.secrets.decrypt(value)
+		if err != nil {
+			return n, fmt.Errorf("setting %s: %w", key, err)
+		}
+		if err := s.SetSetting(key, plain); err != nil {
+			return n, fmt.Errorf("setting %s: %w", key, err)
+		}
+		n++
+	}
+
+	m, err := s.resealProjectSecrets()
+	return n + m, err
+}
+
+// projectSecretColumns lists the project columns holding encrypted values.
+var projectSecretColumns = []string{"webhook_secret", "db_password"}
+
+// resealProjectSecrets re-encrypts the secrets stored on projects.
+func (s *ProjectStore) resealProjectSecrets() (int, error) {
+	n := 0
+	for _, column := range projectSecretColumns {
+		m, err := s.resealProjectColumn(column)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// resealProjectColumn re-encrypts one secret column of every project.
+func (s *ProjectStore) resealProjectColumn(column string) (int, error) {
+	rows, err := s.db.Query(`SELECT id, ` + column + ` FROM projects`)
+	if err != nil {
+		return 0, err
+	}
+	pending := map[string]string{}
+	for rows.Next() {
+		var id, value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if s.secrets.needsReseal(value) {
+			pending[id] = value
+		}
+	}
+	rows.Close()
+
+	n := 0
+	for id, value := range pending {
+		plain, err := s.secrets.decrypt(value)
+		if err != nil {
+			return n, fmt.Errorf("project %s %s: %w", id, column, err)
+		}
+		sealed, err := s.secrets.encrypt(plain)
+		if err != nil {
+			return n, fmt.Errorf("project %s %s: %w", id, column, err)
+		}
+		if _, err := s.db.Exec(`UPDATE projects SET `+column+` = ? WHERE id = ?`, sealed, id); err != nil {
+			return n, fmt.Errorf("project %s %s: %w", id, column, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// newDBCredentials generates a random PostgreSQL user and password for a
+// new project.
+func newDBCredentials() (user, password string, err error) {
+	buf := make([]byte, 28)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generate database credentials: %w", err)
+	}
+	return "odoo_" + hex.EncodeToString(buf[:4]), hex.EncodeToString(buf[4:]), nil
+}
+
+// openDBPassword decrypts a project's stored database password into p.
+func (s *ProjectStore) openDBPassword(p *Project, sealed string) {
+	password, err := s.secrets.decrypt(sealed)
+	if err != nil {
+		log.Printf("Warning: cannot decrypt database password of project %s: %v", p.ID, err)
+		return
+	}
+	p.DBPassword = password
+}
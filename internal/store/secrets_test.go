@@ -0,0 +1,183 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestLoadSecretBox(t *testing.T) {
+	keyA, keyB := newTestKey(t), newTestKey(t)
+	tests := []struct {
+		name    string
+		env     string
+		wantErr bool
+	}{
+		{name: "single key", env: keyA},
+		{name: "keys separated by commas", env: keyA + "," + keyB},
+		{name: "keys separated by newlines", env: keyA + "\n" + keyB + "\n"},
+		{name: "not base64", env: "not a key!", wantErr: true},
+		{name: "short key", env: base64.StdEncoding.EncodeToString([]byte("too short")), wantErr: true},
+		{name: "only separators", env: " ,\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(secretKeyEnv, tt.env)
+			box, err := loadSecretBox(t.TempDir())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("loadSecretBox accepted an invalid key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadSecretBox: %v", err)
+			}
+			if box.primary == "" {
+				t.Fatal("no primary key")
+			}
+		})
+	}
+}
+
+func TestLoadSecretBoxGeneratesKeyfile(t *testing.T) {
+	t.Setenv(secretKeyEnv, "")
+	dir := t.TempDir()
+	box, err := loadSecretBox(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, secretKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("keyfile mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// The same keyfile is used on the next start
+	again, err := loadSecretBox(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.encrypt("ghp_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := again.decrypt(sealed); err != nil || plain != "ghp_secret" {
+		t.Fatalf("decrypt after reload = %q, %v", plain, err)
+	}
+}
+
+func TestSecretBox(t *testing.T) {
+	keyOld, keyNew := newTestKey(t), newTestKey(t)
+	t.Setenv(secretKeyEnv, keyOld)
+	old, err := loadSecretBox(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rotated keyring: the new key encrypts, the old one still decrypts
+	t.Setenv(secretKeyEnv, keyNew+","+keyOld)
+	box, err := loadSecretBox(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(secretKeyEnv, newTestKey(t))
+	other, err := loadSecretBox(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealedOld, err := old.encrypt("ghp_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedNew, err := box.encrypt("ghp_secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := sealedNew[:len(sealedNew)-4] + "AAAA"
+
+	tests := []struct {
+		name       string
+		box        *secretBox
+		value      string
+		want       string
+		wantErr    string
+		wantReseal bool
+	}{
+		{name: "empty stays empty", box: box, value: "", want: ""},
+		{name: "plaintext passes through", box: box, value: "plain", want: "plain", wantReseal: true},
+		{name: "primary key", box: box, value: sealedNew, want: "ghp_secret"},
+		{name: "rotated out key", box: box, value: sealedOld, want: "ghp_secret", wantReseal: true},
+		{name: "unknown key", box: other, value: sealedNew, wantErr: "no secret key", wantReseal: true},
+		{name: "tampered ciphertext", box: box, value: tampered, wantErr: "decrypt"},
+		{name: "malformed", box: box, value: secretPrefix + "nokeyid", wantErr: "malformed", wantReseal: true},
+		{name: "bad base64", box: box, value: secretPrefix + box.primary + ":%%%", wantErr: "decode"},
+		{name: "too short", box: box, value: secretPrefix + box.primary + ":AAAA", wantErr: "too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.box.decrypt(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decrypt error = %v, want one containing %q", err, tt.wantErr)
+				}
+			} else if err != nil || got != tt.want {
+				t.Fatalf("decrypt = %q, %v, want %q", got, err, tt.want)
+			}
+			if reseal := tt.box.needsReseal(tt.value); reseal != tt.wantReseal {
+				t.Errorf("needsReseal = %v, want %v", reseal, tt.wantReseal)
+			}
+		})
+	}
+}
+
+func TestSecretBoxEncrypt(t *testing.T) {
+	t.Setenv(secretKeyEnv, newTestKey(t))
+	box, err := loadSecretBox(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed, err := box.encrypt(""); err != nil || sealed != "" {
+		t.Fatalf("encrypt(\"\") = %q, %v, want empty", sealed, err)
+	}
+	a, _ := box.encrypt("same")
+	b, _ := box.encrypt("same")
+	if a == b {
+		t.Error("two encryptions of the same value are identical; the nonce is not random")
+	}
+	if !isEncrypted(a) || strings.Contains(a, "same") {
+		t.Errorf("encrypt = %q, want an opaque enc:v1 value", a)
+	}
+}
+
+func TestIsSecretSetting(t *testing.T) {
+	tests := map[string]bool{
+		"github_pat":         true,
+		"oidc_client_secret": true,
+		"smtp_password":      true,
+		"deploy_private_key": true,
+		"api_token":          true,
+		"bind_address":       false,
+		"port_range":         false,
+		"pat":                false,
+	}
+	for key, want := range tests {
+		if got := isSecretSetting(key); got != want {
+			t.Errorf("isSecretSetting(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package store
 
 import (
 	"database/sql"
+	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"time"
@@ -11,24 +13,45 @@ import (
 
 // Project represents an Odoo project configuration
 type Project struct {
-	ID                  string    `json:"id"`
-	Name                string    `json:"name"`
-	Description         string    `json:"description"`
-	OdooVersion         string    `json:"odoo_version"`
-	PostgresVersion     string    `json:"postgres_version"`
-	Port                int       `json:"port"`
-	Status              string    `json:"status"` // running, stopped, error
-	GitRepoURL          string    `json:"git_repo_url"`
-	GitRepoBranch       string    `json:"git_repo_branch"`
-	EnterpriseEnabled   bool      `json:"enterprise_enabled"`
-	DesignThemesEnabled bool      `json:"design_themes_enabled"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  string     `json:"id"`
+	Name                string     `json:"name"`
+	Description         string     `json:"description"`
+	OdooVersion         string     `json:"odoo_version"`
+	PostgresVersion     string     `json:"postgres_version"`
+	Port                int        `json:"port"`
+	GeventPort          int        `json:"gevent_port"`  // host port for longpolling/gevent (8072), 0 = not published
+	DebugPort           int        `json:"debug_port"`   // host port for debugpy (5678), 0 = debugging off
+	DBPort              int        `json:"db_port"`      // host port for PostgreSQL (5432), 0 = not published
+	BindAddress         string     `json:"bind_address"` // host IP the ports are published on, e.g. 127.0.0.1 or 0.0.0.0
+	Exposed             bool       `json:"exposed"`      // published beyond localhost; derived from BindAddress
+	Status              string     `json:"status"`       // running, stopped, error
+	GitRepoURL          string     `json:"git_repo_url"`
+	GitRepoBranch       string     `json:"git_repo_branch"`   // branch, tag or commit SHA
+	LocalAddonsPath     string     `json:"local_addons_path"` // host folder mounted instead of a git repo
+	EnterpriseEnabled   bool       `json:"enterprise_enabled"`
+	DesignThemesEnabled bool       `json:"design_themes_enabled"`
+	PreviewOf           string     `json:"preview_of,omitempty"` // template project ID of a preview environment
+	ExpiresAt           *time.Time `json:"expires_at,omitempty"` // previews are deleted after this time
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+
+	// PostgreSQL credentials of the project's containers, generated by
+	// Create and never changed afterwards. The password is encrypted at rest.
+	DBUser     string `json:"-"`
+	DBPassword string `json:"-"`
+}
+
+// IsExposedAddress reports whether ports bound to addr are reachable from
+// other machines, i.e. addr is not a loopback address.
+func IsExposedAddress(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip == nil || !ip.IsLoopback()
 }
 
 // ProjectStore manages projects persistence using SQLite
 type ProjectStore struct {
-	db *sql.DB
+	db      *sql.DB
+	secrets *secretBox
 }
 
 // NewProjectStore creates a new project store backed by SQLite
@@ -39,6 +62,12 @@ func NewProjectStore(dbPath string) (*ProjectStore, error) {
 		return nil, err
 	}
 
+	// Load the key used to encrypt secret settings
+	secrets, err := loadSecretBox(dir)
+	if err != nil {
+		return nil, err
+	}
+
 	db, err := sql.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, err
@@ -65,7 +94,7 @@ func NewProjectStore(dbPath string) (*ProjectStore, error) {
 		return nil, err
 	}
 
-	return &ProjectStore{db: db}, nil
+	return &ProjectStore{db: db, secrets: secrets}, nil
 }
 
 // Close closes the underlying database connection
@@ -78,12 +107,25 @@ func (s *ProjectStore) Create(project *Project) error {
 	now := time.Now()
 	project.CreatedAt = now
 	project.UpdatedAt = now
+	project.Exposed = IsExposedAddress(project.BindAddress)
 
-	_, err := s.db.Exec(
-		`INSERT INTO projects (id, name, description, odoo_version, postgres_version, port, status, git_repo_url, git_repo_branch, enterprise_enabled, design_themes_enabled, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	if project.DBUser == "" {
+		user, password, err := newDBCredentials()
+		if err != nil {
+			return err
+		}
+		project.DBUser, project.DBPassword = user, password
+	}
+	sealedPassword, err := s.secrets.encrypt(project.DBPassword)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO projects (id, name, description, odoo_version, postgres_version, port, gevent_port, debug_port, db_port, bind_address, status, git_repo_url, git_repo_branch, local_addons_path, enterprise_enabled, design_themes_enabled, preview_of, expires_at, db_user, db_password, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		project.ID, project.Name, project.Description, project.OdooVersion,
-		project.PostgresVersion, project.Port, project.Status, project.GitRepoURL, project.GitRepoBranch, project.EnterpriseEnabled, project.DesignThemesEnabled, project.CreatedAt, project.UpdatedAt,
+		project.PostgresVersion, project.Port, project.GeventPort, project.DebugPort, project.DBPort, project.BindAddress, project.Status, project.GitRepoURL, project.GitRepoBranch, project.LocalAddonsPath, project.EnterpriseEnabled, project.DesignThemesEnabled, project.PreviewOf, project.ExpiresAt, project.DBUser, sealedPassword, project.CreatedAt, project.UpdatedAt,
 	)
 	return err
 }
@@ -91,21 +133,24 @@ func (s *ProjectStore) Create(project *Project) error {
 // Get retrieves a project by ID
 func (s *ProjectStore) Get(id string) (*Project, bool) {
 	p := &Project{}
+	var sealedPassword string
 	err := s.db.QueryRow(
-		`SELECT id, name, description, odoo_version, postgres_version, port, status, git_repo_url, git_repo_branch, enterprise_enabled, design_themes_enabled, created_at, updated_at
+		`SELECT id, name, description, odoo_version, postgres_version, port, gevent_port, debug_port, db_port, bind_address, status, git_repo_url, git_repo_branch, local_addons_path, enterprise_enabled, design_themes_enabled, preview_of, expires_at, db_user, db_password, created_at, updated_at
 		 FROM projects WHERE id = ?`, id,
 	).Scan(&p.ID, &p.Name, &p.Description, &p.OdooVersion, &p.PostgresVersion,
-		&p.Port, &p.Status, &p.GitRepoURL, &p.GitRepoBranch, &p.EnterpriseEnabled, &p.DesignThemesEnabled, &p.CreatedAt, &p.UpdatedAt)
+		&p.Port, &p.GeventPort, &p.DebugPort, &p.DBPort, &p.BindAddress, &p.Status, &p.GitRepoURL, &p.GitRepoBranch, &p.LocalAddonsPath, &p.EnterpriseEnabled, &p.DesignThemesEnabled, &p.PreviewOf, &p.ExpiresAt, &p.DBUser, &sealedPassword, &p.CreatedAt, &p.UpdatedAt)
 	if err != nil {
 		return nil, false
 	}
+	s.openDBPassword(p, sealedPassword)
+	p.Exposed = IsExposedAddress(p.BindAddress)
 	return p, true
 }
 
 // List returns all projects
 func (s *ProjectStore) List() []*Project {
 	rows, err := s.db.Query(
-		`SELECT id, name, description, odoo_version, postgres_version, port, status, git_repo_url, git_repo_branch, enterprise_enabled, design_themes_enabled, created_at, updated_at
+		`SELECT id, name, description, odoo_version, postgres_version, port, gevent_port, debug_port, db_port, bind_address, status, git_repo_url, git_repo_branch, local_addons_path, enterprise_enabled, design_themes_enabled, preview_of, expires_at, db_user, db_password, created_at, updated_at
 		 FROM projects ORDER BY created_at DESC`)
 	if err != nil {
 		return nil
@@ -115,10 +160,13 @@ func (s *ProjectStore) List() []*Project {
 	var projects []*Project
 	for rows.Next() {
 		p := &Project{}
+		var sealedPassword string
 		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.OdooVersion, &p.PostgresVersion,
-			&p.Port, &p.Status, &p.GitRepoURL, &p.GitRepoBranch, &p.EnterpriseEnabled, &p.DesignThemesEnabled, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			&p.Port, &p.GeventPort, &p.DebugPort, &p.DBPort, &p.BindAddress, &p.Status, &p.GitRepoURL, &p.GitRepoBranch, &p.LocalAddonsPath, &p.EnterpriseEnabled, &p.DesignThemesEnabled, &p.PreviewOf, &p.ExpiresAt, &p.DBUser, &sealedPassword, &p.CreatedAt, &p.UpdatedAt); err != nil {
 			continue
 		}
+		s.openDBPassword(p, sealedPassword)
+		p.Exposed = IsExposedAddress(p.BindAddress)
 		projects = append(projects, p)
 	}
 	return projects
@@ -127,12 +175,13 @@ func (s *ProjectStore) List() []*Project {
 // Update modifies an existing project
 func (s *ProjectStore) Update(project *Project) error {
 	project.UpdatedAt = time.Now()
+	project.Exposed = IsExposedAddress(project.BindAddress)
 
 	result, err := s.db.Exec(
-		`UPDATE projects SET name=?, description=?, odoo_version=?, postgres_version=?, port=?, status=?, git_repo_url=?, git_repo_branch=?, enterprise_enabled=?, design_themes_enabled=?, updated_at=?
+		`UPDATE projects SET name=?, description=?, odoo_version=?, postgres_version=?, port=?, gevent_port=?, debug_port=?, db_port=?, bind_address=?, status=?, git_repo_url=?, git_repo_branch=?, local_addons_path=?, enterprise_enabled=?, design_themes_enabled=?, preview_of=?, expires_at=?, updated_at=?
 		 WHERE id=?`,
 		project.Name, project.Description, project.OdooVersion, project.PostgresVersion,
-		project.Port, project.Status, project.GitRepoURL, project.GitRepoBranch, project.EnterpriseEnabled, project.DesignThemesEnabled, project.UpdatedAt, project.ID,
+		project.Port, project.GeventPort, project.DebugPort, project.DBPort, project.BindAddress, project.Status, project.GitRepoURL, project.GitRepoBranch, project.LocalAddonsPath, project.EnterpriseEnabled, project.DesignThemesEnabled, project.PreviewOf, project.ExpiresAt, project.UpdatedAt, project.ID,
 	)
 	if err != nil {
 		return err
@@ -143,12 +192,51 @@ func (s *ProjectStore) Update(project *Project) error {
 	return nil
 }
 
-// Delete removes a project
+// Delete removes a project and its repo revision history
 func (s *ProjectStore) Delete(id string) error {
+	if _, err := s.db.Exec(`DELETE FROM repo_revisions WHERE project_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM project_members WHERE project_id = ?`, id); err != nil {
+		return err
+	}
 	_, err := s.db.Exec(`DELETE FROM projects WHERE id = ?`, id)
 	return err
 }
 
+// GetWebhookSecret returns the decrypted git webhook secret of a project,
+// or empty string if webhooks are not enabled for it. The secret is kept
+// out of Project so it never appears in API responses.
+func (s *ProjectStore) GetWebhookSecret(id string) string {
+	var value string
+	if err := s.db.QueryRow(`SELECT webhook_secret FROM projects WHERE id = ?`, id).Scan(&value); err != nil {
+		return ""
+	}
+	plain, err := s.secrets.decrypt(value)
+	if err != nil {
+		log.Printf("Warning: cannot decrypt webhook secret of project %s: %v", id, err)
+		return ""
+	}
+	return plain
+}
+
+// SetWebhookSecret stores a project's git webhook secret encrypted. An
+// empty secret disables webhooks for the project.
+func (s *ProjectStore) SetWebhookSecret(id, secret string) error {
+	value, err := s.secrets.encrypt(secret)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE projects SET webhook_secret = ? WHERE id = ?`, value, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // NameExists checks if a project with the given name already exists (optionally excluding an ID)
 func (s *ProjectStore) NameExists(name string, excludeID string) bool {
 	var count int
@@ -186,14 +274,31 @@ func (s *ProjectStore) ReconcileStaleStatuses() (int64, error) {
 }
 
 // GetSetting retrieves a setting value by key. Returns empty string if not found.
+// Secret settings are transparently decrypted.
 func (s *ProjectStore) GetSetting(key string) string {
 	var value string
 	s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if isSecretSetting(key) {
+		plain, err := s.secrets.decrypt(value)
+		if err != nil {
+			log.Printf("Warning: failed to decrypt setting %s: %v", key, err)
+			return ""
+		}
+		return plain
+	}
 	return value
 }
 
-// SetSetting creates or updates a setting.
+// SetSetting creates or updates a setting. Secret settings are encrypted
+// before they are written.
 func (s *ProjectStore) SetSetting(key, value string) error {
+	if isSecretSetting(key) {
+		sealed, err := s.secrets.encrypt(value)
+		if err != nil {
+			return err
+		}
+		value = sealed
+	}
 	_, err := s.db.Exec(
 		`INSERT INTO settings (key, value) VALUES (?, ?)
 		 ON CONFLICT(key) DO UPDATE SET value = excluded.value`,
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"time"
+)
+
+// API token scopes, from most to least privileged. A token never allows
+// more than the role of its owner.
+const (
+	ScopeAdmin   = "admin"   // everything the owner's role allows
+	ScopeManage  = "manage"  // create, change and delete projects
+	ScopeOperate = "operate" // start, stop, logs and backups
+	ScopeRead    = "read"    // read-only access
+)
+
+// Scopes lists the valid token scopes, from most to least privileged.
+var Scopes = []string{ScopeAdmin, ScopeManage, ScopeOperate, ScopeRead}
+
+// IsValidScope reports whether scope is one of Scopes.
+func IsValidScope(scope string) bool {
+	for _, sc := range Scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenPrefix marks API tokens so they are easy to recognise in scripts and
+// secret scanners.
+const tokenPrefix = "omt_"
+
+// APIToken is a personal access token for scripts and CI. Only the hash of
+// the token is stored; the value is shown once when it is created.
+type APIToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+const tokenColumns = `id, user_id, name, scope, created_at, expires_at, last_used_at`
+
+func scanToken(row interface{ Scan(...any) error }) (*APIToken, bool) {
+	t := &APIToken{}
+	var expires, lastUsed sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.CreatedAt, &expires, &lastUsed); err != nil {
+		return nil, false
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	return t, true
+}
+
+// CreateAPIToken adds a token for a user and returns it together with its
+// value. A zero ttl means the token never expires.
+func (s *ProjectStore) CreateAPIToken(userID int64, name, scope string, ttl time.Duration) (*APIToken, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	value := tokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	t := &APIToken{UserID: userID, Name: name, Scope: scope, CreatedAt: time.Now()}
+	if ttl > 0 {
+		expires := t.CreatedAt.Add(ttl)
+		t.ExpiresAt = &expires
+	}
+	result, err := s.db.Exec(
+		`INSERT INTO api_tokens (user_id, name, scope, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		t.UserID, t.Name, t.Scope, hashSessionToken(value), t.CreatedAt, t.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	t.ID, _ = result.LastInsertId()
+	return t, value, nil
+}
+
+// ListAPITokens returns the tokens of a user, newest first.
+func (s *ProjectStore) ListAPITokens(userID int64) []*APIToken {
+	rows, err := s.db.Query(`SELECT `+tokenColumns+` FROM api_tokens WHERE user_id = ? ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var tokens []*APIToken
+	for rows.Next() {
+		if t, ok := scanToken(rows); ok {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// DeleteAPIToken revokes one of a user's tokens.
+func (s *ProjectStore) DeleteAPIToken(userID, id int64) error {
+	result, err := s.db.Exec(`DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// APITokenUser returns an unexpired token and its owner, and records that
+// the token was used.
+func (s *ProjectStore) APITokenUser(value string) (*APIToken, *User, bool) {
+	if value == "" {
+		return nil, nil, false
+	}
+	now := time.Now()
+	t, ok := scanToken(s.db.QueryRow(
+		`SELECT `+tokenColumns+` FROM api_tokens
+		 WHERE token_hash = ? AND (expires_at IS NULL OR expires_at > ?)`, hashSessionToken(value), now))
+	if !ok {
+		return nil, nil, false
+	}
+	u, ok := s.GetUser(t.UserID)
+	if !ok {
+		return nil, nil, false
+	}
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, now, t.ID); err == nil {
+		t.LastUsedAt = &now
+	}
+	return t, u, true
+}
@@ -0,0 +1,342 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User roles, from most to least privileged.
+const (
+	RoleAdmin      = "admin"      // everything, including settings, maintenance and users
+	RoleMaintainer = "maintainer" // create, change and delete any project
+	RoleDeveloper  = "developer"  // start, stop, logs and backups of assigned projects
+	RoleViewer     = "viewer"     // read-only access to assigned projects
+)
+
+// Roles lists the valid roles, from most to least privileged.
+var Roles = []string{RoleAdmin, RoleMaintainer, RoleDeveloper, RoleViewer}
+
+// IsValidRole reports whether role is one of Roles.
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// User is an account that can sign in to the web UI and API.
+type User struct {
+	ID           int64     `json:"id"`
+	Username     string    `json:"username"`
+	Role         string    `json:"role"`
+	PasswordHash string    `json:"-"`
+	OIDCIssuer   string    `json:"oidc_issuer,omitempty"`  // set for users provisioned by single sign-on
+	OIDCSubject  string    `json:"oidc_subject,omitempty"` // provider's ID of the user, unique per issuer
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SeesAllProjects reports whether the user can see every project rather
+// than only those they are a member of.
+func (u *User) SeesAllProjects() bool {
+	return u.Role == RoleAdmin || u.Role == RoleMaintainer
+}
+
+const userColumns = `id, username, role, password_hash, oidc_issuer, oidc_subject, created_at, updated_at`
+
+// dummyPasswordHash is compared against when a username does not exist so
+// that failed logins take the same time whether or not the user exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("odoo-manager"), bcrypt.DefaultCost)
+
+// CountUsers returns the number of user accounts.
+func (s *ProjectStore) CountUsers() int {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// CreateUser adds a user with a bcrypt hash of the given password.
+func (s *ProjectStore) CreateUser(username, password, role string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	u := &User{Username: username, Role: role, PasswordHash: string(hash), CreatedAt: now, UpdatedAt: now}
+	result, err := s.db.Exec(
+		`INSERT INTO users (username, role, password_hash, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		u.Username, u.Role, u.PasswordHash, u.CreatedAt, u.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	u.ID, _ = result.LastInsertId()
+	return u, nil
+}
+
+// CreateOIDCUser adds a user provisioned by single sign-on. It has no
+// password, so it can only sign in through the provider.
+func (s *ProjectStore) CreateOIDCUser(username, issuer, subject, role string) (*User, error) {
+	now := time.Now()
+	u := &User{Username: username, Role: role, OIDCIssuer: issuer, OIDCSubject: subject, CreatedAt: now, UpdatedAt: now}
+	result, err := s.db.Exec(
+		`INSERT INTO users (username, role, password_hash, oidc_issuer, oidc_subject, created_at, updated_at) VALUES (?, ?, '', ?, ?, ?, ?)`,
+		u.Username, u.Role, u.OIDCIssuer, u.OIDCSubject, u.CreatedAt, u.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	u.ID, _ = result.LastInsertId()
+	return u, nil
+}
+
+// GetUserByOIDCSubject retrieves the user provisioned for a subject of the
+// provider with the given issuer.
+func (s *ProjectStore) GetUserByOIDCSubject(issuer, subject string) (*User, bool) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE oidc_issuer = ? AND oidc_subject = ?`, issuer, subject))
+}
+
+// ListUsers returns all users ordered by username.
+func (s *ProjectStore) ListUsers() []*User {
+	rows, err := s.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username COLLATE NOCASE`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		if u, ok := scanUser(rows); ok {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// GetUser retrieves a user by ID.
+func (s *ProjectStore) GetUser(id int64) (*User, bool) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+// GetUserByUsername retrieves a user by username, ignoring case.
+func (s *ProjectStore) GetUserByUsername(username string) (*User, bool) {
+	return scanUser(s.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, username))
+}
+
+func scanUser(row interface{ Scan(...any) error }) (*User, bool) {
+	u := &User{}
+	var issuer, subject sql.NullString
+	if err := row.Scan(&u.ID, &u.Username, &u.Role, &u.PasswordHash, &issuer, &subject, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return nil, false
+	}
+	u.OIDCIssuer, u.OIDCSubject = issuer.String, subject.String
+	return u, true
+}
+
+// CountAdmins returns the number of users with the admin role.
+func (s *ProjectStore) CountAdmins() int {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = ?`, RoleAdmin).Scan(&n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// SetUserRole changes a user's role.
+func (s *ProjectStore) SetUserRole(id int64, role string) error {
+	result, err := s.db.Exec(`UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, role, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUser removes a user together with their sessions, API tokens and
+// project memberships.
+func (s *ProjectStore) DeleteUser(id int64) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM project_members WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM api_tokens WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM users WHERE id = ?`, id)
+	return err
+}
+
+// Authenticate returns the user matching username and password.
+func (s *ProjectStore) Authenticate(username, password string) (*User, bool) {
+	u, ok := s.GetUserByUsername(username)
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, false
+	}
+	return u, true
+}
+
+// SetUserPassword replaces a user's password.
+func (s *ProjectStore) SetUserPassword(id int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`, string(hash), time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// hashSessionToken returns the value stored for a session token.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession starts a session for a user that expires after ttl and
+// returns its token. Expired sessions are pruned on the way.
+func (s *ProjectStore) CreateSession(userID int64, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := time.Now()
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at < ?`, now); err != nil {
+		return "", err
+	}
+	if _, err := s.db.Exec(
+		`INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+		hashSessionToken(token), userID, now, now.Add(ttl),
+	); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SessionUser returns the user of an unexpired session.
+func (s *ProjectStore) SessionUser(token string) (*User, bool) {
+	if token == "" {
+		return nil, false
+	}
+	return scanUser(s.db.QueryRow(
+		`SELECT u.id, u.username, u.role, u.password_hash, u.oidc_issuer, u.oidc_subject, u.created_at, u.updated_at
+		 FROM sessions s JOIN users u ON u.id = s.user_id
+		 WHERE s.token_hash = ? AND s.expires_at > ?`, hashSessionToken(token), time.Now()))
+}
+
+// DeleteSession ends a session.
+func (s *ProjectStore) DeleteSession(token string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, hashSessionToken(token))
+	return err
+}
+
+// DeleteUserSessions ends every session of a user except keepToken, which
+// may be empty.
+func (s *ProjectStore) DeleteUserSessions(userID int64, keepToken string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE user_id = ? AND token_hash != ?`, userID, hashSessionToken(keepToken))
+	return err
+}
+
+// ProjectMemberIDs returns the IDs of the users assigned to a project.
+func (s *ProjectStore) ProjectMemberIDs(projectID string) []int64 {
+	rows, err := s.db.Query(`SELECT user_id FROM project_members WHERE project_id = ? ORDER BY user_id`, projectID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// SetProjectMembers replaces the users assigned to a project.
+func (s *ProjectStore) SetProjectMembers(projectID string, userIDs []int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM project_members WHERE project_id = ?`, projectID); err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO project_members (project_id, user_id) VALUES (?, ?)`, projectID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// IsProjectMember reports whether a user is assigned to a project.
+func (s *ProjectStore) IsProjectMember(projectID string, userID int64) bool {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM project_members WHERE project_id = ? AND user_id = ?`, projectID, userID).Scan(&n)
+	return err == nil && n > 0
+}
+
+// CanSeeProject reports whether a user may see a project: admins and
+// maintainers see all projects, other roles only those they are assigned to.
+func (s *ProjectStore) CanSeeProject(user *User, projectID string) bool {
+	return user.SeesAllProjects() || s.IsProjectMember(projectID, user.ID)
+}
+
+// ListFor returns the projects a user can see, like List.
+func (s *ProjectStore) ListFor(user *User) []*Project {
+	projects := s.List()
+	if user.SeesAllProjects() {
+		return projects
+	}
+
+	rows, err := s.db.Query(`SELECT project_id FROM project_members WHERE user_id = ?`, user.ID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	member := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			member[id] = true
+		}
+	}
+
+	visible := []*Project{}
+	for _, p := range projects {
+		if member[p.ID] {
+			visible = append(visible, p)
+		}
+	}
+	return visible
+}